@@ -5,6 +5,7 @@ import (
 	"io"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 )
 
@@ -25,11 +26,18 @@ type AsyncWriter struct {
 	once    sync.Once
 	ch      chan *Entry
 	chClose chan error
+	chFlush chan struct{}
+	closed  atomic.Bool
 	file    *FileWriter
 }
 
+// asyncFlush is the marker entry of AsyncWriter.Flush.
+var asyncFlush = new(Entry)
+
 // Close implements io.Closer, and closes the underlying Writer.
 func (w *AsyncWriter) Close() (err error) {
+	w.init()
+	w.closed.Store(true)
 	w.ch <- nil
 	err = <-w.chClose
 	if closer, ok := w.Writer.(io.Closer); ok {
@@ -60,12 +68,12 @@ func (w *AsyncWriter) Write(p []byte) (n int, err error) {
 	return
 }
 
-// WriteEntry implements Writer.
-func (w *AsyncWriter) WriteEntry(e *Entry) (int, error) {
+func (w *AsyncWriter) init() {
 	w.once.Do(func() {
 		// channels
 		w.ch = make(chan *Entry, w.ChannelSize)
 		w.chClose = make(chan error)
+		w.chFlush = make(chan struct{})
 		w.file, _ = w.Writer.(*FileWriter)
 		if w.file != nil && runtime.GOOS == "linux" && unsafe.Sizeof(uintptr(0)) == 8 && !w.DisableWritev {
 			go w.writever()
@@ -73,10 +81,32 @@ func (w *AsyncWriter) WriteEntry(e *Entry) (int, error) {
 			go w.writer()
 		}
 	})
+}
+
+// Flush implements Flusher, it waits for the queued entries to be written and flushes the underlying Writer.
+func (w *AsyncWriter) Flush() error {
+	w.init()
+	if w.closed.Load() {
+		return nil
+	}
+	w.ch <- asyncFlush
+	<-w.chFlush
+	if flusher, ok := w.Writer.(Flusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+// WriteEntry implements Writer.
+func (w *AsyncWriter) WriteEntry(e *Entry) (int, error) {
+	w.init()
 
 	// cheating to logger pool
 	entry := epool.Get().(*Entry)
 	entry.Level = e.Level
+	entry.logger = e.logger
+	entry.cbor = e.cbor
+	entry.logfmt = e.logfmt
 	entry.buf, e.buf = e.buf, entry.buf
 
 	if w.DiscardOnFull {
@@ -98,6 +128,10 @@ func (w *AsyncWriter) writer() {
 		if entry == nil {
 			break
 		}
+		if entry == asyncFlush {
+			w.chFlush <- struct{}{}
+			continue
+		}
 		_, err = w.Writer.WriteEntry(entry)
 		epool.Put(entry)
 	}
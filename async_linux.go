@@ -20,6 +20,10 @@ func (w *AsyncWriter) writever() {
 		if es[0] == nil {
 			break
 		}
+		if es[0] == asyncFlush {
+			w.chFlush <- struct{}{}
+			continue
+		}
 		iovs[0].Base = &es[0].buf[0]
 		iovs[0].Len = uint64(len(es[0].buf))
 		// drain the channel
@@ -27,13 +31,17 @@ func (w *AsyncWriter) writever() {
 		if length > IOV_MAX-1 {
 			length = IOV_MAX - 1
 		}
-		n := 1
+		n, flush := 1, false
 		for n <= length {
 			es[n] = <-w.ch
 			if es[n] == nil {
 				quit = true
 				break
 			}
+			if es[n] == asyncFlush {
+				flush = true
+				break
+			}
 			iovs[n].Base = &es[n].buf[0]
 			iovs[n].Len = uint64(len(es[n].buf))
 			n++
@@ -47,6 +55,10 @@ func (w *AsyncWriter) writever() {
 			es[i] = nil
 			iovs[i].Base = nil
 		}
+		if flush {
+			es[n] = nil
+			w.chFlush <- struct{}{}
+		}
 	}
 	w.chClose <- err
 }
@@ -0,0 +1,351 @@
+package log
+
+import (
+	"strconv"
+	"time"
+	"unsafe"
+)
+
+// logfmtArray marks the nested arrays of logfmt entries, whose elements are added as JSON.
+const logfmtArray = ^0
+
+// end ends the nested objects and arrays which are not ended.
+func (e *Entry) end() {
+	for n := len(e.nest); n != 0; n = len(e.nest) {
+		if e.nest[n-1] < 0 {
+			e.EndArray()
+		} else {
+			e.EndObject()
+		}
+	}
+}
+
+// begin adds the key of a nested value, the key is omitted inside arrays.
+func (e *Entry) begin(key string) {
+	if n := len(e.nest); n != 0 && e.nest[n-1] < 0 {
+		if !e.cbor {
+			e.buf = append(e.buf, ',')
+		}
+		return
+	}
+	e.key(key)
+}
+
+// BeginObject starts a nested object of key, the fields added until EndObject are its members.
+// Inside arrays the key is ignored and the object is added as an element, e.g.
+//
+//	log.Info().
+//		BeginObject("request").
+//		Str("method", "GET").
+//		BeginArray("tags").AppendStr("a").AppendStr("b").EndArray().
+//		EndObject().
+//		Msg("hello world")
+//
+//	// Output: {"time":"2019-07-10T05:35:54.277Z","level":"info","request":{"method":"GET","tags":["a","b"]},"message":"hello world"}
+func (e *Entry) BeginObject(key string) *Entry {
+	if e == nil {
+		return nil
+	}
+
+	if e.logfmt {
+		// the members are flattened to the dotted keys of key, which are checked together
+		e.keyFields(e.keyObject(key))
+		e.nest = append(e.nest, len(e.prefix))
+		e.prefix = append(logfmtAppendName(e.prefix, key), '.')
+		return e
+	}
+
+	e.begin(key)
+	if e.cbor {
+		e.buf = append(e.buf, cborMap|cborIndefinite)
+	}
+	e.nest = append(e.nest, len(e.buf))
+	return e
+}
+
+// EndObject ends the nested object started by BeginObject.
+func (e *Entry) EndObject() *Entry {
+	if e == nil {
+		return nil
+	}
+
+	n := len(e.nest)
+	if n == 0 || e.nest[n-1] < 0 {
+		return e
+	}
+	i := e.nest[n-1]
+	e.nest = e.nest[:n-1]
+	if e.logfmt {
+		e.prefix = e.prefix[:i]
+	} else if e.cbor {
+		e.buf = append(e.buf, cborBreak)
+	} else if i < len(e.buf) {
+		e.buf[i] = '{'
+		e.buf = append(e.buf, '}')
+	} else {
+		e.buf = append(e.buf, '{', '}')
+	}
+	return e
+}
+
+// BeginArray starts a nested array of key, the elements added by the Append methods,
+// BeginObject and BeginArray until EndArray are its elements.
+func (e *Entry) BeginArray(key string) *Entry {
+	if e == nil {
+		return nil
+	}
+
+	if e.logfmt {
+		// the elements are added as JSON, the array is its JSON text
+		e.key(key)
+		e.nest = append(e.nest, logfmtArray)
+		e.logfmt = false
+	} else {
+		e.begin(key)
+	}
+	if e.cbor {
+		e.buf = append(e.buf, cborArray|cborIndefinite)
+	}
+	e.nest = append(e.nest, ^len(e.buf))
+	return e
+}
+
+// EndArray ends the nested array started by BeginArray.
+func (e *Entry) EndArray() *Entry {
+	if e == nil {
+		return nil
+	}
+
+	n := len(e.nest)
+	if n == 0 || e.nest[n-1] >= 0 {
+		return e
+	}
+	i := ^e.nest[n-1]
+	e.nest = e.nest[:n-1]
+	if e.cbor {
+		e.buf = append(e.buf, cborBreak)
+	} else if i < len(e.buf) {
+		e.buf[i] = '['
+		e.buf = append(e.buf, ']')
+	} else {
+		e.buf = append(e.buf, '[', ']')
+	}
+	if n = len(e.nest); n != 0 && e.nest[n-1] == logfmtArray {
+		e.nest = e.nest[:n-1]
+		e.logfmt = true
+		e.logfmtQuote(i)
+	}
+	return e
+}
+
+// AppendStr adds s as an element to the array started by BeginArray.
+func (e *Entry) AppendStr(s string) *Entry {
+	if e == nil {
+		return nil
+	}
+
+	if e.cbor {
+		e.string(s)
+		return e
+	}
+
+	e.buf = append(e.buf, ',', '"')
+	e.string(s)
+	e.buf = append(e.buf, '"')
+	return e
+}
+
+// AppendInt adds i as an element to the array started by BeginArray.
+func (e *Entry) AppendInt(i int) *Entry {
+	if e == nil {
+		return nil
+	}
+
+	if e.cbor {
+		e.buf = cborAppendInt(e.buf, int64(i))
+		return e
+	}
+
+	e.buf = append(e.buf, ',')
+	e.buf = strconv.AppendInt(e.buf, int64(i), 10)
+	return e
+}
+
+// AppendInt64 adds i as an element to the array started by BeginArray.
+func (e *Entry) AppendInt64(i int64) *Entry {
+	if e == nil {
+		return nil
+	}
+
+	if e.cbor {
+		e.buf = cborAppendInt(e.buf, i)
+		return e
+	}
+
+	e.buf = append(e.buf, ',')
+	e.buf = strconv.AppendInt(e.buf, i, 10)
+	return e
+}
+
+// AppendUint64 adds i as an element to the array started by BeginArray.
+func (e *Entry) AppendUint64(i uint64) *Entry {
+	if e == nil {
+		return nil
+	}
+
+	if e.cbor {
+		e.buf = cborAppendUint(e.buf, i)
+		return e
+	}
+
+	e.buf = append(e.buf, ',')
+	e.buf = strconv.AppendUint(e.buf, i, 10)
+	return e
+}
+
+// AppendFloat64 adds f as an element to the array started by BeginArray.
+func (e *Entry) AppendFloat64(f float64) *Entry {
+	if e == nil {
+		return nil
+	}
+
+	if e.cbor {
+		e.buf = cborAppendFloat64(e.buf, f)
+		return e
+	}
+
+	e.buf = append(e.buf, ',')
+	e.buf = appendFloat(e.buf, f, 64)
+	return e
+}
+
+// AppendBool adds b as an element to the array started by BeginArray.
+func (e *Entry) AppendBool(b bool) *Entry {
+	if e == nil {
+		return nil
+	}
+
+	if e.cbor {
+		e.buf = cborAppendBool(e.buf, b)
+		return e
+	}
+
+	e.buf = append(e.buf, ',')
+	e.buf = strconv.AppendBool(e.buf, b)
+	return e
+}
+
+// AppendTime adds t as an element to the array started by BeginArray.
+func (e *Entry) AppendTime(t time.Time) *Entry {
+	if e == nil {
+		return nil
+	}
+
+	if e.cbor {
+		e.buf = cborAppendTime(e.buf, t.Unix(), int64(t.Nanosecond()))
+		return e
+	}
+
+	e.buf = append(e.buf, ',', '"')
+	e.buf = t.AppendFormat(e.buf, "2006-01-02T15:04:05.999Z07:00")
+	e.buf = append(e.buf, '"')
+	return e
+}
+
+// AppendObject adds obj as an element to the array started by BeginArray.
+func (e *Entry) AppendObject(obj ObjectMarshaler) *Entry {
+	if e == nil {
+		return nil
+	}
+
+	if e.cbor {
+		if obj == nil || (*[2]uintptr)(unsafe.Pointer(&obj))[1] == 0 {
+			e.buf = append(e.buf, cborNull)
+			return e
+		}
+		e.buf = append(e.buf, cborMap|cborIndefinite)
+		obj.MarshalObject(e)
+		e.buf = append(e.buf, cborBreak)
+		return e
+	}
+
+	if obj == nil || (*[2]uintptr)(unsafe.Pointer(&obj))[1] == 0 {
+		e.buf = append(e.buf, ",null"...)
+		return e
+	}
+	e.buf = append(e.buf, ',')
+	n := len(e.buf)
+	obj.MarshalObject(e)
+	if n < len(e.buf) {
+		e.buf[n] = '{'
+		e.buf = append(e.buf, '}')
+	} else {
+		e.buf = append(e.buf, '{', '}')
+	}
+	return e
+}
+
+// AppendNull adds null as an element to the array started by BeginArray.
+func (e *Entry) AppendNull() *Entry {
+	if e == nil {
+		return nil
+	}
+
+	if e.cbor {
+		e.buf = append(e.buf, cborNull)
+		return e
+	}
+
+	e.buf = append(e.buf, ",null"...)
+	return e
+}
+
+// ObjectsOf adds the field key with objects marshaled by their MarshalObject method,
+// it avoids the reflection of Entry.Objects.
+func ObjectsOf[T ObjectMarshaler](e *Entry, key string, objects []T) *Entry {
+	if e == nil {
+		return nil
+	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		ObjectsOf(e, key, objects)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(objects)))
+		for _, obj := range objects {
+			if o := ObjectMarshaler(obj); o == nil || (*[2]uintptr)(unsafe.Pointer(&o))[1] == 0 {
+				e.buf = append(e.buf, cborNull)
+				continue
+			}
+			e.buf = append(e.buf, cborMap|cborIndefinite)
+			e.marshalObject(obj)
+			e.buf = append(e.buf, cborBreak)
+		}
+		return e
+	}
+
+	e.buf = append(e.buf, '[')
+	for i, obj := range objects {
+		if i != 0 {
+			e.buf = append(e.buf, ',')
+		}
+		if o := ObjectMarshaler(obj); o == nil || (*[2]uintptr)(unsafe.Pointer(&o))[1] == 0 {
+			e.buf = append(e.buf, "null"...)
+			continue
+		}
+		n := len(e.buf)
+		e.marshalObject(obj)
+		if n < len(e.buf) {
+			e.buf[n] = '{'
+			e.buf = append(e.buf, '}')
+		} else {
+			e.buf = append(e.buf, '{', '}')
+		}
+	}
+	e.buf = append(e.buf, ']')
+	return e
+}
@@ -0,0 +1,74 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+type builderItem struct {
+	A int
+}
+
+func (o *builderItem) MarshalObject(e *Entry) {
+	e.Int("a", o.A)
+}
+
+func TestBuilder(t *testing.T) {
+	cases := []struct {
+		name   string
+		entry  func(e *Entry) *Entry
+		json   string
+		logfmt string
+	}{
+		{"object", func(e *Entry) *Entry {
+			return e.BeginObject("req").Str("method", "GET").BeginObject("user").Int("id", 1).EndObject().EndObject()
+		}, `"req":{"method":"GET","user":{"id":1}}`, `req.method=GET req.user.id=1`},
+		{"empty", func(e *Entry) *Entry { return e.BeginObject("a").EndObject().BeginArray("b").EndArray() },
+			`"a":{},"b":[]`, `b=[]`},
+		{"array", func(e *Entry) *Entry {
+			return e.BeginArray("v").AppendStr("a b").AppendInt(1).AppendInt64(-2).AppendUint64(3).
+				AppendFloat64(1.5).AppendBool(true).AppendNull().AppendTime(time.Date(2019, 7, 10, 5, 35, 54, 0, time.UTC)).EndArray()
+		}, `"v":["a b",1,-2,3,1.5,true,null,"2019-07-10T05:35:54Z"]`, `v="[\"a b\",1,-2,3,1.5,true,null,\"2019-07-10T05:35:54Z\"]"`},
+		{"nested", func(e *Entry) *Entry {
+			return e.BeginArray("rows").BeginObject("").Int("n", 1).BeginArray("tags").AppendStr("x").EndArray().EndObject().
+				AppendObject(&builderItem{2}).AppendObject((*builderItem)(nil)).EndArray()
+		}, `"rows":[{"n":1,"tags":["x"]},{"a":2},null]`, `rows="[{\"n\":1,\"tags\":[\"x\"]},{\"a\":2},null]"`},
+		{"unended", func(e *Entry) *Entry { return e.BeginObject("a").BeginArray("b").AppendInt(1) },
+			`"a":{"b":[1]}`, `a.b=[1]`},
+		{"objects-of", func(e *Entry) *Entry {
+			return ObjectsOf(e, "objs", []*builderItem{{1}, nil, {2}})
+		}, `"objs":[{"a":1},null,{"a":2}]`, `objs="[{\"a\":1},null,{\"a\":2}]"`},
+		{"objects-of-empty", func(e *Entry) *Entry { return ObjectsOf(e, "objs", []*builderItem{}) },
+			`"objs":[]`, `objs=[]`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for _, enc := range []Encoding{EncodingJSON, EncodingLogfmt, EncodingCBOR} {
+				var b bytes.Buffer
+				logger := testLogger(t, &b)
+				logger.Encoding = enc
+				c.entry(logger.Info()).Msg("hi")
+
+				var want string
+				got := b.Bytes()
+				switch enc {
+				case EncodingLogfmt:
+					want = "time=2019-07-10T05:35:54.277Z level=info " + c.logfmt + " message=hi\n"
+				case EncodingCBOR:
+					var err error
+					if got, err = CBORToJSON(nil, got); err != nil {
+						t.Fatalf("CBORToJSON: %v", err)
+					}
+					fallthrough
+				default:
+					want = `{"time":"2019-07-10T05:35:54.277Z","level":"info",` + c.json + `,"message":"hi"}` + "\n"
+				}
+				if string(got) != want {
+					t.Errorf("encoding %v: got %s\nwant %s", enc, got, want)
+				}
+			}
+		})
+	}
+}
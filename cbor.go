@@ -0,0 +1,435 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+// major types, simple values and tags of CBOR
+const (
+	cborUint   = 0 << 5
+	cborNegint = 1 << 5
+	cborBytes  = 2 << 5
+	cborText   = 3 << 5
+	cborArray  = 4 << 5
+	cborMap    = 5 << 5
+	cborTag    = 6 << 5
+	cborSimple = 7 << 5
+
+	cborIndefinite = 31
+	cborFalse      = cborSimple | 20
+	cborTrue       = cborSimple | 21
+	cborNull       = cborSimple | 22
+	cborFloat32    = cborSimple | 26
+	cborFloat64    = cborSimple | 27
+	cborBreak      = 0xff
+
+	cborTagTime    = 0
+	cborTagEpoch   = 1
+	cborTagMAC     = 48
+	cborTagIPv4    = 52
+	cborTagIPv6    = 54
+	cborTagTimeExt = 1001
+)
+
+// cborAppendHead appends the head of a data item of major type with argument n.
+func cborAppendHead(dst []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(dst, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(dst, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return append(dst, major|25, byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		return append(dst, major|26, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(dst, major|27, byte(n>>56), byte(n>>48), byte(n>>40), byte(n>>32),
+		byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+func cborAppendInt(dst []byte, i int64) []byte {
+	if i < 0 {
+		return cborAppendHead(dst, cborNegint, uint64(^i))
+	}
+	return cborAppendHead(dst, cborUint, uint64(i))
+}
+
+func cborAppendUint(dst []byte, i uint64) []byte {
+	return cborAppendHead(dst, cborUint, i)
+}
+
+func cborAppendText(dst []byte, s string) []byte {
+	dst = cborAppendHead(dst, cborText, uint64(len(s)))
+	return append(dst, s...)
+}
+
+func cborAppendBytes(dst []byte, b []byte) []byte {
+	dst = cborAppendHead(dst, cborBytes, uint64(len(b)))
+	return append(dst, b...)
+}
+
+func cborAppendBool(dst []byte, b bool) []byte {
+	if b {
+		return append(dst, cborTrue)
+	}
+	return append(dst, cborFalse)
+}
+
+func cborAppendFloat64(dst []byte, f float64) []byte {
+	n := math.Float64bits(f)
+	return append(dst, cborFloat64, byte(n>>56), byte(n>>48), byte(n>>40), byte(n>>32),
+		byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+func cborAppendFloat32(dst []byte, f float32) []byte {
+	n := math.Float32bits(f)
+	return append(dst, cborFloat32, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+// cborAppendTime appends the time of sec and nsec as an epoch time of tag 1, an integer if
+// nsec is zero or a float if nsec is whole microseconds, which float64 keeps exact until
+// 2106. Other times are extended times of tag 1001, maps of seconds and nanoseconds.
+func cborAppendTime(dst []byte, sec int64, nsec int64) []byte {
+	switch {
+	case nsec == 0:
+		return cborAppendInt(append(dst, cborTag|cborTagEpoch), sec)
+	case nsec%1000 == 0 && sec >= -1<<32 && sec < 1<<32:
+		return cborAppendFloat64(append(dst, cborTag|cborTagEpoch), float64(sec)+float64(nsec)/1e9)
+	}
+	dst = cborAppendHead(dst, cborTag, cborTagTimeExt)
+	// {1: sec, -9: nsec}
+	dst = append(dst, cborMap|2, cborUint|1)
+	dst = cborAppendInt(dst, sec)
+	dst = append(dst, cborNegint|8)
+	return cborAppendInt(dst, nsec)
+}
+
+// cborAppendTimeFormat appends t like TimeFormat, times of formats other than the Unix ones
+// are times of tag 1 or 1001, see cborAppendTime.
+func cborAppendTimeFormat(dst []byte, timefmt string, t time.Time) []byte {
+	switch timefmt {
+	case TimeFormatUnix:
+		return cborAppendInt(dst, t.Unix())
+	case TimeFormatUnixMs:
+		return cborAppendInt(dst, t.UnixNano()/1000000)
+	case TimeFormatUnixWithMs:
+		return cborAppendFloat64(dst, float64(t.Unix())+float64(t.UnixNano()/1000000%1000)/1e3)
+	case TimeFormatUnixMicro:
+		return cborAppendInt(dst, t.UnixMicro())
+	case TimeFormatUnixNano:
+		return cborAppendInt(dst, t.UnixNano())
+	}
+	return cborAppendTime(dst, t.Unix(), int64(t.Nanosecond()))
+}
+
+// cborAppendDur appends d in milliseconds like Dur, as an integer if d has no fraction.
+func cborAppendDur(dst []byte, d time.Duration) []byte {
+	if d%time.Millisecond == 0 {
+		return cborAppendInt(dst, int64(d/time.Millisecond))
+	}
+	return cborAppendFloat64(dst, float64(d)/float64(time.Millisecond))
+}
+
+// cborAppendAddr appends ip as a byte string of tag 52 or 54, addresses with zones as text.
+func cborAppendAddr(dst []byte, ip netip.Addr) []byte {
+	switch {
+	case !ip.IsValid() || ip.Zone() != "":
+		return cborAppendText(dst, ip.String())
+	case ip.Is4():
+		a := ip.As4()
+		dst = append(dst, cborTag|24, cborTagIPv4)
+		return cborAppendBytes(dst, a[:])
+	}
+	a := ip.As16()
+	dst = append(dst, cborTag|24, cborTagIPv6)
+	return cborAppendBytes(dst, a[:])
+}
+
+// cborAppendPrefix appends pfx as an array of its length and address bytes without trailing
+// zeros of tag 52 or 54, prefixes with zones as text.
+func cborAppendPrefix(dst []byte, pfx netip.Prefix) []byte {
+	if !pfx.IsValid() || pfx.Addr().Zone() != "" {
+		return cborAppendText(dst, pfx.String())
+	}
+	pfx = pfx.Masked()
+	var b []byte
+	if pfx.Addr().Is4() {
+		a := pfx.Addr().As4()
+		b = a[:]
+		dst = append(dst, cborTag|24, cborTagIPv4)
+	} else {
+		a := pfx.Addr().As16()
+		b = a[:]
+		dst = append(dst, cborTag|24, cborTagIPv6)
+	}
+	for len(b) != 0 && b[len(b)-1] == 0 {
+		b = b[:len(b)-1]
+	}
+	dst = append(dst, cborArray|2)
+	dst = cborAppendUint(dst, uint64(pfx.Bits()))
+	return cborAppendBytes(dst, b)
+}
+
+// cborAppendIP appends ip like IPAddr, IPv4 addresses in IPv6 form as IPv4 addresses.
+func cborAppendIP(dst []byte, ip net.IP) []byte {
+	a, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return cborAppendText(dst, "")
+	}
+	return cborAppendAddr(dst, a.Unmap())
+}
+
+// cborAppendIPNet appends pfx like IPPrefix.
+func cborAppendIPNet(dst []byte, pfx net.IPNet) []byte {
+	a, ok := netip.AddrFromSlice(pfx.IP)
+	ones, bits := pfx.Mask.Size()
+	if bits == 32 {
+		a = a.Unmap()
+	}
+	if !ok || bits == 0 || a.BitLen() != bits {
+		return cborAppendText(dst, pfx.String())
+	}
+	return cborAppendPrefix(dst, netip.PrefixFrom(a, ones))
+}
+
+func cborAppendMAC(dst []byte, ha net.HardwareAddr) []byte {
+	dst = append(dst, cborTag|24, cborTagMAC)
+	return cborAppendBytes(dst, ha)
+}
+
+// cborAppendLevel appends the level field of level like appendLevel.
+func (s *Schema) cborAppendLevel(dst []byte, level Level) []byte {
+	if level == noLevel {
+		return dst
+	}
+	dst = cborAppendText(dst, s.levelKey())
+	var style LevelStyle
+	if s != nil {
+		style = s.LevelStyle
+	}
+	switch style {
+	case LevelStyleNumeric:
+		return cborAppendUint(dst, uint64(level))
+	case LevelStyleSyslog:
+		return cborAppendInt(dst, int64(level.syslog()))
+	case LevelStyleGCP:
+		return cborAppendText(dst, gcpSeverities[level.syslog()])
+	case LevelStyleUpper:
+		name := level.String()
+		dst = cborAppendText(dst, name)
+		for i := len(dst) - len(name); i < len(dst); i++ {
+			if 'a' <= dst[i] && dst[i] <= 'z' {
+				dst[i] -= 'a' - 'A'
+			}
+		}
+		return dst
+	}
+	return cborAppendText(dst, level.String())
+}
+
+// cborHeader starts the CBOR map of e with the time, level and contexts of l, see header.
+// Times of formats other than the Unix ones are written by cborAppendTime.
+func (l *Logger) cborHeader(e *Entry, level Level) {
+	e.cbor = true
+	e.buf = append(e.buf, cborMap|cborIndefinite)
+	e.buf = cborAppendText(e.buf, l.timeField())
+	sec, nsec, _ := now()
+	switch l.TimeFormat {
+	case TimeFormatUnix:
+		e.buf = cborAppendInt(e.buf, sec)
+	case TimeFormatUnixMs:
+		e.buf = cborAppendInt(e.buf, sec*1000+int64(nsec)/1000000)
+	case TimeFormatUnixWithMs:
+		e.buf = cborAppendFloat64(e.buf, float64(sec)+float64(nsec/1000000)/1e3)
+	case TimeFormatUnixMicro:
+		e.buf = cborAppendInt(e.buf, sec*1000000+int64(nsec)/1000)
+	case TimeFormatUnixNano:
+		e.buf = cborAppendInt(e.buf, sec*1000000000+int64(nsec))
+	case "":
+		e.buf = cborAppendTime(e.buf, sec, int64(nsec/1000000*1000000))
+	case TimeFormatRFC3339Micro:
+		e.buf = cborAppendTime(e.buf, sec, int64(nsec/1000*1000))
+	default:
+		e.buf = cborAppendTime(e.buf, sec, int64(nsec))
+	}
+	e.levelAt = len(e.buf)
+	e.buf = l.Schema.cborAppendLevel(e.buf, level)
+	if l.Schema != nil {
+		e.buf = cborAppendFields(e.buf, l.Schema.Context)
+	}
+	if l.Context != nil {
+		e.appendContext(l)
+	}
+}
+
+// cborInterface appends i marshaled by encoding/json like Interface.
+func (e *Entry) cborInterface(i any) {
+	b := bbpool.Get().(*bb)
+	b.B = b.B[:0]
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(i); err != nil {
+		b.B = b.B[:0]
+		fmt.Fprintf(b, `marshaling error: %+v`, err)
+		e.string(b2s(b.B))
+	} else {
+		e.buf, _ = cborAppendJSON(e.buf, b.B, 0)
+	}
+	if cap(b.B) <= bbcap {
+		bbpool.Put(b)
+	}
+}
+
+// cborAppendFields appends the JSON fields of ctx, e.g. `,"a":1,"b":"c"`, as CBOR map members.
+func cborAppendFields(dst []byte, ctx []byte) []byte {
+	for i := 0; i < len(ctx); i++ {
+		if ctx[i] != '"' {
+			continue
+		}
+		j, key, esc, ok := jsonParseString(ctx, i+1)
+		if !ok {
+			break
+		}
+		dst = cborAppendJSONString(dst, key, esc)
+		j = skipSpaces(ctx, j)
+		if j < len(ctx) && ctx[j] == ':' {
+			j++
+		}
+		dst, i = cborAppendJSON(dst, ctx, j)
+		i--
+	}
+	return dst
+}
+
+// cborAppendJSON appends the JSON value of json at i as CBOR, and returns the index after it.
+func cborAppendJSON(dst []byte, json []byte, i int) ([]byte, int) {
+	i = skipSpaces(json, i)
+	if i >= len(json) {
+		return append(dst, cborNull), i
+	}
+	switch json[i] {
+	case '{':
+		dst = append(dst, cborMap|cborIndefinite)
+		for i++; i < len(json); {
+			switch json[i] {
+			case '}':
+				return append(dst, cborBreak), i + 1
+			case '"':
+			default:
+				i++
+				continue
+			}
+			j, key, esc, ok := jsonParseString(json, i+1)
+			if !ok {
+				break
+			}
+			dst = cborAppendJSONString(dst, key, esc)
+			j = skipSpaces(json, j)
+			if j < len(json) && json[j] == ':' {
+				j++
+			}
+			dst, i = cborAppendJSON(dst, json, j)
+		}
+		return append(dst, cborBreak), len(json)
+	case '[':
+		dst = append(dst, cborArray|cborIndefinite)
+		for i++; i < len(json); {
+			switch json[i] {
+			case ']':
+				return append(dst, cborBreak), i + 1
+			case ',', ' ', '\t', '\r', '\n':
+				i++
+				continue
+			}
+			dst, i = cborAppendJSON(dst, json, i)
+		}
+		return append(dst, cborBreak), len(json)
+	case '"':
+		j, str, esc, _ := jsonParseString(json, i+1)
+		return cborAppendJSONString(dst, str, esc), j
+	}
+
+	j, typ, val, ok := jsonParseAny(json, i, true)
+	if !ok {
+		return append(dst, cborNull), j
+	}
+	switch typ {
+	case 't':
+		return append(dst, cborTrue), j
+	case 'f':
+		return append(dst, cborFalse), j
+	case 'n':
+		if n, err := strconv.ParseInt(b2s(val), 10, 64); err == nil {
+			return cborAppendInt(dst, n), j
+		}
+		if n, err := strconv.ParseUint(b2s(val), 10, 64); err == nil {
+			return cborAppendUint(dst, n), j
+		}
+		if f, err := strconv.ParseFloat(b2s(val), 64); err == nil {
+			return cborAppendFloat64(dst, f), j
+		}
+		return cborAppendText(dst, b2s(val)), j
+	}
+	return append(dst, cborNull), j
+}
+
+// cborAppendJSONString appends the quoted JSON string str as CBOR text, unescaped if esc.
+func cborAppendJSONString(dst []byte, str []byte, esc bool) []byte {
+	if len(str) < 2 {
+		return cborAppendText(dst, "")
+	}
+	str = str[1 : len(str)-1]
+	if !esc {
+		return cborAppendText(dst, b2s(str))
+	}
+	b := bbpool.Get().(*bb)
+	b.B = jsonUnescape(str, b.B[:0])
+	dst = cborAppendText(dst, b2s(b.B))
+	if cap(b.B) <= bbcap {
+		bbpool.Put(b)
+	}
+	return dst
+}
+
+// cborCaller adds the caller fields of file, line and function name like caller.
+func (e *Entry) cborCaller(file string, line int, name string) {
+	var tmp [20]byte
+	num := strconv.AppendInt(tmp[:0], int64(line), 10)
+	s := e.schema()
+	switch {
+	case s != nil && s.SourceLocationKey != "":
+		e.buf = append(cborAppendText(e.buf, s.SourceLocationKey), cborMap|3)
+		e.buf = cborAppendText(cborAppendText(e.buf, "file"), file)
+		e.buf = cborAppendText(cborAppendText(e.buf, "line"), b2s(num))
+		e.buf = cborAppendText(cborAppendText(e.buf, "function"), name)
+	case s != nil && s.CallerLineKey != "":
+		e.buf = cborAppendText(cborAppendText(e.buf, s.callerKey()), file)
+		e.buf = cborAppendInt(cborAppendText(e.buf, s.CallerLineKey), int64(line))
+		e.buf = cborAppendText(cborAppendText(e.buf, s.callerFuncKey()), name)
+	default:
+		e.buf = cborAppendText(e.buf, s.callerKey())
+		e.buf = cborAppendHead(e.buf, cborText, uint64(len(file)+1+len(num)))
+		e.buf = append(e.buf, file...)
+		e.buf = append(e.buf, ':')
+		e.buf = append(e.buf, num...)
+		e.buf = cborAppendText(cborAppendText(e.buf, s.callerFuncKey()), name)
+	}
+	e.buf = cborAppendInt(cborAppendText(e.buf, s.goidKey()), int64(goid()))
+}
+
+// transcode converts the JSON entry to CBOR, e.g. the entries of the handler of Slog.
+func (e *Entry) transcode() {
+	b := bbpool.Get().(*bb)
+	b.B, _ = cborAppendJSON(b.B[:0], e.buf, 0)
+	e.buf, b.B = b.B, e.buf
+	e.cbor = true
+	if cap(b.B) <= bbcap {
+		bbpool.Put(b)
+	}
+}
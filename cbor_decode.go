@@ -0,0 +1,387 @@
+package log
+
+import (
+	"errors"
+	"math"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+// maxCBORDepth limits the nesting of decoded CBOR data items.
+const maxCBORDepth = 64
+
+var (
+	errCBORTruncated = errors.New("unexpected end of CBOR data")
+	errCBORInvalid   = errors.New("invalid CBOR data")
+	errCBORDepth     = errors.New("CBOR data nested too deeply")
+)
+
+// CBORToJSON appends the JSON of the CBOR data items of src to dst, one line per item, e.g.
+// to read the entries written with EncodingCBOR. Byte strings are decoded as strings like
+// Bytes of JSON entries, times of tag 0 as their RFC3339 strings, epoch times of tag 1 and
+// extended times of tag 1001 as RFC3339 strings in UTC, and addresses of tags 48, 52 and 54
+// as their text forms. Other tags are ignored.
+func CBORToJSON(dst, src []byte) ([]byte, error) {
+	var err error
+	for i := 0; i < len(src); {
+		dst, i, err = cborToJSON(dst, src, i, 0)
+		if err != nil {
+			return dst, err
+		}
+		dst = append(dst, '\n')
+	}
+	return dst, nil
+}
+
+// cborHead reads the head of the data item of src at i. It returns the major type, the
+// additional information, the argument and the index after the head.
+func cborHead(src []byte, i int) (major, info byte, arg uint64, j int, err error) {
+	if i >= len(src) {
+		return 0, 0, 0, i, errCBORTruncated
+	}
+	major, info = src[i]&0xe0, src[i]&0x1f
+	i++
+	var n int
+	switch {
+	case info < 24:
+		return major, info, uint64(info), i, nil
+	case info == 24:
+		n = 1
+	case info == 25:
+		n = 2
+	case info == 26:
+		n = 4
+	case info == 27:
+		n = 8
+	case info == cborIndefinite:
+		if major == cborUint || major == cborNegint || major == cborTag {
+			return 0, 0, 0, i, errCBORInvalid
+		}
+		return major, info, 0, i, nil
+	default:
+		return 0, 0, 0, i, errCBORInvalid
+	}
+	if len(src)-i < n {
+		return 0, 0, 0, len(src), errCBORTruncated
+	}
+	for _, c := range src[i : i+n] {
+		arg = arg<<8 | uint64(c)
+	}
+	return major, info, arg, i + n, nil
+}
+
+// cborString returns the content of the definite byte or text string of src at i.
+func cborString(src []byte, i int) (major byte, str []byte, j int, err error) {
+	major, info, arg, j, err := cborHead(src, i)
+	if err != nil {
+		return
+	}
+	if major != cborBytes && major != cborText || info == cborIndefinite {
+		return major, nil, j, errCBORInvalid
+	}
+	if arg > uint64(len(src)-j) {
+		return major, nil, len(src), errCBORTruncated
+	}
+	return major, src[j : j+int(arg)], j + int(arg), nil
+}
+
+// cborSkip returns the index after the data item of src at i.
+func cborSkip(src []byte, i int, depth int) (int, error) {
+	if depth > maxCBORDepth {
+		return i, errCBORDepth
+	}
+	major, info, arg, j, err := cborHead(src, i)
+	if err != nil {
+		return j, err
+	}
+	switch major {
+	case cborBytes, cborText:
+		if info == cborIndefinite {
+			return cborSkipItems(src, j, depth)
+		}
+		if arg > uint64(len(src)-j) {
+			return len(src), errCBORTruncated
+		}
+		return j + int(arg), nil
+	case cborArray, cborMap:
+		if info == cborIndefinite {
+			return cborSkipItems(src, j, depth)
+		}
+		if major == cborMap {
+			arg *= 2
+		}
+		for ; arg != 0; arg-- {
+			if j, err = cborSkip(src, j, depth+1); err != nil {
+				return j, err
+			}
+		}
+		return j, nil
+	case cborTag:
+		return cborSkip(src, j, depth+1)
+	case cborSimple:
+		if info == cborIndefinite {
+			return j, errCBORInvalid
+		}
+	}
+	return j, nil
+}
+
+// cborSkipItems returns the index after the break of the items of src at i.
+func cborSkipItems(src []byte, i int, depth int) (int, error) {
+	var err error
+	for i < len(src) && src[i] != cborBreak {
+		if i, err = cborSkip(src, i, depth+1); err != nil {
+			return i, err
+		}
+	}
+	if i >= len(src) {
+		return i, errCBORTruncated
+	}
+	return i + 1, nil
+}
+
+// cborToJSON appends the JSON of the data item of src at i to dst, and returns the index after it.
+func cborToJSON(dst, src []byte, i int, depth int) ([]byte, int, error) {
+	if depth > maxCBORDepth {
+		return dst, i, errCBORDepth
+	}
+	major, info, arg, j, err := cborHead(src, i)
+	if err != nil {
+		return dst, j, err
+	}
+	switch major {
+	case cborUint:
+		return strconv.AppendUint(dst, arg, 10), j, nil
+	case cborNegint:
+		if arg <= math.MaxInt64 {
+			return strconv.AppendInt(dst, -1-int64(arg), 10), j, nil
+		}
+		if arg == math.MaxUint64 {
+			return append(dst, "-18446744073709551616"...), j, nil
+		}
+		dst = append(dst, '-')
+		return strconv.AppendUint(dst, arg+1, 10), j, nil
+	case cborBytes, cborText:
+		e := Entry{buf: append(dst, '"')}
+		if info != cborIndefinite {
+			_, str, j, err := cborString(src, i)
+			e.bytes(str)
+			return append(e.buf, '"'), j, err
+		}
+		for j < len(src) && src[j] != cborBreak {
+			var str []byte
+			if _, str, j, err = cborString(src, j); err != nil {
+				return e.buf, j, err
+			}
+			e.bytes(str)
+		}
+		if j >= len(src) {
+			return e.buf, j, errCBORTruncated
+		}
+		return append(e.buf, '"'), j + 1, nil
+	case cborArray:
+		dst = append(dst, '[')
+		for n := uint64(0); ; n++ {
+			if info == cborIndefinite && j < len(src) && src[j] == cborBreak {
+				j++
+				break
+			} else if info != cborIndefinite && n == arg {
+				break
+			}
+			if n != 0 {
+				dst = append(dst, ',')
+			}
+			if dst, j, err = cborToJSON(dst, src, j, depth+1); err != nil {
+				return dst, j, err
+			}
+		}
+		return append(dst, ']'), j, nil
+	case cborMap:
+		dst = append(dst, '{')
+		for n := uint64(0); ; n++ {
+			if info == cborIndefinite && j < len(src) && src[j] == cborBreak {
+				j++
+				break
+			} else if info != cborIndefinite && n == arg {
+				break
+			}
+			if n != 0 {
+				dst = append(dst, ',')
+			}
+			if dst, j, err = cborKeyToJSON(dst, src, j, depth+1); err != nil {
+				return dst, j, err
+			}
+			dst = append(dst, ':')
+			if dst, j, err = cborToJSON(dst, src, j, depth+1); err != nil {
+				return dst, j, err
+			}
+		}
+		return append(dst, '}'), j, nil
+	case cborTag:
+		return cborTagToJSON(dst, src, arg, j, depth)
+	}
+
+	switch info {
+	case 20:
+		return append(dst, "false"...), j, nil
+	case 21:
+		return append(dst, "true"...), j, nil
+	case 25:
+		return appendFloat(dst, cborHalf(uint16(arg)), 32), j, nil
+	case 26:
+		return appendFloat(dst, float64(math.Float32frombits(uint32(arg))), 32), j, nil
+	case 27:
+		return appendFloat(dst, math.Float64frombits(arg), 64), j, nil
+	case cborIndefinite:
+		return dst, j, errCBORInvalid
+	}
+	return append(dst, "null"...), j, nil
+}
+
+// cborKeyToJSON appends the map key of src at i to dst as a JSON string.
+func cborKeyToJSON(dst, src []byte, i int, depth int) ([]byte, int, error) {
+	if i < len(src) && src[i]&0xe0 == cborText {
+		return cborToJSON(dst, src, i, depth)
+	}
+	b := bbpool.Get().(*bb)
+	defer bbpool.Put(b)
+	var err error
+	b.B, i, err = cborToJSON(b.B[:0], src, i, depth)
+	e := Entry{buf: append(dst, '"')}
+	e.bytes(b.B)
+	return append(e.buf, '"'), i, err
+}
+
+// cborTagToJSON appends the JSON of the content of tag of src at i to dst.
+func cborTagToJSON(dst, src []byte, tag uint64, i int, depth int) ([]byte, int, error) {
+	major, info, arg, j, err := cborHead(src, i)
+	if err != nil {
+		return dst, j, err
+	}
+	switch {
+	case tag == cborTagEpoch && (major == cborUint || major == cborNegint):
+		sec := int64(arg)
+		if major == cborNegint {
+			sec = -1 - sec
+		}
+		dst = append(dst, '"')
+		dst = time.Unix(sec, 0).UTC().AppendFormat(dst, time.RFC3339)
+		return append(dst, '"'), j, nil
+	case tag == cborTagEpoch && major == cborSimple && (info == 26 || info == 27):
+		f := math.Float64frombits(arg)
+		if info == 26 {
+			f = float64(math.Float32frombits(uint32(arg)))
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			break
+		}
+		sec := math.Floor(f)
+		nsec := math.Round((f-sec)*1e6) * 1e3
+		dst = append(dst, '"')
+		dst = time.Unix(int64(sec), int64(nsec)).UTC().AppendFormat(dst, "2006-01-02T15:04:05.999999Z07:00")
+		return append(dst, '"'), j, nil
+	case tag == cborTagTimeExt && major == cborMap && info != cborIndefinite:
+		if t, k, ok := cborTimeExt(src, j, arg); ok {
+			dst = append(dst, '"')
+			dst = t.UTC().AppendFormat(dst, time.RFC3339Nano)
+			return append(dst, '"'), k, nil
+		}
+	case tag == cborTagMAC && major == cborBytes && info != cborIndefinite:
+		_, b, j, err := cborString(src, i)
+		dst = append(dst, '"')
+		for k, c := range b {
+			if k > 0 {
+				dst = append(dst, ':')
+			}
+			dst = append(dst, hex[c>>4], hex[c&0x0f])
+		}
+		return append(dst, '"'), j, err
+	case (tag == cborTagIPv4 || tag == cborTagIPv6) && major == cborBytes && info != cborIndefinite:
+		_, b, j, err := cborString(src, i)
+		if ip, ok := netip.AddrFromSlice(b); ok && err == nil {
+			dst = append(dst, '"')
+			dst = ip.AppendTo(dst)
+			return append(dst, '"'), j, nil
+		}
+	case (tag == cborTagIPv4 || tag == cborTagIPv6) && major == cborArray && arg == 2:
+		max := uint64(32)
+		if tag == cborTagIPv6 {
+			max = 128
+		}
+		m, _, bits, k, err := cborHead(src, j)
+		if err != nil || m != cborUint || bits > max {
+			break
+		}
+		_, b, k, err := cborString(src, k)
+		if err != nil || uint64(len(b)) > max/8 {
+			break
+		}
+		var a [16]byte
+		copy(a[:], b)
+		ip := netip.AddrFrom16(a)
+		if tag == cborTagIPv4 {
+			ip = netip.AddrFrom4([4]byte(a[:4]))
+		}
+		dst = append(dst, '"')
+		dst = netip.PrefixFrom(ip, int(bits)).AppendTo(dst)
+		return append(dst, '"'), k, nil
+	}
+	return cborToJSON(dst, src, i, depth+1)
+}
+
+// cborTimeExt returns the extended time of tag 1001 of the map of n pairs of src at i and
+// the index after it. It reports false for keys other than the seconds and their fractions.
+func cborTimeExt(src []byte, i int, n uint64) (t time.Time, j int, ok bool) {
+	var sec, nsec int64
+	for ; n > 0; n-- {
+		major, _, key, k, err := cborHead(src, i)
+		if err != nil || major != cborUint && major != cborNegint {
+			return t, i, false
+		}
+		m, _, arg, k, err := cborHead(src, k)
+		if err != nil || m != cborUint && m != cborNegint {
+			return t, i, false
+		}
+		v := int64(arg)
+		if m == cborNegint {
+			v = -1 - v
+		}
+		switch {
+		case major == cborUint && key == 1:
+			sec = v
+		case major == cborNegint && key == 2:
+			nsec = v * 1000000
+		case major == cborNegint && key == 5:
+			nsec = v * 1000
+		case major == cborNegint && key == 8:
+			nsec = v
+		default:
+			return t, i, false
+		}
+		i = k
+	}
+	return time.Unix(sec, nsec), i, true
+}
+
+// cborHalf converts the half-precision float of h to float64.
+func cborHalf(h uint16) float64 {
+	exp, mant := int(h>>10&0x1f), float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
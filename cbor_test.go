@@ -0,0 +1,210 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCBORToJSONEntries(t *testing.T) {
+	cases := []struct {
+		name   string
+		fields func(e *Entry) *Entry
+	}{
+		{"strings", func(e *Entry) *Entry {
+			return e.Str("s", "a \"quoted\"\n\ttab").Str("u", "héllo ✓").Strs("ss", []string{"x", ""})
+		}},
+		{"numbers", func(e *Entry) *Entry {
+			return e.Int("i", -42).Int64("min", math.MinInt64).Uint64("max", math.MaxUint64).Float64("f", 1.5).Float32("f32", 0.25)
+		}},
+		{"bools", func(e *Entry) *Entry { return e.Bool("t", true).Bool("f", false).Bools("bs", []bool{true, false}) }},
+		{"dur", func(e *Entry) *Entry { return e.Dur("d", 1500*time.Millisecond).Dur("neg", -time.Second) }},
+		{"addrs", func(e *Entry) *Entry {
+			mac, _ := net.ParseMAC("00:1a:2b:3c:4d:5e")
+			return e.IPAddr("v4", net.IPv4(10, 0, 0, 1)).IPAddr("v6", net.ParseIP("2001:db8::1")).MACAddr("mac", mac)
+		}},
+		{"nested", func(e *Entry) *Entry {
+			return e.Dict("req", NewContext(nil).Str("method", "GET").Ints("codes", []int{200, 404}).Value()).
+				RawJSON("raw", []byte(`{"a":[1,{"b":null}],"c":"d"}`))
+		}},
+		{"error", func(e *Entry) *Entry { return e.Err(errors.New("refused")).AnErr("cause", errors.New("eof")) }},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var want, got bytes.Buffer
+			logger := testLogger(t, &want)
+			c.fields(logger.Info()).Msg("hello")
+
+			logger.Writer = IOWriter{&got}
+			logger.Encoding = EncodingCBOR
+			c.fields(logger.Info()).Msg("hello")
+
+			json, err := CBORToJSON(nil, got.Bytes())
+			if err != nil {
+				t.Fatalf("CBORToJSON() error = %v", err)
+			}
+			if string(json) != want.String() {
+				t.Errorf("CBORToJSON() = %s\nwant           %s", json, want.String())
+			}
+		})
+	}
+}
+
+func TestCBORTime(t *testing.T) {
+	at := time.Date(2019, 7, 10, 5, 35, 54, 123456789, time.UTC)
+	cases := []struct {
+		name   string
+		format string
+		fields func(e *Entry) *Entry
+		want   string
+	}{
+		{
+			name:   "nanos",
+			fields: func(e *Entry) *Entry { return e.Time("at", at) },
+			want:   `{"time":"2019-07-10T05:35:54.277Z","level":"info","at":"2019-07-10T05:35:54.123456789Z","message":"hello"}`,
+		},
+		{
+			name:   "micros",
+			fields: func(e *Entry) *Entry { return e.Time("at", at.Truncate(time.Microsecond)) },
+			want:   `{"time":"2019-07-10T05:35:54.277Z","level":"info","at":"2019-07-10T05:35:54.123456Z","message":"hello"}`,
+		},
+		{
+			name:   "seconds",
+			fields: func(e *Entry) *Entry { return e.Time("at", at.Truncate(time.Second)) },
+			want:   `{"time":"2019-07-10T05:35:54.277Z","level":"info","at":"2019-07-10T05:35:54Z","message":"hello"}`,
+		},
+		{
+			name:   "zone",
+			fields: func(e *Entry) *Entry { return e.Time("at", at.In(time.FixedZone("", 3600))) },
+			want:   `{"time":"2019-07-10T05:35:54.277Z","level":"info","at":"2019-07-10T05:35:54.123456789Z","message":"hello"}`,
+		},
+		{
+			name:   "layout",
+			format: time.RFC3339,
+			fields: func(e *Entry) *Entry { return e.TimeFormat("at", time.Kitchen, at) },
+			want:   `{"time":"2019-07-10T05:35:54.277Z","level":"info","at":"2019-07-10T05:35:54.123456789Z","message":"hello"}`,
+		},
+		{
+			name:   "unix-nano",
+			format: TimeFormatUnixNano,
+			fields: func(e *Entry) *Entry { return e.TimeFormat("at", TimeFormatUnixNano, at) },
+			want:   `{"time":1562736954277000000,"level":"info","at":1562736954123456789,"message":"hello"}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			logger.Encoding = EncodingCBOR
+			logger.TimeFormat = c.format
+			c.fields(logger.Info()).Msg("hello")
+
+			json, err := CBORToJSON(nil, b.Bytes())
+			if err != nil {
+				t.Fatalf("CBORToJSON() error = %v", err)
+			}
+			if string(json) != c.want+"\n" {
+				t.Errorf("CBORToJSON() = %s\nwant           %s", json, c.want)
+			}
+		})
+	}
+}
+
+func TestCBORTimeRoundTrip(t *testing.T) {
+	cases := []time.Time{
+		time.Date(2019, 7, 10, 5, 35, 54, 0, time.UTC),
+		time.Date(2019, 7, 10, 5, 35, 54, 1, time.UTC),
+		time.Date(2019, 7, 10, 5, 35, 54, 123456000, time.UTC),
+		time.Date(2019, 7, 10, 5, 35, 54, 123456789, time.UTC),
+		time.Date(1969, 12, 31, 23, 59, 59, 500000000, time.UTC),
+		time.Date(2262, 4, 11, 23, 47, 16, 854775807, time.UTC),
+		time.Date(1969, 12, 31, 23, 59, 59, 999999999, time.UTC),
+		time.Date(1900, 1, 1, 0, 0, 0, 500, time.FixedZone("", 3600)),
+	}
+
+	for _, want := range cases {
+		t.Run(want.Format(time.RFC3339Nano), func(t *testing.T) {
+			json, err := CBORToJSON(nil, cborAppendTime(nil, want.Unix(), int64(want.Nanosecond())))
+			if err != nil {
+				t.Fatalf("CBORToJSON() error = %v", err)
+			}
+			got, err := time.Parse(`"`+time.RFC3339Nano+`"`+"\n", string(json))
+			if err != nil {
+				t.Fatalf("time.Parse(%s) error = %v", json, err)
+			}
+			if !got.Equal(want) {
+				t.Errorf("decoded %s, want %s", got.Format(time.RFC3339Nano), want.Format(time.RFC3339Nano))
+			}
+		})
+	}
+}
+
+func TestCBORAppendTime(t *testing.T) {
+	cases := []struct {
+		name string
+		nsec int64
+		want []byte
+	}{
+		{"seconds", 0, []byte{0xc1, 0x1a, 0x5d, 0x25, 0x79, 0x3a}},
+		{"millis", 277000000, cborAppendFloat64([]byte{0xc1}, 1562736954.277)},
+		{"nanos", 123456789, []byte{0xd9, 0x03, 0xe9, 0xa2, 0x01, 0x1a, 0x5d, 0x25, 0x79, 0x3a, 0x28, 0x1a, 0x07, 0x5b, 0xcd, 0x15}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := cborAppendTime(nil, 1562736954, c.nsec); !bytes.Equal(got, c.want) {
+				t.Errorf("cborAppendTime() = % x, want % x", got, c.want)
+			}
+		})
+	}
+}
+
+func TestCBORToJSON(t *testing.T) {
+	cases := []struct {
+		name string
+		cbor []byte
+		want string
+		err  error
+	}{
+		{"uint", []byte{0x18, 0x64}, "100", nil},
+		{"negint", []byte{0x38, 0x63}, "-100", nil},
+		{"half", []byte{0xf9, 0x3e, 0x00}, "1.5", nil},
+		{"text", []byte{0x62, 'h', 'i'}, `"hi"`, nil},
+		{"bytes", []byte{0x42, 'h', '"'}, `"h\""`, nil},
+		{"definite-map", []byte{0xa1, 0x61, 'a', 0x82, 0x01, 0xf6}, `{"a":[1,null]}`, nil},
+		{"indefinite-map", []byte{0xbf, 0x61, 'a', 0x9f, 0xf5, 0xf4, 0xff, 0xff}, `{"a":[true,false]}`, nil},
+		{"uint-key", []byte{0xa1, 0x01, 0x02}, `{"1":2}`, nil},
+		{"epoch", []byte{0xc1, 0x1a, 0x5d, 0x25, 0x79, 0x3a}, `"2019-07-10T05:35:54Z"`, nil},
+		{"epoch-float", []byte{0xc1, 0xfb, 0x41, 0xd7, 0x49, 0x5e, 0x4e, 0x90, 0x00, 0x00}, `"2019-07-10T05:35:54.25Z"`, nil},
+		{"time-ext", []byte{0xd9, 0x03, 0xe9, 0xa2, 0x01, 0x1a, 0x5d, 0x25, 0x79, 0x3a, 0x28, 0x1a, 0x07, 0x5b, 0xcd, 0x15}, `"2019-07-10T05:35:54.123456789Z"`, nil},
+		{"time-ext-millis", []byte{0xd9, 0x03, 0xe9, 0xa2, 0x01, 0x1a, 0x5d, 0x25, 0x79, 0x3a, 0x22, 0x19, 0x01, 0x15}, `"2019-07-10T05:35:54.277Z"`, nil},
+		{"time-ext-unknown", []byte{0xd9, 0x03, 0xe9, 0xa2, 0x01, 0x01, 0x02, 0x03}, `{"1":1,"2":3}`, nil},
+		{"time-text", append([]byte{0xc0, 0x78, 0x1e}, "2019-07-10T05:35:54.123456789Z"...), `"2019-07-10T05:35:54.123456789Z"`, nil},
+		{"ipv4", []byte{0xd8, 0x34, 0x44, 10, 0, 0, 1}, `"10.0.0.1"`, nil},
+		{"prefix", []byte{0xd8, 0x34, 0x82, 0x18, 0x18, 0x43, 10, 0, 0}, `"10.0.0.0/24"`, nil},
+		{"mac", []byte{0xd8, 0x30, 0x46, 0, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}, `"00:1a:2b:3c:4d:5e"`, nil},
+		{"unknown-tag", []byte{0xd8, 0x20, 0x61, 'x'}, `"x"`, nil},
+		{"sequence", []byte{0x01, 0x02}, "1\n2", nil},
+		{"truncated", []byte{0x62, 'h'}, "", errCBORTruncated},
+		{"unbroken", []byte{0xbf, 0x61, 'a', 0x01}, "", errCBORTruncated},
+		{"invalid", []byte{0x1c}, "", errCBORInvalid},
+		{"deep", bytes.Repeat([]byte{0x81}, maxCBORDepth+2), "", errCBORDepth},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := CBORToJSON(nil, c.cbor)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("CBORToJSON() error = %v, want %v", err, c.err)
+			}
+			if c.err == nil && string(got) != c.want+"\n" {
+				t.Errorf("CBORToJSON() = %s, want %s", got, c.want)
+			}
+		})
+	}
+}
@@ -51,7 +51,7 @@ func (w *ConsoleWriter) Close() (err error) {
 	return
 }
 
-func (w *ConsoleWriter) write(out io.Writer, p []byte) (int, error) {
+func (w *ConsoleWriter) write(out io.Writer, p []byte, schema *Schema) (int, error) {
 	b := bbpool.Get().(*bb)
 	b.B = b.B[:0]
 	defer bbpool.Put(b)
@@ -59,7 +59,7 @@ func (w *ConsoleWriter) write(out io.Writer, p []byte) (int, error) {
 	b.B = append(b.B, p...)
 
 	var args FormatterArgs
-	parseFormatterArgs(b.B, &args)
+	parseFormatterArgs(b.B, &args, schema)
 
 	switch {
 	case args.Time == "":
@@ -109,6 +109,12 @@ func (w *ConsoleWriter) format(out io.Writer, args *FormatterArgs) (n int, err e
 		color, three = Red, "PNC"
 	default:
 		color, three = Gray, "???"
+		if info, ok := LookupLevel(ParseLevel(args.Level)); ok {
+			three = info.Code
+			if info.Color != "" {
+				color = info.Color
+			}
+		}
 	}
 
 	// pretty console writer
@@ -125,6 +131,9 @@ func (w *ConsoleWriter) format(out io.Writer, args *FormatterArgs) (n int, err e
 		}
 		// key and values
 		for _, kv := range args.KeyValues {
+			if kv.Key == "goroutines" && kv.ValueType == 'o' {
+				continue
+			}
 			if w.QuoteString && kv.ValueType == 's' {
 				kv.Value = strconv.Quote(kv.Value)
 			}
@@ -151,6 +160,9 @@ func (w *ConsoleWriter) format(out io.Writer, args *FormatterArgs) (n int, err e
 		}
 		// key and values
 		for _, kv := range args.KeyValues {
+			if kv.Key == "goroutines" && kv.ValueType == 'o' {
+				continue
+			}
 			if w.QuoteString && kv.ValueType == 's' {
 				b.B = append(b.B, ' ')
 				b.B = append(b.B, kv.Key...)
@@ -172,16 +184,37 @@ func (w *ConsoleWriter) format(out io.Writer, args *FormatterArgs) (n int, err e
 	}
 
 	// stack
-	if args.Stack != "" {
+	var cyan, gray, reset string
+	if w.ColorOutput {
+		cyan, gray, reset = Cyan, Gray, Reset
+	}
+	if args.Stack != "" && args.Stack[0] == '[' {
+		w.frames(b, args.Stack, cyan, gray, reset)
+	} else if args.Stack != "" {
 		b.B = append(b.B, args.Stack...)
 		if args.Stack[len(args.Stack)-1] != '\n' {
 			b.B = append(b.B, '\n')
 		}
 	}
 
+	// goroutines
+	if goroutines := args.Get("goroutines"); goroutines != "" && goroutines[0] == '[' {
+		for _, g := range jsonObjects([]byte(goroutines)) {
+			fmt.Fprintf(b, "%sgoroutine %s [%s]:%s\n", cyan, g["id"], g["state"], reset)
+			w.frames(b, g["stack"], cyan, gray, reset)
+		}
+	}
+
 	return out.Write(b.B)
 }
 
+// frames pretty prints the structured stack frames of JSON array s.
+func (w *ConsoleWriter) frames(b *bb, s string, cyan, gray, reset string) {
+	for _, frame := range jsonObjects([]byte(s)) {
+		fmt.Fprintf(b, "  %s%s%s\n      %s%s:%s%s\n", cyan, frame["func"], reset, gray, frame["file"], frame["line"], reset)
+	}
+}
+
 type LogfmtFormatter struct {
 	TimeField string
 }
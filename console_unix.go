@@ -64,5 +64,11 @@ func (w *ConsoleWriter) WriteEntry(e *Entry) (int, error) {
 	if out == nil {
 		out = os.Stderr
 	}
-	return w.write(out, e.buf)
+	if e.cbor || e.logfmt {
+		b := bbpool.Get().(*bb)
+		defer bbpool.Put(b)
+		b.B = e.appendJSON(b.B[:0])
+		return w.write(out, b.B, e.schema())
+	}
+	return w.write(out, e.buf, e.schema())
 }
@@ -38,15 +38,22 @@ func (w *ConsoleWriter) WriteEntry(e *Entry) (n int, err error) {
 	if out == nil {
 		out = os.Stderr
 	}
+	p := e.buf
+	if e.cbor || e.logfmt {
+		b := bbpool.Get().(*bb)
+		defer bbpool.Put(b)
+		b.B = e.appendJSON(b.B[:0])
+		p = b.B
+	}
 	if isvt {
-		n, err = w.write(out, e.buf)
+		n, err = w.write(out, p, e.schema())
 	} else {
-		n, err = w.writew(out, e.buf)
+		n, err = w.writew(out, p, e.schema())
 	}
 	return
 }
 
-func (w *ConsoleWriter) writew(out io.Writer, p []byte) (n int, err error) {
+func (w *ConsoleWriter) writew(out io.Writer, p []byte, schema *Schema) (n int, err error) {
 	muConsole.Lock()
 	defer muConsole.Unlock()
 
@@ -54,7 +61,7 @@ func (w *ConsoleWriter) writew(out io.Writer, p []byte) (n int, err error) {
 	b.B = b.B[:0]
 	defer bbpool.Put(b)
 
-	n, err = w.write(b, p)
+	n, err = w.write(b, p, schema)
 	if err != nil {
 		return
 	}
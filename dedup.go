@@ -0,0 +1,186 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DedupWriter is a Writer that suppresses repeated entries within a time window. Entries
+// are repeated if they have the same level, exactly the same message and equal values of
+// Fields, messages are not normalized, e.g. ones interpolating a counter are distinct.
+// The first entry of a run is written, the repeated ones are counted and summarized by an
+// entry of the same level when the window closes or a different entry arrives, e.g.
+//
+//	{"time":"2019-07-10T05:35:55.277Z","level":"warn","repeated_message":"reconnect failed","repeated":4999,"first":"2019-07-10T05:35:54.279Z","last":"2019-07-10T05:35:55.276Z","message":"last message repeated 4999 times"}
+//
+// The summary has the time format, Schema, Context and encoding of the logger of the first
+// entry of the run, it is written to Writer directly, so it is not passed to the Hooks and
+// has neither trace id nor node name. Fatal and panic entries are never suppressed.
+type DedupWriter struct {
+	// Writer specifies the writer of entries and summaries.
+	Writer Writer
+
+	// Window specifies the time window of a run, it uses 1s if zero.
+	Window time.Duration
+
+	// Fields specifies the top-level keys whose values make entries distinct besides
+	// level and message, other fields are ignored.
+	Fields []string
+
+	mu     sync.Mutex
+	active bool
+	level  Level
+	msg    string
+	logger *Logger
+	values []string
+	count  int
+	first  time.Time
+	last   time.Time
+	timer  *time.Timer
+	gen    uint64
+}
+
+// WriteEntry implements Writer.
+func (w *DedupWriter) WriteEntry(e *Entry) (n int, err error) {
+	if level := e.Level.rank(); level >= FatalLevel && level != noLevel {
+		w.mu.Lock()
+		w.reset()
+		w.mu.Unlock()
+		return w.Writer.WriteEntry(e)
+	}
+
+	b := bbpool.Get().(*bb)
+	b.B = e.appendJSON(b.B[:0])
+	defer bbpool.Put(b)
+
+	var args FormatterArgs
+	parseFormatterArgs(b.B, &args, e.schema())
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.repeated(e.Level, &args) {
+		now := timeNow()
+		if w.count == 0 {
+			w.first = now
+		}
+		w.count++
+		w.last = now
+		return len(e.buf), nil
+	}
+
+	w.reset()
+	// the strings of args refer to b
+	w.active = true
+	w.level, w.msg = e.Level, strings.Clone(args.Message)
+	w.logger = e.logger
+	if w.logger == nil {
+		w.logger = &Logger{Encoding: e.encoding()}
+	}
+	w.values = w.values[:0]
+	for _, field := range w.Fields {
+		w.values = append(w.values, strings.Clone(args.Get(field)))
+	}
+
+	window := w.Window
+	if window <= 0 {
+		window = time.Second
+	}
+	w.gen++
+	gen := w.gen
+	w.timer = time.AfterFunc(window, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.gen == gen {
+			w.reset()
+		}
+	})
+
+	return w.Writer.WriteEntry(e)
+}
+
+// repeated reports whether the entry of level and args repeats the current run.
+func (w *DedupWriter) repeated(level Level, args *FormatterArgs) bool {
+	if !w.active || level != w.level || args.Message != w.msg {
+		return false
+	}
+	for i, field := range w.Fields {
+		if args.Get(field) != w.values[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// reset ends the current run and writes its summary if entries are suppressed.
+func (w *DedupWriter) reset() {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if !w.active {
+		return
+	}
+	w.active = false
+	w.gen++
+	origin := w.logger
+	w.logger = nil
+	if w.count == 0 {
+		return
+	}
+
+	logger := summaryLogger(origin, w.Writer)
+	e := logger.header(w.level)
+	e.Str("repeated_message", w.msg)
+	e.Int("repeated", w.count)
+	e.Time("first", w.first)
+	e.Time("last", w.last)
+	for i, field := range w.Fields {
+		if w.values[i] != "" {
+			e.Str(field, w.values[i])
+		}
+	}
+	e.Msg(fmt.Sprintf("last message repeated %d times", w.count))
+	w.count = 0
+}
+
+// summaryLogger returns a logger which formats entries like origin and writes them to w
+// without hooks, tracing or redaction, the Context of origin is redacted beforehand.
+func summaryLogger(origin *Logger, w Writer) *Logger {
+	return &Logger{
+		TimeField:    origin.TimeField,
+		TimeFormat:   origin.TimeFormat,
+		TimeLocation: origin.TimeLocation,
+		Schema:       origin.Schema,
+		Encoding:     origin.Encoding,
+		Context:      origin.context(),
+		Writer:       w,
+	}
+}
+
+// Flush implements Flusher, writes the summary of the current run and flushes the
+// underlying Writer.
+func (w *DedupWriter) Flush() (err error) {
+	w.mu.Lock()
+	w.reset()
+	w.mu.Unlock()
+	if flusher, ok := w.Writer.(Flusher); ok {
+		err = flusher.Flush()
+	}
+	return
+}
+
+// Close implements io.Closer, writes the summary of the current run and closes the
+// underlying Writer.
+func (w *DedupWriter) Close() (err error) {
+	w.mu.Lock()
+	w.reset()
+	w.mu.Unlock()
+	if closer, ok := w.Writer.(io.Closer); ok {
+		err = closer.Close()
+	}
+	return
+}
@@ -0,0 +1,150 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDedupWriter(t *testing.T) {
+	cases := []struct {
+		name   string
+		fields []string
+		logger func(l *Logger)
+		log    func(l *Logger)
+		want   []string
+	}{
+		{
+			name: "repeated",
+			log: func(l *Logger) {
+				for i := 0; i < 3; i++ {
+					l.Info().Msg("reconnect failed")
+				}
+			},
+			want: []string{
+				`{"time":"2019-07-10T05:35:54.277Z","level":"info","message":"reconnect failed"}`,
+				`{"time":"2019-07-10T05:35:54.277Z","level":"info","repeated_message":"reconnect failed","repeated":2,"first":"2019-07-10T05:35:54.277Z","last":"2019-07-10T05:35:54.277Z","message":"last message repeated 2 times"}`,
+			},
+		},
+		{
+			name: "distinct",
+			log: func(l *Logger) {
+				l.Info().Msg("retry 1")
+				l.Info().Msg("retry 2")
+				l.Warn().Msg("retry 2")
+			},
+			want: []string{
+				`{"time":"2019-07-10T05:35:54.277Z","level":"info","message":"retry 1"}`,
+				`{"time":"2019-07-10T05:35:54.277Z","level":"info","message":"retry 2"}`,
+				`{"time":"2019-07-10T05:35:54.277Z","level":"warn","message":"retry 2"}`,
+			},
+		},
+		{
+			name:   "fields",
+			fields: []string{"host"},
+			log: func(l *Logger) {
+				l.Info().Str("host", "a").Int("n", 1).Msg("down")
+				l.Info().Str("host", "a").Int("n", 2).Msg("down")
+				l.Info().Str("host", "b").Msg("down")
+			},
+			want: []string{
+				`{"time":"2019-07-10T05:35:54.277Z","level":"info","host":"a","n":1,"message":"down"}`,
+				`{"time":"2019-07-10T05:35:54.277Z","level":"info","repeated_message":"down","repeated":1,"first":"2019-07-10T05:35:54.277Z","last":"2019-07-10T05:35:54.277Z","host":"a","message":"last message repeated 1 times"}`,
+				`{"time":"2019-07-10T05:35:54.277Z","level":"info","host":"b","message":"down"}`,
+			},
+		},
+		{
+			name: "schema-context",
+			logger: func(l *Logger) {
+				l.Schema = &Schema{LevelKey: "severity", MessageKey: "msg"}
+				l.Context = NewContext(nil).Str("app", "api").Value()
+			},
+			log: func(l *Logger) {
+				l.Error().Msg("timeout")
+				l.Error().Msg("timeout")
+			},
+			want: []string{
+				`{"time":"2019-07-10T05:35:54.277Z","severity":"error","app":"api","msg":"timeout"}`,
+				`{"time":"2019-07-10T05:35:54.277Z","severity":"error","app":"api","repeated_message":"timeout","repeated":1,"first":"2019-07-10T05:35:54.277Z","last":"2019-07-10T05:35:54.277Z","msg":"last message repeated 1 times"}`,
+			},
+		},
+		{
+			name: "bypass",
+			logger: func(l *Logger) {
+				l.Redactor = &Redactor{Keys: []string{"token"}}
+				l.Context = NewContext(nil).Str("token", "secret").Value()
+				l.Hooks = []Hook{HookFunc(func(e *Entry, msg string) bool {
+					e.Bool("hooked", true)
+					return true
+				})}
+			},
+			log: func(l *Logger) {
+				l.Warn().Msg("retry")
+				l.Warn().Msg("retry")
+			},
+			want: []string{
+				`{"time":"2019-07-10T05:35:54.277Z","level":"warn","token":"***","hooked":true,"message":"retry"}`,
+				`{"time":"2019-07-10T05:35:54.277Z","level":"warn","token":"***","repeated_message":"retry","repeated":1,"first":"2019-07-10T05:35:54.277Z","last":"2019-07-10T05:35:54.277Z","message":"last message repeated 1 times"}`,
+			},
+		},
+		{
+			name: "fatal",
+			log: func(l *Logger) {
+				l.Info().Msg("exiting")
+				l.Info().Msg("exiting")
+				l.Fatal().Msg("exiting")
+				l.Fatal().Msg("exiting")
+			},
+			want: []string{
+				`{"time":"2019-07-10T05:35:54.277Z","level":"info","message":"exiting"}`,
+				`{"time":"2019-07-10T05:35:54.277Z","level":"info","repeated_message":"exiting","repeated":1,"first":"2019-07-10T05:35:54.277Z","last":"2019-07-10T05:35:54.277Z","message":"last message repeated 1 times"}`,
+				`{"time":"2019-07-10T05:35:54.277Z","level":"fatal","message":"exiting"}`,
+				`{"time":"2019-07-10T05:35:54.277Z","level":"fatal","message":"exiting"}`,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			w := &DedupWriter{Writer: logger.Writer, Window: time.Hour, Fields: c.fields}
+			logger.Writer = w
+			if c.logger != nil {
+				c.logger(&logger)
+			}
+
+			c.log(&logger)
+			if err := w.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			if got, want := b.String(), strings.Join(c.want, "\n")+"\n"; got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestDedupWriterWindow(t *testing.T) {
+	var b bytes.Buffer
+	logger := testLogger(t, &b)
+	w := &DedupWriter{Writer: logger.Writer, Window: 50 * time.Millisecond}
+	logger.Writer = w
+
+	logger.Info().Msg("tick")
+	logger.Info().Msg("tick")
+	time.Sleep(300 * time.Millisecond)
+	logger.Info().Msg("tick")
+
+	w.mu.Lock()
+	got := b.String()
+	w.mu.Unlock()
+	want := `{"time":"2019-07-10T05:35:54.277Z","level":"info","message":"tick"}` + "\n" +
+		`{"time":"2019-07-10T05:35:54.277Z","level":"info","repeated_message":"tick","repeated":1,"first":"2019-07-10T05:35:54.277Z","last":"2019-07-10T05:35:54.277Z","message":"last message repeated 1 times"}` + "\n" +
+		`{"time":"2019-07-10T05:35:54.277Z","level":"info","message":"tick"}` + "\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
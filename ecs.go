@@ -0,0 +1,36 @@
+package log
+
+// ECSVersion is the version of Elastic Common Schema written by ECSSchema.
+const ECSVersion = "8.11.0"
+
+// ECSSchema returns a Schema of Elastic Common Schema, which writes "@timestamp", "log.level",
+// "message", "log.origin.file.name", "log.origin.file.line", "log.origin.function",
+// "error.message", "error.type", "error.stack_trace", "trace.id", "span.id" and "ecs.version".
+// If labels, the fields of entries are nested under "labels" as flat values, see Labels of
+// Schema, except dotted keys which are kept top-level as ECS fields, e.g.
+//
+//	logger := log.Logger{Caller: 1, Schema: log.ECSSchema(true)}
+//	logger.Info().Str("user", "alice").Str("http.request.method", "GET").Msg("hello world")
+//
+//	// Output: {"@timestamp":"2019-07-10T05:35:54.277Z","log.level":"info","ecs.version":"8.11.0","log.origin.file.name":"main.go","log.origin.file.line":42,"log.origin.function":"main.main","process.thread.id":1,"http.request.method":"GET","message":"hello world","labels":{"user":"alice"}}
+func ECSSchema(labels bool) *Schema {
+	s := &Schema{
+		TimeKey:       "@timestamp",
+		LevelKey:      "log.level",
+		MessageKey:    "message",
+		CallerKey:     "log.origin.file.name",
+		CallerLineKey: "log.origin.file.line",
+		CallerFuncKey: "log.origin.function",
+		GoidKey:       "process.thread.id",
+		ErrorKey:      "error.message",
+		ErrorTypeKey:  "error.type",
+		StackKey:      "error.stack_trace",
+		TraceIDKey:    "trace.id",
+		SpanIDKey:     "span.id",
+		Context:       NewContext(nil).Str("ecs.version", ECSVersion).Value(),
+	}
+	if labels {
+		s.Labels = "labels"
+	}
+	return s
+}
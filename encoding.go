@@ -0,0 +1,88 @@
+package log
+
+// Encoding specifies the encoding of entries, see Logger.Encoding.
+type Encoding uint8
+
+const (
+	// EncodingJSON encodes entries as lines of JSON objects.
+	EncodingJSON Encoding = iota
+	// EncodingCBOR encodes entries as CBOR (RFC 8949) maps of indefinite length, which
+	// are written back to back as a CBOR sequence without newlines. Times are epoch times
+	// of tag 1, integers or floats of whole microseconds, or extended times of tag 1001 of
+	// seconds and nanoseconds (RFC 9581), Bytes are byte strings,
+	// and IP and MAC addresses are byte strings of tags 52, 54 and 48. Contexts and raw
+	// JSON, e.g. of RawJSON and Interface, are transcoded from JSON. Entries are decoded
+	// to JSON by CBORToJSON.
+	EncodingCBOR
+	// EncodingLogfmt encodes entries as lines of logfmt key=value pairs, e.g.
+	//
+	//	time=2019-07-10T05:35:54.277Z level=info req.method=GET tags="[\"a\",\"b\"]" message="hello world"
+	//
+	// Values are quoted with JSON escapes if they are empty or contain spaces, '=', quotes
+	// or control characters. Nested objects, e.g. of Dict, Object and Interface, are
+	// flattened to dotted keys, and arrays are written as their JSON text.
+	EncodingLogfmt
+)
+
+// encoding returns the encoding of the entry.
+func (e *Entry) encoding() Encoding {
+	switch {
+	case e.cbor:
+		return EncodingCBOR
+	case e.logfmt:
+		return EncodingLogfmt
+	}
+	return EncodingJSON
+}
+
+// beginJSON switches a CBOR or logfmt entry to JSON for the producers of raw JSON fields,
+// e.g. ErrorMarshaler. It returns the offset and the encoding for endJSON, the offset is
+// -1 if the entry is JSON. The fields are checked together once transcoded, see keyStart.
+func (e *Entry) beginJSON() (int, Encoding) {
+	enc := e.encoding()
+	if enc == EncodingJSON {
+		return -1, enc
+	}
+	e.keyStart()
+	e.cbor, e.logfmt = false, false
+	return len(e.buf), enc
+}
+
+// endJSON transcodes the JSON fields added since beginJSON returned n back to enc.
+func (e *Entry) endJSON(n int, enc Encoding) {
+	if n < 0 {
+		return
+	}
+	if e.keying && e.field == n {
+		// the fields are checked as JSON, before they are transcoded
+		e.keyFields(n)
+		e.keyField()
+	}
+	e.cbor, e.logfmt = enc == EncodingCBOR, enc == EncodingLogfmt
+	if n == len(e.buf) {
+		return
+	}
+	b := bbpool.Get().(*bb)
+	b.B = append(b.B[:0], e.buf[n:]...)
+	if e.cbor {
+		e.buf = cborAppendFields(e.buf[:n], b.B)
+	} else {
+		e.buf = logfmtAppendFields(e.buf[:n], e.prefix, b.B)
+	}
+	if cap(b.B) <= bbcap {
+		bbpool.Put(b)
+	}
+}
+
+// appendJSON appends the entry as JSON to dst, CBOR entries are decoded by CBORToJSON
+// and logfmt entries by logfmtToJSON.
+func (e *Entry) appendJSON(dst []byte) []byte {
+	switch {
+	case e.cbor:
+		dst, _ = CBORToJSON(dst, e.buf)
+		return dst
+	case e.logfmt:
+		return logfmtToJSON(dst, e.buf)
+	}
+	return append(dst, e.buf...)
+}
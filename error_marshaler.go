@@ -0,0 +1,110 @@
+package log
+
+import (
+	"errors"
+	"reflect"
+)
+
+// ErrorMarshaler adds the field key with err to the entry, see Logger.ErrorMarshaler.
+type ErrorMarshaler interface {
+	MarshalError(e *Entry, key string, err error)
+}
+
+// The ErrorMarshalerFunc type is an adapter to allow the use of
+// ordinary functions as error marshalers. If f is a function
+// with the appropriate signature, ErrorMarshalerFunc(f) is a
+// [ErrorMarshaler] that calls f.
+type ErrorMarshalerFunc func(e *Entry, key string, err error)
+
+// MarshalError calls f(e, key, err).
+func (f ErrorMarshalerFunc) MarshalError(e *Entry, key string, err error) {
+	f(e, key, err)
+}
+
+// StackTracer is implemented by errors which carry the program counters of the stack
+// where they are created. Errors with a Callers method returning the program counters,
+// e.g. of github.com/go-errors/errors, are supported as well.
+type StackTracer interface {
+	StackTrace() []uintptr
+}
+
+// callers is implemented by errors which carry the program counters of their stack like StackTracer.
+type callers interface {
+	Callers() []uintptr
+}
+
+// RichErrorMarshaler renders errors as objects of their message and the errors.Unwrap
+// chain with the type of each error, e.g.
+//
+//	{"message":"read config: open app.yaml: no such file or directory","chain":[
+//		{"message":"read config: open app.yaml: no such file or directory","type":"*fmt.wrapError"},
+//		{"message":"open app.yaml: no such file or directory","type":"*fs.PathError"},
+//		{"message":"no such file or directory","type":"syscall.Errno"}]}
+//
+// The members of errors.Join are rendered in "errors" of their node, the stack frames of
+// a StackTracer in "stack", and the fields of an ObjectMarshaler in "details".
+var RichErrorMarshaler ErrorMarshaler = ErrorMarshalerFunc(func(e *Entry, key string, err error) {
+	e.key(key)
+	e.richError(err, 0)
+})
+
+// maxErrorDepth limits the length of rendered error chains and the nesting of joined errors.
+const maxErrorDepth = 32
+
+// richError adds err as an object of its message and unwrap chain.
+func (e *Entry) richError(err error, depth int) {
+	e.buf = append(e.buf, "{\"message\":\""...)
+	e.string(err.Error())
+	e.buf = append(e.buf, "\",\"chain\":["...)
+	for i := 0; err != nil && i < maxErrorDepth; i++ {
+		if i != 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = append(e.buf, "{\"message\":\""...)
+		e.string(err.Error())
+		e.buf = append(e.buf, "\",\"type\":\""...)
+		e.string(reflect.TypeOf(err).String())
+		e.buf = append(e.buf, '"')
+		if o, ok := err.(ObjectMarshaler); ok {
+			e.Object("details", o)
+		}
+		if pcs := errorStack(err); len(pcs) != 0 {
+			e.buf = append(e.buf, ",\"stack\":"...)
+			e.frames(pcs, nil)
+		}
+		var joined []error
+		switch x := err.(type) {
+		case interface{ Unwrap() []error }:
+			joined, err = x.Unwrap(), nil
+		default:
+			err = errors.Unwrap(err)
+		}
+		if len(joined) != 0 {
+			e.buf = append(e.buf, ",\"errors\":["...)
+			for j, member := range joined {
+				if j != 0 {
+					e.buf = append(e.buf, ',')
+				}
+				if member == nil || depth >= maxErrorDepth {
+					e.buf = append(e.buf, "null"...)
+				} else {
+					e.richError(member, depth+1)
+				}
+			}
+			e.buf = append(e.buf, ']')
+		}
+		e.buf = append(e.buf, '}')
+	}
+	e.buf = append(e.buf, ']', '}')
+}
+
+// errorStack returns the program counters carried by err, or nil if none.
+func errorStack(err error) []uintptr {
+	switch x := err.(type) {
+	case StackTracer:
+		return x.StackTrace()
+	case callers:
+		return x.Callers()
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type stackError struct {
+	pcs []uintptr
+}
+
+func newStackError() *stackError {
+	pcs := make([]uintptr, 8)
+	return &stackError{pcs[:runtime.Callers(1, pcs)]}
+}
+
+func (e *stackError) Error() string         { return "stack" }
+func (e *stackError) StackTrace() []uintptr { return e.pcs }
+
+type callersError struct {
+	stackError
+}
+
+func (e *callersError) Callers() []uintptr { return e.pcs }
+
+type detailsError struct{}
+
+func (detailsError) Error() string          { return "details" }
+func (detailsError) MarshalObject(e *Entry) { e.Int("code", 7) }
+
+func TestRichErrorMarshaler(t *testing.T) {
+	base := errors.New("no such file")
+	cases := []struct {
+		name  string
+		err   error
+		want  string
+		stack bool
+	}{
+		{"plain", base, `{"message":"no such file","chain":[{"message":"no such file","type":"*errors.errorString"}]}`, false},
+		{"wrapped", fmt.Errorf("read config: %w", base),
+			`{"message":"read config: no such file","chain":[{"message":"read config: no such file","type":"*fmt.wrapError"},{"message":"no such file","type":"*errors.errorString"}]}`, false},
+		{"joined", errors.Join(base, detailsError{}),
+			`{"message":"no such file\ndetails","chain":[{"message":"no such file\ndetails","type":"*errors.joinError","errors":[{"message":"no such file","chain":[{"message":"no such file","type":"*errors.errorString"}]},{"message":"details","chain":[{"message":"details","type":"log.detailsError","details":{"code":7}}]}]}]}`, false},
+		{"stack-tracer", newStackError(), "", true},
+		{"callers", &callersError{*newStackError()}, "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			logger.ErrorMarshaler = RichErrorMarshaler
+			logger.Error().Err(c.err).Msg("")
+
+			var entry struct {
+				Error json.RawMessage
+			}
+			if err := json.Unmarshal(b.Bytes(), &entry); err != nil {
+				t.Fatalf("invalid JSON %s: %v", b.Bytes(), err)
+			}
+			if !c.stack {
+				if got := string(entry.Error); got != c.want {
+					t.Errorf("got %s\nwant %s", got, c.want)
+				}
+				return
+			}
+			var rich struct {
+				Chain []struct {
+					Stack []struct{ Func string }
+				}
+			}
+			if err := json.Unmarshal(entry.Error, &rich); err != nil || len(rich.Chain) == 0 || len(rich.Chain[0].Stack) == 0 ||
+				!strings.HasSuffix(rich.Chain[0].Stack[0].Func, "newStackError") {
+				t.Errorf("no stack frames of newStackError: %s", entry.Error)
+			}
+		})
+	}
+}
+
+func TestErrorStack(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("x"), false},
+		{newStackError(), true},
+		{&callersError{*newStackError()}, true},
+		{fmt.Errorf("wrapped: %w", newStackError()), false},
+	}
+	for _, c := range cases {
+		if got := len(errorStack(c.err)) != 0; got != c.want {
+			t.Errorf("errorStack(%T) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
@@ -106,7 +106,7 @@ func (w *EventlogWriter) WriteEntry(e *Entry) (n int, err error) {
 	)
 
 	var etype uint16
-	switch e.Level {
+	switch e.Level.rank() {
 	case TraceLevel:
 		etype = EVENTLOG_INFORMATION_TYPE
 	case DebugLevel:
@@ -129,7 +129,14 @@ func (w *EventlogWriter) WriteEntry(e *Entry) (n int, err error) {
 	var eid = w.ID
 	var ss = []*uint16{nil}
 
-	ss[0], err = syscall.UTF16PtrFromString(b2s(e.buf))
+	json := e.buf
+	if e.cbor || e.logfmt {
+		b := bbpool.Get().(*bb)
+		defer bbpool.Put(b)
+		b.B = e.appendJSON(b.B[:0])
+		json = b.B
+	}
+	ss[0], err = syscall.UTF16PtrFromString(b2s(json))
 	if err != nil {
 		return
 	}
@@ -128,6 +128,16 @@ func (w *FileWriter) write(p []byte) (n int, err error) {
 	return
 }
 
+// Flush implements Flusher, and commits the current logfile to stable storage.
+func (w *FileWriter) Flush() (err error) {
+	w.mu.Lock()
+	if w.file != nil {
+		err = w.file.Sync()
+	}
+	w.mu.Unlock()
+	return
+}
+
 // Close implements io.Closer, and closes the current logfile.
 func (w *FileWriter) Close() (err error) {
 	w.mu.Lock()
@@ -283,6 +293,10 @@ func (w *FileWriter) fileargs(now time.Time) (filename string, flag int, perm os
 		filename = prefix + "." + strconv.FormatInt(now.Unix(), 10)
 	case TimeFormatUnixMs:
 		filename = prefix + "." + strconv.FormatInt(now.UnixNano()/1000000, 10)
+	case TimeFormatUnixMicro:
+		filename = prefix + "." + strconv.FormatInt(now.UnixMicro(), 10)
+	case TimeFormatUnixNano:
+		filename = prefix + "." + strconv.FormatInt(now.UnixNano(), 10)
 	default:
 		filename = prefix + "." + now.Format(w.TimeFormat)
 	}
@@ -3,10 +3,8 @@ package log
 import (
 	"bytes"
 	"fmt"
-	"reflect"
 	"strconv"
 	"strings"
-	"time"
 	"unicode/utf16"
 	"unicode/utf8"
 	"unsafe"
@@ -64,13 +62,10 @@ func formatterArgsPos(key string) (pos int) {
 }
 
 // parseFormatterArgs extracts json string to json items
-func parseFormatterArgs(json []byte, args *FormatterArgs) {
+func parseFormatterArgs(json []byte, args *FormatterArgs, schema *Schema) {
 	// treat formatter args as []string
 	const size = int(unsafe.Sizeof(FormatterArgs{}) / unsafe.Sizeof(""))
-	//nolint:all
-	slice := *(*[]string)(unsafe.Pointer(&reflect.SliceHeader{
-		Data: uintptr(unsafe.Pointer(args)), Len: size, Cap: size,
-	}))
+	slice := unsafe.Slice((*string)(unsafe.Pointer(args)), size)
 	var keys = true
 	var key, str []byte
 	var ok bool
@@ -107,7 +102,7 @@ func parseFormatterArgs(json []byte, args *FormatterArgs) {
 			str = jsonUnescape(str[1:len(str)-1], str[:0])
 			typ = 's'
 		}
-		pos := formatterArgsPos(b2s(key))
+		pos := schema.pos(b2s(key))
 		if pos == 0 && args.Time == "" {
 			pos = 1
 		}
@@ -128,6 +123,8 @@ func parseFormatterArgs(json []byte, args *FormatterArgs) {
 
 	if args.Level == "" {
 		args.Level = "????"
+	} else if schema != nil && schema.LevelStyle != LevelStyleLower {
+		args.Level = schema.ParseLevel(args.Level).String()
 	}
 }
 
@@ -377,7 +374,7 @@ func (f *JSONFormatter) Format(entry *Entry) ([]byte, error) {
 	// Fallback: create a minimal JSON entry if buffer is empty
 	var buf bytes.Buffer
 	buf.WriteString(`{"time":"`)
-	buf.WriteString(time.Now().Format("2006-01-02T15:04:05.999Z07:00"))
+	buf.WriteString(timeNow().Format("2006-01-02T15:04:05.999Z07:00"))
 	buf.WriteString(`","level":"`)
 	switch entry.Level {
 	case DebugLevel:
@@ -414,7 +411,7 @@ func (f *HumanReadableFormatter) Format(entry *Entry) ([]byte, error) {
 
 	// Add timestamp
 	if f.ShowTimestamp {
-		now := time.Now()
+		now := timeNow()
 		if entry.logger != nil && entry.logger.TimeLocation != nil {
 			buf.WriteString(now.In(entry.logger.TimeLocation).Format("2006-01-02 15:04:05"))
 		} else {
@@ -761,7 +758,7 @@ func (f *TemplateFormatter) getTemplateValue(varName string, entry *Entry) strin
 			return "????"
 		}
 	case "time":
-		now := time.Now()
+		now := timeNow()
 		if entry.logger != nil && entry.logger.TimeLocation != nil {
 			return now.In(entry.logger.TimeLocation).Format("2006-01-02 15:04:05")
 		}
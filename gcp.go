@@ -0,0 +1,39 @@
+package log
+
+// GCPErrorReportType is the "@type" of Google Cloud Error Reporting events.
+const GCPErrorReportType = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+// GCPSchema returns a Schema of the structured logging of Google Cloud Logging, which writes
+// "severity", "timestamp", "message", "logging.googleapis.com/sourceLocation" of the caller,
+// "logging.googleapis.com/trace", "logging.googleapis.com/spanId" and
+// "logging.googleapis.com/trace_sampled" of the trace context extracted by W3CExtractor.
+// Entries of error and higher levels with the textual stack of Stack are marked for Error
+// Reporting, the structured frames of StackOptions are not reported. The trace ids are
+// prefixed by "projects/{projectID}/traces/" if projectID is not empty, e.g.
+//
+//	logger := log.Logger{
+//		Caller:           1,
+//		ContextExtractor: log.W3CExtractor{},
+//		Schema:           log.GCPSchema("my-project"),
+//		Writer:           log.IOWriter{Writer: os.Stdout},
+//	}
+//
+//	// Output: {"timestamp":"2019-07-10T05:35:54.277Z","severity":"INFO","logging.googleapis.com/sourceLocation":{"file":"main.go","line":"42","function":"main.main"},"goid":1,"message":"hello world"}
+func GCPSchema(projectID string) *Schema {
+	s := &Schema{
+		TimeKey:           "timestamp",
+		LevelKey:          "severity",
+		MessageKey:        "message",
+		SourceLocationKey: "logging.googleapis.com/sourceLocation",
+		StackKey:          "stack_trace",
+		TraceIDKey:        "logging.googleapis.com/trace",
+		SpanIDKey:         "logging.googleapis.com/spanId",
+		TraceSampledKey:   "logging.googleapis.com/trace_sampled",
+		ErrorReportType:   GCPErrorReportType,
+		LevelStyle:        LevelStyleGCP,
+	}
+	if projectID != "" {
+		s.TraceIDPrefix = "projects/" + projectID + "/traces/"
+	}
+	return s
+}
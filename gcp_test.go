@@ -0,0 +1,75 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGCPSchemaErrorReport(t *testing.T) {
+	cases := []struct {
+		name     string
+		encoding Encoding
+		level    Level
+		frames   bool
+		stack    bool
+		report   bool
+	}{
+		{"error-stack", EncodingJSON, ErrorLevel, false, true, true},
+		{"fatal-stack", EncodingJSON, FatalLevel, false, true, true},
+		{"warn-stack", EncodingJSON, WarnLevel, false, true, false},
+		{"error-nostack", EncodingJSON, ErrorLevel, false, false, false},
+		{"error-frames", EncodingJSON, ErrorLevel, true, true, false},
+		{"cbor-stack", EncodingCBOR, ErrorLevel, false, true, true},
+		{"cbor-frames", EncodingCBOR, ErrorLevel, true, true, false},
+		{"logfmt-stack", EncodingLogfmt, ErrorLevel, false, true, true},
+		{"logfmt-frames", EncodingLogfmt, ErrorLevel, true, true, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			logger.Encoding = c.encoding
+			logger.Schema = GCPSchema("my-project")
+			if c.frames {
+				logger.StackOptions = &StackOptions{}
+			}
+
+			e := logger.WithLevel(c.level)
+			if c.stack {
+				e = e.Stack()
+			}
+			e.Msg("failed")
+
+			out := b.Bytes()
+			switch c.encoding {
+			case EncodingCBOR:
+				var err error
+				if out, err = CBORToJSON(nil, out); err != nil {
+					t.Fatalf("CBORToJSON() error = %v", err)
+				}
+			case EncodingLogfmt:
+				if got := strings.Contains(string(out), "@type="+GCPErrorReportType); got != c.report {
+					t.Errorf("reported = %v, want %v: %s", got, c.report, out)
+				}
+				return
+			}
+
+			var m map[string]any
+			if err := json.Unmarshal(out, &m); err != nil {
+				t.Fatalf("json.Unmarshal(%s) error = %v", out, err)
+			}
+			if got := m["@type"] == GCPErrorReportType; got != c.report {
+				t.Errorf("reported = %v, want %v: %s", got, c.report, out)
+			}
+			if _, ok := m["stack_trace"]; ok != c.stack {
+				t.Errorf("has stack_trace = %v, want %v", ok, c.stack)
+			}
+			if s, ok := m["stack_trace"].(string); c.report && (!ok || !strings.HasPrefix(s, "goroutine ")) {
+				t.Errorf("stack_trace = %v, want the text of runtime.Stack", m["stack_trace"])
+			}
+		})
+	}
+}
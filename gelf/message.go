@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
+
+	"github.com/oarkflow/log"
 )
 
 // Message represents the contents of the GELF message.  It is gzipped
@@ -121,20 +124,41 @@ func (m *Message) toBytes(buf *bytes.Buffer) (messageBytes []byte, err error) {
 	return messageBytes, nil
 }
 
-func constructMessage(p []byte, hostname string, facility string, file string, line int) (m *Message) {
+func constructMessage(p []byte, hostname string, facility string, file string, line int, schema *log.Schema) (m *Message) {
+	if len(p) != 0 && p[0] == 0xbf {
+		// entries of log.EncodingCBOR
+		if b, err := log.CBORToJSON(nil, p); err == nil {
+			p = b
+		}
+	}
 	data := ByteToMap(p)
 	level := "info"
+	messageKey, levelKey := "message", "level"
+	if schema != nil && schema.MessageKey != "" {
+		messageKey = schema.MessageKey
+	}
+	if schema != nil && schema.LevelKey != "" {
+		levelKey = schema.LevelKey
+	}
 	// If there are newlines in the message, use the first line
 	// for the short message and set the full message to the
 	// original input.  If the input has no newlines, stick the
 	// whole thing in Short.
 	short := []byte("Success")
-	if val, ok := data["message"]; ok {
+	if val, ok := data[messageKey]; ok {
 		short = []byte(val.(string))
 	}
-	if val, ok := data["level"]; ok {
-		level = val.(string)
-		delete(data, "level")
+	if val, ok := data[levelKey]; ok {
+		switch v := val.(type) {
+		case string:
+			level = v
+		case float64:
+			level = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		if schema != nil {
+			level = schema.ParseLevel(level).String()
+		}
+		delete(data, levelKey)
 	}
 	full := []byte("")
 	if i := bytes.IndexRune(short, '\n'); i > 0 {
@@ -167,6 +191,10 @@ func constructMessage(p []byte, hostname string, facility string, file string, l
 		m.Level = 6
 	case "debug":
 		m.Level = 7
+	default:
+		if info, ok := log.LookupLevel(log.ParseLevel(level)); ok {
+			m.Level = int32(info.Severity)
+		}
 	}
 	return m
 }
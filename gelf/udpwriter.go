@@ -160,7 +160,7 @@ func (w *UDPWriter) Write(p []byte) (n int, err error) {
 	// 1 for the function that called us.
 	file, line := getCallerIgnoringLogMulti(1)
 
-	m := constructMessage(p, w.hostname, w.Facility, file, line)
+	m := constructMessage(p, w.hostname, w.Facility, file, line, w.Schema)
 	if err = w.WriteMessage(m); err != nil {
 		return 0, err
 	}
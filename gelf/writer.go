@@ -11,6 +11,8 @@ import (
 	"fmt"
 	"io"
 	"net"
+
+	"github.com/oarkflow/log"
 )
 
 type WriterInterface interface {
@@ -27,7 +29,10 @@ type Writer struct {
 	conn     net.Conn
 	hostname string
 	Facility string // defaults to current process name
-	proto    string
+	// Schema specifies the key names and level style of the written entries,
+	// defaults to the names of log.
+	Schema *log.Schema
+	proto  string
 }
 
 // writes the gzip compressed byte array to the connection as a series
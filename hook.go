@@ -0,0 +1,176 @@
+package log
+
+import (
+	"bytes"
+)
+
+// Hook is run by Msg after the caller adds fields and before the entry is written.
+// A hook may add fields to e or change its Level, returning false vetoes the entry.
+type Hook interface {
+	Run(e *Entry, msg string) bool
+}
+
+// The HookFunc type is an adapter to allow the use of
+// ordinary functions as hooks. If f is a function
+// with the appropriate signature, HookFunc(f) is a
+// [Hook] that calls f.
+type HookFunc func(e *Entry, msg string) bool
+
+// Run calls f(e, msg).
+func (f HookFunc) Run(e *Entry, msg string) bool {
+	return f(e, msg)
+}
+
+// hook runs the hooks of the entry's logger, the entry is discarded if vetoed.
+func (e *Entry) hook(msg string) bool {
+	level := e.Level
+	for _, hook := range e.logger.Hooks {
+		if !hook.Run(e, msg) {
+			e.Discard()
+			return false
+		}
+	}
+	if e.Level != level {
+		e.relevel(level)
+	}
+	return true
+}
+
+// relevel rewrites the level field encoded for old at the offset recorded by header to the
+// current Level of the entry, the other fields keep their positions.
+func (e *Entry) relevel(old Level) {
+	if e.levelAt < 0 || e.levelAt > len(e.buf) {
+		return
+	}
+	var tmp, level [64]byte
+	schema := e.schema()
+	appendLevel := schema.appendLevel
+	if e.cbor {
+		appendLevel = schema.cborAppendLevel
+	} else if e.logfmt {
+		appendLevel = schema.logfmtAppendLevel
+	}
+	field, next := appendLevel(tmp[:0], old), appendLevel(level[:0], e.Level)
+	if !bytes.HasPrefix(e.buf[e.levelAt:], field) {
+		return
+	}
+	end := e.levelAt + len(field)
+	if d := len(next) - len(field); d > 0 {
+		e.buf = append(e.buf, next[:d]...)
+		copy(e.buf[end+d:], e.buf[end:len(e.buf)-d])
+	} else if d < 0 {
+		e.buf = append(e.buf[:end+d], e.buf[end:]...)
+	}
+	copy(e.buf[e.levelAt:], next)
+}
+
+// Encoded returns the fields encoded so far as an unterminated JSON object, an
+// unterminated CBOR map of EncodingCBOR, or an unterminated logfmt line of EncodingLogfmt.
+// The returned slice is only valid until the entry is sent and must not be modified.
+func (e *Entry) Encoded() []byte {
+	if e == nil {
+		return nil
+	}
+	return e.buf
+}
+
+// Lookup returns the value of the last encoded field with key. String values are
+// unescaped, other values are returned as their JSON text.
+func (e *Entry) Lookup(key string) (value string, ok bool) {
+	if e == nil || len(e.buf) == 0 {
+		return
+	}
+	if e.cbor {
+		return e.cborLookup(key)
+	}
+	if e.logfmt {
+		return e.logfmtLookup(key)
+	}
+	if e.buf[0] != '{' {
+		return
+	}
+
+	json := e.buf
+	var str []byte
+	var typ byte
+	var found bool
+	for i := 1; i < len(json); i++ {
+		if json[i] != '"' {
+			continue
+		}
+		i, str, _, found = jsonParseString(json, i+1)
+		if !found {
+			return
+		}
+		k := str[1 : len(str)-1]
+		for ; i < len(json); i++ {
+			if json[i] <= ' ' || json[i] == ':' {
+				continue
+			}
+			break
+		}
+		i, typ, str, found = jsonParseAny(json, i, true)
+		if !found {
+			return
+		}
+		if b2s(k) != key {
+			continue
+		}
+		switch typ {
+		case 's':
+			value = string(str[1 : len(str)-1])
+		case 'S':
+			value = string(jsonUnescape(str[1:len(str)-1], nil))
+		default:
+			value = string(str)
+		}
+		ok = true
+	}
+	return
+}
+
+// cborLookup is Lookup of CBOR entries.
+func (e *Entry) cborLookup(key string) (value string, ok bool) {
+	cbor := e.buf
+	if cbor[0] != cborMap|cborIndefinite {
+		return
+	}
+	for i := 1; i < len(cbor) && cbor[i] != cborBreak; {
+		major, k, j, err := cborString(cbor, i)
+		if err != nil || major != cborText {
+			return
+		}
+		if b2s(k) != key {
+			if i, err = cborSkip(cbor, j, 0); err != nil {
+				return
+			}
+			continue
+		}
+		var str []byte
+		if major, str, i, err = cborString(cbor, j); err == nil && major == cborText {
+			value, ok = string(str), true
+			continue
+		}
+		b := bbpool.Get().(*bb)
+		b.B, i, err = cborToJSON(b.B[:0], cbor, j, 0)
+		if err != nil {
+			bbpool.Put(b)
+			return
+		}
+		value, ok = string(b.B), true
+		bbpool.Put(b)
+	}
+	return
+}
+
+// logfmtLookup is Lookup of logfmt entries, the keys of nested fields are dotted.
+func (e *Entry) logfmtLookup(key string) (value string, ok bool) {
+	for i := 0; i < len(e.buf); {
+		k, val, j := logfmtPair(e.buf, i)
+		i = j
+		if val != nil && b2s(k) == key {
+			value, ok = logfmtValue(val), true
+		}
+	}
+	return
+}
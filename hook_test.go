@@ -0,0 +1,119 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	version := HookFunc(func(e *Entry, msg string) bool {
+		e.Str("version", "1.2.3")
+		return true
+	})
+	health := HookFunc(func(e *Entry, msg string) bool {
+		path, _ := e.Lookup("path")
+		return path != "/healthz"
+	})
+	escalate := HookFunc(func(e *Entry, msg string) bool {
+		if msg == "disk full" {
+			e.Level = ErrorLevel
+		}
+		return true
+	})
+
+	cases := []struct {
+		name  string
+		hooks []Hook
+		log   func(l *Logger)
+		want  string
+	}{
+		{"add", []Hook{version}, func(l *Logger) { l.Info().Msg("hello") }, `{"time":"2019-07-10T05:35:54.277Z","level":"info","version":"1.2.3","message":"hello"}`},
+		{"veto", []Hook{health, version}, func(l *Logger) { l.Info().Str("path", "/healthz").Msg("served") }, ``},
+		{"pass", []Hook{health}, func(l *Logger) { l.Info().Str("path", "/").Msg("served") }, `{"time":"2019-07-10T05:35:54.277Z","level":"info","path":"/","message":"served"}`},
+		{"relevel", []Hook{escalate}, func(l *Logger) { l.Warn().Msg("disk full") }, `{"time":"2019-07-10T05:35:54.277Z","level":"error","message":"disk full"}`},
+		{"msgf", []Hook{escalate}, func(l *Logger) { l.Warn().Msgf("disk %s", "full") }, `{"time":"2019-07-10T05:35:54.277Z","level":"error","message":"disk full"}`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			logger.Hooks = c.hooks
+			c.log(&logger)
+			want := c.want
+			if want != "" {
+				want += "\n"
+			}
+			if got := b.String(); got != want {
+				t.Errorf("got %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+func TestHookRelevel(t *testing.T) {
+	relevel := func(level Level) Hook {
+		return HookFunc(func(e *Entry, msg string) bool {
+			e.Level = level
+			return true
+		})
+	}
+	fields := func(e *Entry) *Entry {
+		return e.Str("note", `,"level":"warn"`).Dict("d", NewContext(nil).Str("level", "warn").Value())
+	}
+
+	cases := []struct {
+		name     string
+		encoding Encoding
+		schema   *Schema
+		level    Level
+		want     string
+	}{
+		{"longer", 0, nil, ErrorLevel, `{"time":"2019-07-10T05:35:54.277Z","level":"error","note":",\"level\":\"warn\"","d":{"level":"warn"},"message":"full"}`},
+		{"same", 0, nil, InfoLevel, `{"time":"2019-07-10T05:35:54.277Z","level":"info","note":",\"level\":\"warn\"","d":{"level":"warn"},"message":"full"}`},
+		{"shorter", 0, &Schema{LevelStyle: LevelStyleUpper}, testAuditLevel, `{"time":"2019-07-10T05:35:54.277Z","level":"AUDIT","note":",\"level\":\"warn\"","d":{"level":"warn"},"message":"full"}`},
+		{"numeric", 0, &Schema{LevelStyle: LevelStyleNumeric}, DebugLevel, `{"time":"2019-07-10T05:35:54.277Z","level":2,"note":",\"level\":\"warn\"","d":{"level":"warn"},"message":"full"}`},
+		{"absent", 0, nil, noLevel, `{"time":"2019-07-10T05:35:54.277Z","note":",\"level\":\"warn\"","d":{"level":"warn"},"message":"full"}`},
+		{"cbor", EncodingCBOR, nil, testNoticeLevel, `{"time":"2019-07-10T05:35:54.277Z","level":"notice","note":",\"level\":\"warn\"","d":{"level":"warn"},"message":"full"}`},
+		{"logfmt", EncodingLogfmt, nil, ErrorLevel, `time=2019-07-10T05:35:54.277Z level=error note=",\"level\":\"warn\"" d.level=warn message=full`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			logger.Encoding = c.encoding
+			logger.Schema = c.schema
+			logger.Hooks = []Hook{relevel(c.level)}
+
+			fields(logger.Warn()).Msgf("%s", "full")
+
+			got := b.Bytes()
+			if c.encoding == EncodingCBOR {
+				var err error
+				if got, err = CBORToJSON(nil, got); err != nil {
+					t.Fatalf("CBORToJSON() error = %v", err)
+				}
+			}
+			if string(got) != c.want+"\n" {
+				t.Errorf("got  %s\nwant %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, enc := range []Encoding{EncodingJSON, EncodingCBOR, EncodingLogfmt} {
+		logger := Logger{Encoding: enc}
+		e := logger.Info().Str("s", "a \"b\"").Int("n", 42).Strs("tags", []string{"x"})
+		for key, want := range map[string]string{"s": `a "b"`, "n": "42", "tags": `["x"]`, "level": "info"} {
+			if got, ok := e.Lookup(key); !ok || got != want {
+				t.Errorf("encoding %d: Lookup(%q) = %q, %v, want %q", enc, key, got, ok, want)
+			}
+		}
+		if _, ok := e.Lookup("missing"); ok {
+			t.Errorf("encoding %d: Lookup(missing) found", enc)
+		}
+		e.Discard()
+	}
+}
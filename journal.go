@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -53,13 +54,21 @@ func (w *JournalWriter) WriteEntry(e *Entry) (n int, err error) {
 		return
 	}
 
+	json := e.buf
+	if e.cbor || e.logfmt {
+		b1 := bbpool.Get().(*bb)
+		defer bbpool.Put(b1)
+		b1.B = e.appendJSON(b1.B[:0])
+		json = b1.B
+	}
+
 	b0 := bbpool.Get().(*bb)
 	b0.B = b0.B[:0]
 	defer bbpool.Put(b0)
-	b0.B = append(b0.B, e.buf...)
+	b0.B = append(b0.B, json...)
 
 	var args FormatterArgs
-	parseFormatterArgs(b0.B, &args)
+	parseFormatterArgs(b0.B, &args, e.schema())
 	if args.Time == "" {
 		return
 	}
@@ -110,7 +119,7 @@ func (w *JournalWriter) WriteEntry(e *Entry) (n int, err error) {
 	case PanicLevel:
 		priority = "0" // Emergency
 	default:
-		priority = "5" // Notice
+		priority = strconv.Itoa(e.Level.severity(5)) // Notice
 	}
 	print(false, "PRIORITY", priority)
 
@@ -137,7 +146,7 @@ func (w *JournalWriter) WriteEntry(e *Entry) (n int, err error) {
 		print(true, kv.Key, kv.Value)
 	}
 
-	print(false, "JSON", b2s(e.buf))
+	print(false, "JSON", b2s(json))
 
 	// write
 	n, _, err = w.conn.WriteMsgUnix(b.B, nil, w.addr)
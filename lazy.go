@@ -0,0 +1,52 @@
+package log
+
+// LogValuer is implemented by values which resolve to the value to log. Any defers
+// the call of LogValue until the entry is written, see Entry.Lazy.
+type LogValuer interface {
+	LogValue() any
+}
+
+// maxLogValuerDepth limits the resolving of a LogValuer which returns a LogValuer.
+const maxLogValuerDepth = 8
+
+type lazyField struct {
+	key string
+	fn  func() any
+}
+
+// Lazy adds the field key with the value returned by fn as an any value. fn is only called
+// when the entry is written, after it survives level filtering, sampling and hooks, so lazy
+// fields are added after the other fields and are not visible to hooks, e.g.
+//
+//	log.Debug().Lazy("diff", func() any { return computeDiff(old, new) }).Msg("state changed")
+func (e *Entry) Lazy(key string, fn func() any) *Entry {
+	if e == nil || fn == nil {
+		return e
+	}
+
+	e.lazy = append(e.lazy, lazyField{key, fn})
+	return e
+}
+
+// resolve adds the lazy fields of the entry.
+func (e *Entry) resolve() {
+	// fields resolved to a LogValuer are appended to e.lazy again by Any
+	for i := 0; i < len(e.lazy); i++ {
+		key, value := e.lazy[i].key, e.lazy[i].fn()
+		for depth := 0; depth < maxLogValuerDepth; depth++ {
+			valuer, ok := value.(LogValuer)
+			if !ok {
+				break
+			}
+			value = valuer.LogValue()
+		}
+		if _, ok := value.(LogValuer); ok {
+			value = nil
+		}
+		e.Any(key, value)
+	}
+	for i := range e.lazy {
+		e.lazy[i] = lazyField{}
+	}
+	e.lazy = e.lazy[:0]
+}
@@ -0,0 +1,87 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+type lazyValuer struct {
+	calls *int
+	value any
+}
+
+func (v lazyValuer) LogValue() any {
+	*v.calls++
+	return v.value
+}
+
+type loopValuer struct{}
+
+func (loopValuer) LogValue() any { return loopValuer{} }
+
+func TestLazy(t *testing.T) {
+	cases := []struct {
+		name  string
+		entry func(l *Logger, calls *int) *Entry
+		calls int
+		want  string
+	}{
+		{"written", func(l *Logger, calls *int) *Entry {
+			return l.Info().Lazy("diff", func() any { *calls++; return []int{1, 2} }).Str("a", "b")
+		}, 1, `{"time":"2019-07-10T05:35:54.277Z","level":"info","a":"b","diff":[1,2],"message":"hi"}`},
+		{"level", func(l *Logger, calls *int) *Entry {
+			return l.Trace().Lazy("diff", func() any { *calls++; return 1 })
+		}, 0, ``},
+		{"sampled", func(l *Logger, calls *int) *Entry {
+			l.Sampler = &BurstSampler{Burst: 1, Period: time.Hour}
+			var e *Entry
+			for _, msg := range []string{"hi", ""} {
+				if e = l.Info(); msg != "" {
+					e.Msg(msg)
+				}
+			}
+			return e.Lazy("diff", func() any { *calls++; return 1 })
+		}, 0, `{"time":"2019-07-10T05:35:54.277Z","level":"info","message":"hi"}`},
+		{"vetoed", func(l *Logger, calls *int) *Entry {
+			l.Hooks = []Hook{HookFunc(func(e *Entry, msg string) bool {
+				_, ok := e.Lookup("diff")
+				return ok
+			})}
+			return l.Info().Lazy("diff", func() any { *calls++; return 1 })
+		}, 0, ``},
+		{"valuer", func(l *Logger, calls *int) *Entry {
+			return l.Info().Any("user", lazyValuer{calls, lazyValuer{calls, "alice"}})
+		}, 2, `{"time":"2019-07-10T05:35:54.277Z","level":"info","user":"alice","message":"hi"}`},
+		{"valuer-loop", func(l *Logger, calls *int) *Entry {
+			return l.Info().Any("loop", loopValuer{})
+		}, 0, `{"time":"2019-07-10T05:35:54.277Z","level":"info","loop":null,"message":"hi"}`},
+		{"nil-func", func(l *Logger, calls *int) *Entry {
+			return l.Info().Lazy("diff", nil)
+		}, 0, `{"time":"2019-07-10T05:35:54.277Z","level":"info","message":"hi"}`},
+		{"value", func(l *Logger, calls *int) *Entry {
+			ctx := NewContext(nil).Lazy("n", func() any { *calls++; return 1 }).Value()
+			return l.Info().Context(ctx)
+		}, 1, `{"time":"2019-07-10T05:35:54.277Z","level":"info","n":1,"message":"hi"}`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			logger.Level = DebugLevel
+			var calls int
+			c.entry(&logger, &calls).Msg("hi")
+			if calls != c.calls {
+				t.Errorf("called %d times, want %d", calls, c.calls)
+			}
+			want := c.want
+			if want != "" {
+				want += "\n"
+			}
+			if got := b.String(); got != want {
+				t.Errorf("got %s\nwant %s", got, want)
+			}
+		})
+	}
+}
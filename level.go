@@ -1,5 +1,13 @@
 package log
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
 // Level defines log levels.
 type Level uint32
 
@@ -40,7 +48,11 @@ func (l Level) String() (s string) {
 	case PanicLevel:
 		s = "panic"
 	default:
-		s = "????"
+		if info, ok := LookupLevel(l); ok {
+			s = info.Name
+		} else {
+			s = "????"
+		}
 	}
 	return
 }
@@ -63,7 +75,160 @@ func ParseLevel(s string) (level Level) {
 	case "panic", "Panic", "PANIC", "PNC":
 		level = PanicLevel
 	default:
-		level = noLevel
+		level = parseCustomLevel(s)
 	}
 	return
 }
+
+// LevelInfo describes a custom level registered by RegisterLevel.
+type LevelInfo struct {
+	// Name specifies the value of the level field, e.g. "notice". It is parsed case-insensitively.
+	Name string
+
+	// Code specifies the short code of ConsoleWriter, e.g. "NTC". It uses the upper case Name if empty.
+	Code string
+
+	// Color specifies the ANSI color of ConsoleWriter, e.g. "\x1b[36m". It uses gray if empty.
+	Color string
+
+	// Rank specifies the builtin level which the level is filtered, sampled and routed as,
+	// e.g. InfoLevel for notice. It must be from TraceLevel to PanicLevel.
+	Rank Level
+
+	// Severity specifies the syslog severity of SyslogWriter, JournalWriter and gelf,
+	// from 0 (emergency) to 7 (debug).
+	Severity int
+}
+
+// levelRegistry is a copy-on-write registry of custom levels.
+type levelRegistry struct {
+	infos []LevelInfo      // infos[i] describes the level noLevel+1+i
+	names map[string]Level // lower case names and upper case codes
+}
+
+var (
+	levelsMu sync.Mutex
+	levels   atomic.Pointer[levelRegistry]
+)
+
+// RegisterLevel registers a custom level described by info and returns it, e.g.
+//
+//	var NoticeLevel = log.MustRegisterLevel(log.LevelInfo{Name: "notice", Code: "NTC", Rank: log.InfoLevel, Severity: 5})
+//	var AuditLevel = log.MustRegisterLevel(log.LevelInfo{Name: "audit", Code: "AUD", Rank: log.ErrorLevel, Severity: 5})
+//
+//	logger.WithLevel(NoticeLevel).Msg("configuration changed")
+//
+// Custom levels are written by the level methods of Logger, such as WithLevel, and never
+// terminate the program even if they rank as FatalLevel or PanicLevel.
+func RegisterLevel(info LevelInfo) (Level, error) {
+	if info.Name == "" {
+		return noLevel, errors.New("empty level name")
+	}
+	if info.Rank < TraceLevel || info.Rank > PanicLevel {
+		return noLevel, fmt.Errorf("invalid rank %d of level %q", info.Rank, info.Name)
+	}
+	if info.Severity < 0 || info.Severity > 7 {
+		return noLevel, fmt.Errorf("invalid severity %d of level %q", info.Severity, info.Name)
+	}
+	if info.Code == "" {
+		info.Code = strings.ToUpper(info.Name)
+	}
+
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+
+	for _, name := range []string{info.Name, info.Code} {
+		if ParseLevel(name) != noLevel {
+			return noLevel, fmt.Errorf("level %q is already registered", name)
+		}
+	}
+
+	r := &levelRegistry{names: make(map[string]Level)}
+	if old := levels.Load(); old != nil {
+		r.infos = append(r.infos, old.infos...)
+		for name, level := range old.names {
+			r.names[name] = level
+		}
+	}
+	r.infos = append(r.infos, info)
+	level := noLevel + Level(len(r.infos))
+	r.names[strings.ToLower(info.Name)] = level
+	r.names[strings.ToUpper(info.Code)] = level
+	levels.Store(r)
+
+	return level, nil
+}
+
+// MustRegisterLevel is like RegisterLevel but panics if the level cannot be registered.
+func MustRegisterLevel(info LevelInfo) Level {
+	level, err := RegisterLevel(info)
+	if err != nil {
+		panic(err)
+	}
+	return level
+}
+
+// LookupLevel returns the info of custom level l registered by RegisterLevel.
+func LookupLevel(l Level) (info LevelInfo, ok bool) {
+	if l <= noLevel {
+		return
+	}
+	r := levels.Load()
+	if r == nil || int(l-noLevel) > len(r.infos) {
+		return
+	}
+	return r.infos[l-noLevel-1], true
+}
+
+// rank returns the builtin level which l is filtered as.
+func (l Level) rank() Level {
+	if info, ok := LookupLevel(l); ok {
+		return info.Rank
+	}
+	return l
+}
+
+// severity returns the syslog severity of l, or def if l is not a custom level.
+func (l Level) severity(def int) int {
+	if info, ok := LookupLevel(l); ok {
+		return info.Severity
+	}
+	return def
+}
+
+// syslog returns the syslog severity of l.
+func (l Level) syslog() int {
+	switch l {
+	case TraceLevel, DebugLevel:
+		return 7 // LOG_DEBUG
+	case InfoLevel:
+		return 6 // LOG_INFO
+	case WarnLevel:
+		return 4 // LOG_WARNING
+	case ErrorLevel:
+		return 3 // LOG_ERR
+	case FatalLevel:
+		return 2 // LOG_CRIT
+	case PanicLevel:
+		return 1 // LOG_ALERT
+	}
+	return l.severity(6)
+}
+
+// parseCustomLevel returns the custom level of name or code s.
+func parseCustomLevel(s string) Level {
+	r := levels.Load()
+	if r == nil {
+		return noLevel
+	}
+	if level, ok := r.names[s]; ok {
+		return level
+	}
+	if level, ok := r.names[strings.ToLower(s)]; ok {
+		return level
+	}
+	if level, ok := r.names[strings.ToUpper(s)]; ok {
+		return level
+	}
+	return noLevel
+}
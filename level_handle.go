@@ -0,0 +1,193 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LevelHandle is a level shared by loggers, see Logger.LevelHandle. A handle without
+// its own level inherits the level of its parent, so changes of the parent apply to
+// every child which is not set.
+type LevelHandle struct {
+	level  uint32
+	parent *LevelHandle
+	base   *Level
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	expires time.Time
+}
+
+// NewLevelHandle returns a new LevelHandle with level.
+func NewLevelHandle(level Level) *LevelHandle {
+	return &LevelHandle{level: uint32(level)}
+}
+
+// Child returns a new LevelHandle inheriting the level of h until it is set.
+func (h *LevelHandle) Child() *LevelHandle {
+	return &LevelHandle{parent: h}
+}
+
+// Level returns the level of h, or of the nearest parent which is set.
+func (h *LevelHandle) Level() Level {
+	for ; h != nil; h = h.parent {
+		if level := atomic.LoadUint32(&h.level); level != 0 {
+			return Level(level)
+		}
+		if h.base != nil {
+			// the root of the categories of a logger without handle, see Logger.categories
+			return Level(atomic.LoadUint32((*uint32)(h.base)))
+		}
+	}
+	return 0
+}
+
+// SetLevel changes the level of h and cancels a pending revert of SetLevelFor.
+// The level 0 makes h inherit the level of its parent again.
+func (h *LevelHandle) SetLevel(level Level) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stop()
+	atomic.StoreUint32(&h.level, uint32(level))
+}
+
+// SetLevelFor changes the level of h for ttl, after which the previous level is restored.
+func (h *LevelHandle) SetLevelFor(level Level, ttl time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.timer == nil {
+		previous := atomic.LoadUint32(&h.level)
+		var timer *time.Timer
+		timer = time.AfterFunc(ttl, func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			if h.timer == timer {
+				h.timer = nil
+				h.expires = time.Time{}
+				atomic.StoreUint32(&h.level, previous)
+			}
+		})
+		h.timer = timer
+	} else {
+		h.timer.Reset(ttl)
+	}
+	h.expires = timeNow().Add(ttl)
+	atomic.StoreUint32(&h.level, uint32(level))
+}
+
+// Expires returns the time when the level set by SetLevelFor reverts, or zero time if none.
+func (h *LevelHandle) Expires() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.expires
+}
+
+func (h *LevelHandle) stop() {
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+		h.expires = time.Time{}
+	}
+}
+
+// LevelHandler is a http.Handler which gets and sets the level of a LevelHandle as JSON.
+//
+//	GET /log/level?category=db
+//	PUT /log/level {"level":"debug","ttl":"10m","category":"db"}
+//
+// The response is in the form of {"level":"debug","category":"db","expires":"2006-01-02T15:04:05Z"}.
+// A PUT without ttl sets the level permanently, the level "" of a category makes it
+// inherit the root level again.
+type LevelHandler struct {
+	// Handle specifies the root level handle.
+	Handle *LevelHandle
+
+	// Category specifies an optional resolver of level handles by category.
+	// It uses the category handles of the root logger whose LevelHandle is Handle if empty,
+	// a GET of a category without handle responds 404 and a PUT creates it.
+	Category func(name string) *LevelHandle
+}
+
+type levelRequest struct {
+	Level    string `json:"level"`
+	TTL      string `json:"ttl,omitempty"`
+	Category string `json:"category,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+}
+
+// ServeHTTP implements http.Handler.
+func (h *LevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req levelRequest
+	switch r.Method {
+	case http.MethodGet:
+		req.Category = r.URL.Query().Get("category")
+	case http.MethodPut, http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	handle := h.Handle
+	if req.Category != "" {
+		handle = h.category(req.Category, r.Method != http.MethodGet)
+	}
+	if handle == nil {
+		http.Error(w, "unknown category: "+req.Category, http.StatusNotFound)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		var level Level
+		if req.Level != "" || req.Category == "" {
+			if level = ParseLevel(req.Level); level == noLevel {
+				http.Error(w, "invalid level: "+req.Level, http.StatusBadRequest)
+				return
+			}
+		}
+		if req.TTL != "" {
+			ttl, err := time.ParseDuration(req.TTL)
+			if err != nil || ttl <= 0 {
+				http.Error(w, "invalid ttl: "+req.TTL, http.StatusBadRequest)
+				return
+			}
+			handle.SetLevelFor(level, ttl)
+		} else {
+			handle.SetLevel(level)
+		}
+	}
+
+	resp := levelRequest{
+		Level:    handle.Level().String(),
+		Category: req.Category,
+	}
+	if expires := handle.Expires(); !expires.IsZero() {
+		resp.Expires = expires.UTC().Format(time.RFC3339)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// category returns the level handle of category name, it is only created if create.
+func (h *LevelHandler) category(name string, create bool) *LevelHandle {
+	if h.Category != nil {
+		return h.Category(name)
+	}
+	if h.Handle == nil {
+		return nil
+	}
+	r := h.Handle.registry()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !create {
+		return r.handles[name]
+	}
+	return r.handle(name)
+}
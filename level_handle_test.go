@@ -0,0 +1,91 @@
+package log
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLevelHandle(t *testing.T) {
+	root := NewLevelHandle(InfoLevel)
+	child := root.Child()
+	grandchild := child.Child()
+
+	cases := []struct {
+		name  string
+		set   func()
+		root  Level
+		child Level
+		grand Level
+	}{
+		{"inherit", func() {}, InfoLevel, InfoLevel, InfoLevel},
+		{"root", func() { root.SetLevel(WarnLevel) }, WarnLevel, WarnLevel, WarnLevel},
+		{"child", func() { child.SetLevel(DebugLevel) }, WarnLevel, DebugLevel, DebugLevel},
+		{"grandchild", func() { grandchild.SetLevel(ErrorLevel) }, WarnLevel, DebugLevel, ErrorLevel},
+		{"unset", func() { child.SetLevel(0) }, WarnLevel, WarnLevel, ErrorLevel},
+	}
+	for _, c := range cases {
+		c.set()
+		if got := [3]Level{root.Level(), child.Level(), grandchild.Level()}; got != [3]Level{c.root, c.child, c.grand} {
+			t.Errorf("%s: levels = %v, want %v", c.name, got, [3]Level{c.root, c.child, c.grand})
+		}
+	}
+}
+
+func TestLevelHandleSetLevelFor(t *testing.T) {
+	h := NewLevelHandle(InfoLevel)
+	h.SetLevelFor(DebugLevel, 20*time.Millisecond)
+	if h.Level() != DebugLevel || h.Expires().IsZero() {
+		t.Fatalf("level = %v, expires = %v", h.Level(), h.Expires())
+	}
+	time.Sleep(60 * time.Millisecond)
+	if h.Level() != InfoLevel || !h.Expires().IsZero() {
+		t.Errorf("level = %v, expires = %v after ttl, want info", h.Level(), h.Expires())
+	}
+
+	h.SetLevelFor(DebugLevel, time.Hour)
+	h.SetLevel(WarnLevel)
+	if h.Level() != WarnLevel || !h.Expires().IsZero() {
+		t.Errorf("SetLevel did not cancel SetLevelFor: level = %v, expires = %v", h.Level(), h.Expires())
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	logger := Logger{LevelHandle: NewLevelHandle(InfoLevel)}
+	logger.Categorized("db.pool")
+	handler := &LevelHandler{Handle: logger.LevelHandle}
+
+	cases := []struct {
+		method string
+		target string
+		body   string
+		code   int
+		want   string
+	}{
+		{"GET", "/", "", 200, `{"level":"info"}`},
+		{"GET", "/?category=db.pool", "", 200, `{"level":"info","category":"db.pool"}`},
+		{"GET", "/?category=http", "", 404, "unknown category: http"},
+		{"PUT", "/", `{"level":"warn"}`, 200, `{"level":"warn"}`},
+		{"GET", "/?category=db", "", 200, `{"level":"warn","category":"db"}`},
+		{"PUT", "/", `{"category":"db","level":"debug"}`, 200, `{"level":"debug","category":"db"}`},
+		{"GET", "/?category=db.pool", "", 200, `{"level":"debug","category":"db.pool"}`},
+		{"PUT", "/", `{"category":"http","level":"error"}`, 200, `{"level":"error","category":"http"}`},
+		{"GET", "/?category=http", "", 200, `{"level":"error","category":"http"}`},
+		{"PUT", "/", `{"category":"db","level":""}`, 200, `{"level":"warn","category":"db"}`},
+		{"PUT", "/", `{"level":""}`, 400, "invalid level: "},
+		{"PUT", "/", `{"level":"info","ttl":"-1s"}`, 400, "invalid ttl: -1s"},
+		{"PUT", "/", `{`, 400, "invalid request"},
+		{"DELETE", "/", "", 405, "method not allowed"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(c.method, c.target, strings.NewReader(c.body)))
+		if w.Code != c.code || !strings.HasPrefix(w.Body.String(), c.want) {
+			t.Errorf("%s %s %s: got %d %s, want %d %s", c.method, c.target, c.body, w.Code, w.Body, c.code, c.want)
+		}
+	}
+	if logger.LevelHandle.Level() != WarnLevel {
+		t.Errorf("root level = %v, want warn", logger.LevelHandle.Level())
+	}
+}
@@ -0,0 +1,138 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testNoticeLevel and testAuditLevel are registered once, levels cannot be unregistered.
+var (
+	testNoticeLevel = MustRegisterLevel(LevelInfo{Name: "notice", Code: "NTC", Rank: InfoLevel, Severity: 5})
+	testAuditLevel  = MustRegisterLevel(LevelInfo{Name: "audit", Rank: ErrorLevel, Severity: 4})
+)
+
+func TestRegisterLevel(t *testing.T) {
+	cases := []struct {
+		name string
+		info LevelInfo
+		err  string
+	}{
+		{"empty", LevelInfo{Rank: InfoLevel}, "empty level name"},
+		{"rank", LevelInfo{Name: "verbose", Rank: noLevel}, `invalid rank 8 of level "verbose"`},
+		{"severity", LevelInfo{Name: "verbose", Rank: DebugLevel, Severity: 8}, `invalid severity 8 of level "verbose"`},
+		{"builtin", LevelInfo{Name: "Info", Rank: InfoLevel}, `level "Info" is already registered`},
+		{"builtin-code", LevelInfo{Name: "warned", Code: "WRN", Rank: WarnLevel}, `level "WRN" is already registered`},
+		{"custom", LevelInfo{Name: "NOTICE", Rank: InfoLevel}, `level "NOTICE" is already registered`},
+		{"custom-code", LevelInfo{Name: "note", Code: "ntc", Rank: InfoLevel}, `level "ntc" is already registered`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			level, err := RegisterLevel(c.info)
+			if err == nil || err.Error() != c.err {
+				t.Fatalf("RegisterLevel() error = %v, want %s", err, c.err)
+			}
+			if level != noLevel {
+				t.Errorf("RegisterLevel() = %d, want noLevel", level)
+			}
+		})
+	}
+}
+
+func TestCustomLevel(t *testing.T) {
+	cases := []struct {
+		name     string
+		level    Level
+		str      string
+		parse    []string
+		rank     Level
+		severity int
+	}{
+		{"notice", testNoticeLevel, "notice", []string{"notice", "Notice", "NOTICE", "NTC", "ntc"}, InfoLevel, 5},
+		{"audit", testAuditLevel, "audit", []string{"audit", "AUDIT"}, ErrorLevel, 4},
+		{"builtin", WarnLevel, "warn", []string{"warn", "WRN"}, WarnLevel, 3},
+		{"unknown", testAuditLevel + 100, "????", []string{"verbose"}, testAuditLevel + 100, 3},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.level.String(); got != c.str {
+				t.Errorf("String() = %q, want %q", got, c.str)
+			}
+			want := c.level
+			if c.str == "????" {
+				want = noLevel
+			}
+			for _, s := range c.parse {
+				if got := ParseLevel(s); got != want {
+					t.Errorf("ParseLevel(%q) = %d, want %d", s, got, want)
+				}
+			}
+			if got := c.level.rank(); got != c.rank {
+				t.Errorf("rank() = %d, want %d", got, c.rank)
+			}
+			if got := c.level.severity(3); got != c.severity {
+				t.Errorf("severity() = %d, want %d", got, c.severity)
+			}
+		})
+	}
+}
+
+func TestCustomLevelLogger(t *testing.T) {
+	cases := []struct {
+		name  string
+		level Level
+		want  string
+	}{
+		{"trace", TraceLevel, `{"time":"2019-07-10T05:35:54.277Z","level":"notice","message":"changed"}` + "\n" +
+			`{"time":"2019-07-10T05:35:54.277Z","level":"audit","message":"granted"}` + "\n"},
+		{"info", InfoLevel, `{"time":"2019-07-10T05:35:54.277Z","level":"notice","message":"changed"}` + "\n" +
+			`{"time":"2019-07-10T05:35:54.277Z","level":"audit","message":"granted"}` + "\n"},
+		{"warn", WarnLevel, `{"time":"2019-07-10T05:35:54.277Z","level":"audit","message":"granted"}` + "\n"},
+		{"notice", testNoticeLevel, `{"time":"2019-07-10T05:35:54.277Z","level":"notice","message":"changed"}` + "\n" +
+			`{"time":"2019-07-10T05:35:54.277Z","level":"audit","message":"granted"}` + "\n"},
+		{"audit", testAuditLevel, `{"time":"2019-07-10T05:35:54.277Z","level":"audit","message":"granted"}` + "\n"},
+		{"fatal", FatalLevel, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			logger.Level = c.level
+
+			logger.WithLevel(testNoticeLevel).Msg("changed")
+			logger.WithLevel(testAuditLevel).Msg("granted")
+
+			if got := b.String(); got != c.want {
+				t.Errorf("got %s, want %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestCustomLevelConsole(t *testing.T) {
+	cases := []struct {
+		name  string
+		level Level
+		want  string
+	}{
+		{"code", testNoticeLevel, "NTC"},
+		{"name", testAuditLevel, "AUDIT"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			logger.Writer = &ConsoleWriter{Writer: &b}
+
+			logger.WithLevel(c.level).Msg("hello")
+
+			if got := b.String(); !strings.Contains(got, " "+c.want+" ") {
+				t.Errorf("got %q, want code %s", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// logfmtQuotes are the bytes of values which are quoted in logfmt, i.e. spaces, '=',
+// control characters and the bytes escaped in JSON strings.
+var logfmtQuotes = func() (quotes [256]bool) {
+	for c := range quotes {
+		quotes[c] = c <= ' ' || c == '=' || c == 0x7f || escapes[c]
+	}
+	return
+}()
+
+// logfmtNeedsQuote reports whether the logfmt value s is quoted.
+func logfmtNeedsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for i := 0; i < len(s); i++ {
+		if logfmtQuotes[s[i]] {
+			return true
+		}
+	}
+	return false
+}
+
+// logfmtAppendName appends key to dst, the bytes which are quoted in values are replaced with '_'.
+func logfmtAppendName(dst []byte, key string) []byte {
+	n := len(dst)
+	dst = append(dst, key...)
+	for i := n; i < len(dst); i++ {
+		if logfmtQuotes[dst[i]] {
+			dst[i] = '_'
+		}
+	}
+	return dst
+}
+
+// logfmtAppendKey appends the key of a logfmt pair with the dotted prefix of its nested objects.
+func logfmtAppendKey(dst []byte, prefix []byte, key string) []byte {
+	dst = append(dst, ' ')
+	dst = append(dst, prefix...)
+	dst = logfmtAppendName(dst, key)
+	return append(dst, '=')
+}
+
+// logfmtAppendString appends s as a logfmt value, quoted with JSON escapes if needed.
+func logfmtAppendString(dst []byte, s string) []byte {
+	if !logfmtNeedsQuote(s) {
+		return append(dst, s...)
+	}
+	e := Entry{buf: append(dst, '"')}
+	e.string(s)
+	return append(e.buf, '"')
+}
+
+// logfmtKey adds the key of a field with the prefix of the nested objects.
+func (e *Entry) logfmtKey(key string) {
+	e.buf = logfmtAppendKey(e.buf, e.prefix, key)
+}
+
+// logfmtString adds the value s, quoted if needed.
+func (e *Entry) logfmtString(s string) {
+	if logfmtNeedsQuote(s) {
+		e.buf = append(e.buf, '"')
+		e.string(s)
+		e.buf = append(e.buf, '"')
+		return
+	}
+	e.string(s)
+}
+
+// logfmtQuote quotes the value added since n if needed, e.g. a JSON array.
+func (e *Entry) logfmtQuote(n int) {
+	if !logfmtNeedsQuote(b2s(e.buf[n:])) {
+		return
+	}
+	b := bbpool.Get().(*bb)
+	b.B = append(b.B[:0], e.buf[n:]...)
+	e.buf = logfmtAppendString(e.buf[:n], b2s(b.B))
+	if cap(b.B) <= bbcap {
+		bbpool.Put(b)
+	}
+}
+
+// logfmtHeader adds the level and the contexts of l to e after the time written by header,
+// the time is unquoted unless needed.
+func (l *Logger) logfmtHeader(e *Entry, level Level) {
+	if n := len(l.timeField()) + 1; n < len(e.buf)-1 && e.buf[n] == '"' {
+		if t := e.buf[n+1 : len(e.buf)-1]; !logfmtNeedsQuote(b2s(t)) {
+			e.buf = append(e.buf[:n], t...)
+		}
+	}
+	e.levelAt = len(e.buf)
+	e.buf = l.Schema.logfmtAppendLevel(e.buf, level)
+	if l.Schema != nil {
+		e.buf = logfmtAppendFields(e.buf, nil, l.Schema.Context)
+	}
+	if l.Context != nil {
+		e.appendContext(l)
+	}
+}
+
+// logfmtAppendLevel appends the level field of level like appendLevel.
+func (s *Schema) logfmtAppendLevel(dst []byte, level Level) []byte {
+	if level == noLevel {
+		return dst
+	}
+	dst = logfmtAppendKey(dst, nil, s.levelKey())
+	var style LevelStyle
+	if s != nil {
+		style = s.LevelStyle
+	}
+	switch style {
+	case LevelStyleNumeric:
+		return strconv.AppendUint(dst, uint64(level), 10)
+	case LevelStyleSyslog:
+		return strconv.AppendInt(dst, int64(level.syslog()), 10)
+	case LevelStyleGCP:
+		return append(dst, gcpSeverities[level.syslog()]...)
+	case LevelStyleUpper:
+		return logfmtAppendString(dst, strings.ToUpper(level.String()))
+	}
+	return logfmtAppendString(dst, level.String())
+}
+
+// logfmtCaller adds the caller fields of file, line and function name like caller.
+func (e *Entry) logfmtCaller(file string, line int, name string) {
+	s := e.schema()
+	switch {
+	case s != nil && s.SourceLocationKey != "":
+		n := len(e.prefix)
+		e.prefix = append(logfmtAppendName(e.prefix, s.SourceLocationKey), '.')
+		e.logfmtKey("file")
+		e.logfmtString(file)
+		e.logfmtKey("line")
+		e.buf = strconv.AppendInt(e.buf, int64(line), 10)
+		e.logfmtKey("function")
+		e.logfmtString(name)
+		e.prefix = e.prefix[:n]
+	case s != nil && s.CallerLineKey != "":
+		e.logfmtKey(s.callerKey())
+		e.logfmtString(file)
+		e.logfmtKey(s.CallerLineKey)
+		e.buf = strconv.AppendInt(e.buf, int64(line), 10)
+		e.logfmtKey(s.callerFuncKey())
+		e.logfmtString(name)
+	default:
+		e.logfmtKey(s.callerKey())
+		n := len(e.buf)
+		e.buf = append(e.buf, file...)
+		e.buf = append(e.buf, ':')
+		e.buf = strconv.AppendInt(e.buf, int64(line), 10)
+		e.logfmtQuote(n)
+		e.logfmtKey(s.callerFuncKey())
+		e.logfmtString(name)
+	}
+	e.logfmtKey(s.goidKey())
+	e.buf = strconv.AppendInt(e.buf, int64(goid()), 10)
+}
+
+// logfmtAppendFields appends the JSON fields of ctx, e.g. `,"a":1,"b":{"c":"d"}`, as logfmt
+// pairs of prefix, e.g. ` a=1 b.c=d`.
+func logfmtAppendFields(dst []byte, prefix []byte, ctx []byte) []byte {
+	for i := 0; i < len(ctx); i++ {
+		if ctx[i] != '"' {
+			continue
+		}
+		j, key, esc, ok := jsonParseString(ctx, i+1)
+		if !ok {
+			break
+		}
+		j = skipSpaces(ctx, j)
+		if j < len(ctx) && ctx[j] == ':' {
+			j++
+		}
+		dst, i = logfmtAppendJSON(dst, prefix, logfmtJSONKey(key, esc), ctx, j)
+		i--
+	}
+	return dst
+}
+
+// logfmtAppendJSON appends the JSON value of json at i as the logfmt pair of key, objects are
+// flattened to the pairs of their members. It returns the index after the value.
+func logfmtAppendJSON(dst []byte, prefix []byte, key string, json []byte, i int) ([]byte, int) {
+	i = skipSpaces(json, i)
+	if i < len(json) && json[i] == '{' {
+		prefix = append(logfmtAppendName(prefix, key), '.')
+		for i++; i < len(json); {
+			switch json[i] {
+			case '}':
+				return dst, i + 1
+			case '"':
+			default:
+				i++
+				continue
+			}
+			j, k, esc, ok := jsonParseString(json, i+1)
+			if !ok {
+				break
+			}
+			j = skipSpaces(json, j)
+			if j < len(json) && json[j] == ':' {
+				j++
+			}
+			dst, i = logfmtAppendJSON(dst, prefix, logfmtJSONKey(k, esc), json, j)
+		}
+		return dst, len(json)
+	}
+
+	dst = logfmtAppendKey(dst, prefix, key)
+	if i >= len(json) {
+		return append(dst, "null"...), i
+	}
+	j, typ, val, ok := jsonParseAny(json, i, true)
+	if !ok {
+		return append(dst, "null"...), j
+	}
+	switch typ {
+	case 'o':
+		return logfmtAppendString(dst, b2s(val)), j
+	case 's':
+		if s := val[1 : len(val)-1]; !logfmtNeedsQuote(b2s(s)) {
+			return append(dst, s...), j
+		}
+	}
+	// numbers, literals and quoted strings are valid logfmt values
+	return append(dst, val...), j
+}
+
+// logfmtJSONKey returns the quoted JSON string key, unescaped if esc.
+func logfmtJSONKey(key []byte, esc bool) string {
+	key = key[1 : len(key)-1]
+	if esc {
+		return string(jsonUnescape(key, nil))
+	}
+	return b2s(key)
+}
+
+// logfmtPair returns the key and the raw value of the logfmt pair of src at i, and the
+// index after it. The value is nil for keys without values.
+func logfmtPair(src []byte, i int) (key, val []byte, j int) {
+	for i < len(src) && src[i] <= ' ' {
+		i++
+	}
+	j = i
+	for j < len(src) && src[j] > ' ' && src[j] != '=' {
+		j++
+	}
+	key = src[i:j]
+	if j >= len(src) || src[j] != '=' {
+		return key, nil, j
+	}
+	j++
+	k := j
+	if k < len(src) && src[k] == '"' {
+		k, _, _, _ = jsonParseString(src, k+1)
+	} else {
+		for k < len(src) && src[k] > ' ' {
+			k++
+		}
+	}
+	return key, src[j:k], k
+}
+
+// logfmtValue returns the logfmt value val, unquoted and unescaped if quoted.
+func logfmtValue(val []byte) string {
+	if len(val) >= 2 && val[0] == '"' {
+		return string(jsonUnescape(val[1:len(val)-1], nil))
+	}
+	return string(val)
+}
+
+// logfmtToJSON appends the logfmt lines of src to dst as JSON objects, one line per line.
+// Values which are valid JSON, e.g. quoted strings, numbers and arrays, are kept, other
+// values, e.g. unterminated quotes, are strings and keys without values are true.
+func logfmtToJSON(dst, src []byte) []byte {
+	for len(src) != 0 {
+		line := src
+		if i := bytes.IndexByte(src, '\n'); i >= 0 {
+			line, src = src[:i], src[i+1:]
+		} else {
+			src = nil
+		}
+		dst = append(dst, '{')
+		first := true
+		for i := 0; i < len(line); {
+			key, val, j := logfmtPair(line, i)
+			i = j
+			if len(key) == 0 && val == nil {
+				continue
+			}
+			if !first {
+				dst = append(dst, ',')
+			}
+			first = false
+			e := Entry{buf: append(dst, '"')}
+			e.bytes(key)
+			dst = logfmtAppendValueJSON(append(e.buf, '"', ':'), val)
+		}
+		dst = append(dst, '}', '\n')
+	}
+	return dst
+}
+
+// logfmtAppendValueJSON appends the logfmt value val as JSON like logfmtToJSON.
+func logfmtAppendValueJSON(dst, val []byte) []byte {
+	switch {
+	case val == nil:
+		return append(dst, "true"...)
+	case json.Valid(val):
+		return append(dst, val...)
+	}
+	e := Entry{buf: append(dst, '"')}
+	e.bytes(val)
+	return append(e.buf, '"')
+}
+
+// logfmtTranscode converts the JSON entry to logfmt, e.g. the entries of the handler of Slog.
+func (e *Entry) logfmtTranscode() {
+	b := bbpool.Get().(*bb)
+	b.B = b.B[:0]
+	if len(e.buf) != 0 && e.buf[0] == '{' {
+		b.B = logfmtAppendFields(b.B, nil, e.buf[1:])
+	}
+	if len(b.B) != 0 && b.B[0] == ' ' {
+		b.B = append(b.B[:0], b.B[1:]...)
+	}
+	b.B = append(b.B, '\n')
+	e.buf, b.B = b.B, e.buf
+	e.logfmt = true
+	if cap(b.B) <= bbcap {
+		bbpool.Put(b)
+	}
+}
@@ -0,0 +1,115 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestLogfmtQuote(t *testing.T) {
+	cases := []struct {
+		name   string
+		fields func(e *Entry) *Entry
+		want   string
+	}{
+		{"plain", func(e *Entry) *Entry { return e.Str("s", "abc") }, `s=abc`},
+		{"empty", func(e *Entry) *Entry { return e.Str("s", "") }, `s=""`},
+		{"space", func(e *Entry) *Entry { return e.Str("s", "a b") }, `s="a b"`},
+		{"equal", func(e *Entry) *Entry { return e.Str("s", "a=b") }, `s="a=b"`},
+		{"quote", func(e *Entry) *Entry { return e.Str("s", `a"b`) }, `s="a\"b"`},
+		{"backslash", func(e *Entry) *Entry { return e.Str("s", `a\b`) }, `s="a\\b"`},
+		{"control", func(e *Entry) *Entry { return e.Str("s", "a\nb\tc") }, `s="a\nb\tc"`},
+		{"unicode", func(e *Entry) *Entry { return e.Str("s", "héllo") }, `s=héllo`},
+		{"key", func(e *Entry) *Entry { return e.Str("a key=x", "v") }, `a_key_x=v`},
+		{"scalars", func(e *Entry) *Entry { return e.Int("n", -1).Float64("f", 1.5).Bool("ok", true) }, `n=-1 f=1.5 ok=true`},
+		{"array", func(e *Entry) *Entry { return e.Strs("ss", []string{"x", "y z"}) }, `ss="[\"x\",\"y z\"]"`},
+		{"dict", func(e *Entry) *Entry {
+			return e.Dict("req", NewContext(nil).Str("m", "GET").Dict("p", NewContext(nil).Int("port", 80).Value()).Value())
+		}, `req.m=GET req.p.port=80`},
+		{"raw", func(e *Entry) *Entry { return e.RawJSON("raw", []byte(`{"a":[1],"b":"c d"}`)) }, `raw.a=[1] raw.b="c d"`},
+		{"error", func(e *Entry) *Entry { return e.Err(errors.New("conn refused")) }, `error="conn refused"`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			logger.Encoding = EncodingLogfmt
+
+			c.fields(logger.Info()).Msg("hello world")
+
+			want := `time=2019-07-10T05:35:54.277Z level=info ` + c.want + ` message="hello world"` + "\n"
+			if got := b.String(); got != want {
+				t.Errorf("got  %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+func TestLogfmtToJSON(t *testing.T) {
+	cases := []struct {
+		name   string
+		fields func(e *Entry) *Entry
+		want   string
+	}{
+		{"strings", func(e *Entry) *Entry {
+			return e.Str("empty", "").Str("space", "a b").Str("quote", `a"b`).Str("nl", "a\nb").Str("uni", "héllo")
+		}, `"empty":"","space":"a b","quote":"a\"b","nl":"a\nb","uni":"héllo"`},
+		{"scalars", func(e *Entry) *Entry {
+			return e.Int("n", -1).Float64("f", 1.5).Bool("ok", true).Bool("no", false)
+		}, `"n":-1,"f":1.5,"ok":true,"no":false`},
+		{"numeric-string", func(e *Entry) *Entry { return e.Str("s", "42") }, `"s":42`},
+		{"array", func(e *Entry) *Entry { return e.Ints("is", []int{1, 2}) }, `"is":[1,2]`},
+		{"dict", func(e *Entry) *Entry {
+			return e.Dict("req", NewContext(nil).Str("m", "GET").Int("port", 80).Value())
+		}, `"req.m":"GET","req.port":80`},
+		{"raw", func(e *Entry) *Entry { return e.RawJSON("raw", []byte(`{"a":[1]}`)) }, `"raw.a":[1]`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			logger.Encoding = EncodingLogfmt
+
+			c.fields(logger.Info()).Msg("hello")
+
+			got := logfmtToJSON(nil, b.Bytes())
+			want := `{"time":"2019-07-10T05:35:54.277Z","level":"info",` + c.want + `,"message":"hello"}` + "\n"
+			if string(got) != want {
+				t.Errorf("got  %s\nwant %s", got, want)
+			}
+			if !json.Valid(got) {
+				t.Errorf("invalid JSON %s", got)
+			}
+		})
+	}
+}
+
+func TestLogfmtToJSONLines(t *testing.T) {
+	cases := []struct {
+		name   string
+		logfmt string
+		want   string
+	}{
+		{"empty", "", ""},
+		{"flag", "a=1 debug b=x", `{"a":1,"debug":true,"b":"x"}` + "\n"},
+		{"empty-value", "a= b=", `{"a":"","b":""}` + "\n"},
+		{"quoted", `a="x \"y\" z" b="=\\"`, `{"a":"x \"y\" z","b":"=\\"}` + "\n"},
+		{"unescaped", `a=x"y b=x\y`, `{"a":"x\"y","b":"x\\y"}` + "\n"},
+		{"spaces", "  a=1\t b=2  ", `{"a":1,"b":2}` + "\n"},
+		{"lines", "a=1\nb=2\n", `{"a":1}` + "\n" + `{"b":2}` + "\n"},
+		{"blank-line", "a=1\n\nb=2", `{"a":1}` + "\n{}\n" + `{"b":2}` + "\n"},
+		{"unterminated", `a="x b=1`, `{"a":"\"x b=1"}` + "\n"},
+		{"invalid-escape", `a="x\q"`, `{"a":"\"x\\q\""}` + "\n"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := logfmtToJSON(nil, []byte(c.logfmt)); string(got) != c.want {
+				t.Errorf("logfmtToJSON(%q) = %s, want %s", c.logfmt, got, c.want)
+			}
+		})
+	}
+}
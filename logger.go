@@ -21,10 +21,6 @@ import (
 	"sync/atomic"
 	"time"
 	"unsafe"
-
-	"github.com/oarkflow/xid"
-
-	"github.com/oarkflow/log/fqdn"
 )
 
 type Client interface {
@@ -49,6 +45,18 @@ type Entry struct {
 	Level   Level
 	logger  *Logger
 	context context.Context
+	scanner *PIIScanner
+	nest    []int
+	lazy    []lazyField
+	cbor    bool
+	logfmt  bool
+	prefix  []byte
+	labels  []byte
+	keyed   bool
+	keying  bool
+	field   int
+	value   int
+	levelAt int
 	w       Writer
 }
 
@@ -105,70 +113,102 @@ type HTTPWriter struct {
 // headers, and query parameters. It includes timeout management and enhanced error reporting.
 func (w HTTPWriter) WriteEntry(e *Entry) (n int, err error) {
 	n = len(e.buf)
-	go func(e *Entry) {
-		client := w.Client
-		if client == nil {
-			client = http.DefaultClient
-		}
-		// Determine HTTP method, defaulting to POST.
-		method := w.Method
-		if method == "" {
-			method = "POST"
-		}
+	// the entry is reused after WriteEntry returns
+	data := append([]byte(nil), e.buf...)
+	p := w.pending()
+	if !p.add() {
+		// Flush is draining the requests in flight
+		w.send(data)
+		return n, nil
+	}
+	go func() {
+		defer p.done()
+		w.send(data)
+	}()
 
-		// Build URL with query parameters if provided.
-		urlStr := w.URL
-		if len(w.QueryParams) > 0 {
-			parsedURL, err := url.Parse(w.URL)
-			if err != nil {
-				log.Printf("HTTPWriter: invalid URL: %v", err)
-				return
-			}
-			query := parsedURL.Query()
-			for key, value := range w.QueryParams {
-				query.Set(key, value)
-			}
-			parsedURL.RawQuery = query.Encode()
-			urlStr = parsedURL.String()
-		}
+	return n, nil
+}
+
+// send sends data in a request.
+func (w HTTPWriter) send(data []byte) {
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	// Determine HTTP method, defaulting to POST.
+	method := w.Method
+	if method == "" {
+		method = "POST"
+	}
 
-		// Create a new HTTP request with the log entry payload.
-		req, err := http.NewRequest(method, urlStr, bytes.NewReader(e.buf))
+	// Build URL with query parameters if provided.
+	urlStr := w.URL
+	if len(w.QueryParams) > 0 {
+		parsedURL, err := url.Parse(w.URL)
 		if err != nil {
-			log.Printf("HTTPWriter: error creating request: %v", err)
+			log.Printf("HTTPWriter: invalid URL: %v", err)
 			return
 		}
-
-		// Set default JSON header and any custom headers.
-		req.Header.Set("Content-Type", "application/json")
-		for key, value := range w.Headers {
-			req.Header.Set(key, value)
+		query := parsedURL.Query()
+		for key, value := range w.QueryParams {
+			query.Set(key, value)
 		}
+		parsedURL.RawQuery = query.Encode()
+		urlStr = parsedURL.String()
+	}
+
+	// Create a new HTTP request with the log entry payload.
+	req, err := http.NewRequest(method, urlStr, bytes.NewReader(data))
+	if err != nil {
+		log.Printf("HTTPWriter: error creating request: %v", err)
+		return
+	}
+
+	// Set default JSON header and any custom headers.
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.Headers {
+		req.Header.Set(key, value)
+	}
 
-		// Use a context with timeout to avoid blocking indefinitely.
-		ctx, cancel := context.WithTimeout(req.Context(), 10*time.Second)
-		defer cancel()
-		req = req.WithContext(ctx)
+	// Use a context with timeout to avoid blocking indefinitely.
+	ctx, cancel := context.WithTimeout(req.Context(), 10*time.Second)
+	defer cancel()
+	req = req.WithContext(ctx)
 
-		// Execute the request.
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Printf("HTTPWriter: error executing request: %v", err)
-			return
-		}
-		defer resp.Body.Close()
+	// Execute the request.
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("HTTPWriter: error executing request: %v", err)
+		return
+	}
+	defer resp.Body.Close()
 
-		// Optionally read the response body for debugging.
-		body, _ := io.ReadAll(resp.Body)
-		log.Println("HTTPWriter response:", string(body))
+	// Optionally read the response body for debugging.
+	body, _ := io.ReadAll(resp.Body)
+	log.Println("HTTPWriter response:", string(body))
 
-		// Check for non-successful status codes.
-		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-			log.Printf("HTTPWriter: received status code %d: %s", resp.StatusCode, string(body))
-		}
-	}(e)
+	// Check for non-successful status codes.
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		log.Printf("HTTPWriter: received status code %d: %s", resp.StatusCode, string(body))
+	}
+}
 
-	return n, nil
+// httpWriterPending holds the requests in flight of the HTTPWriters by URL.
+var httpWriterPending sync.Map // key: URL, value: *pending
+
+func (w HTTPWriter) pending() *pending {
+	if v, ok := httpWriterPending.Load(w.URL); ok {
+		return v.(*pending)
+	}
+	v, _ := httpWriterPending.LoadOrStore(w.URL, new(pending))
+	return v.(*pending)
+}
+
+// Flush implements Flusher, it waits for the requests in flight of the HTTPWriters of the URL of w.
+// Entries written meanwhile are sent before WriteEntry returns.
+func (w HTTPWriter) Flush() error {
+	w.pending().wait()
+	return nil
 }
 
 // ObjectMarshaler provides a strongly-typed and encoding-agnostic interface
@@ -182,11 +222,25 @@ type Logger struct {
 	// Level defines log levels.
 	Level Level
 
+	// LevelHandle specifies an optional level shared with other loggers, it overrides Level.
+	LevelHandle *LevelHandle
+
+	// LogNode determines if adds the hostname of the "host_platform" key.
 	LogNode bool
 
+	// EnableTracing determines if adds a trace id to entries, it is taken from the
+	// entry context if present or generated by TraceIDGenerator otherwise.
 	EnableTracing bool
 
+	// TraceIDField defines the trace id field name in output. It uses "trace_id" if empty.
 	TraceIDField string
+
+	// TraceIDGenerator specifies the generator of trace ids. It uses DefaultTraceIDGenerator if empty.
+	TraceIDGenerator TraceIDGenerator
+
+	// ContextExtractor specifies an optional extractor of fields from the entry context, e.g. W3CExtractor.
+	ContextExtractor ContextExtractor
+
 	// Caller determines if adds the file:line of the "caller" key.
 	// If Caller is negative, adds the full /path/to/file:line of the "caller" key.
 	Caller int
@@ -196,14 +250,42 @@ type Logger struct {
 
 	// TimeFormat specifies the time format in output. It uses time.RFC3339 with milliseconds if empty.
 	// If set with `TimeFormatUnix`, `TimeFormatUnixMs`, times are formated as UNIX timestamp.
+	// The header formats `TimeFormatRFC3339Micro`, `TimeFormatRFC3339Nano`, `TimeFormatUnixMicro`
+	// and `TimeFormatUnixNano` without allocations as well.
 	TimeFormat string
 
 	// TimeLocation specifics that the location which TimeFormat used. It uses time.Local if empty.
 	TimeLocation *time.Location
 
+	// Schema specifies the key names and the level style of entries. It uses the default names if empty.
+	Schema *Schema
+
+	// Encoding specifies the encoding of entries, e.g. EncodingCBOR or EncodingLogfmt. It uses EncodingJSON if empty.
+	Encoding Encoding
+
+	// ErrorMarshaler specifies an optional marshaler of errors added by Err and AnErr,
+	// e.g. RichErrorMarshaler. Errors are added as their message if empty.
+	ErrorMarshaler ErrorMarshaler
+
+	// StackOptions specifies optional options which make Stack add structured frames
+	// instead of the text of runtime.Stack, e.g. &DefaultStackOptions.
+	StackOptions *StackOptions
+
 	// Context specifies an optional context of logger.
 	Context Context
 
+	// Sampler specifies an optional sampler that decides which entries are emitted.
+	Sampler Sampler
+
+	// Hooks specifies optional hooks which run in order before entries are written.
+	Hooks []Hook
+
+	// Redactor specifies an optional redactor which replaces the values of sensitive fields.
+	Redactor *Redactor
+
+	// PII specifies an optional scanner which masks PII detected in string values and messages.
+	PII *PIIScanner
+
 	// Writer specifies the writer of output. It uses a wrapped os.Stderr Writer in if empty.
 	Writer Writer
 }
@@ -222,7 +304,7 @@ const TimeFormatUnixWithMs = "\x03"
 
 // Trace starts a new message with trace level.
 func Trace() (e *Entry) {
-	if DefaultLogger.silent(TraceLevel) {
+	if DefaultLogger.silent(TraceLevel) || DefaultLogger.Sampler != nil && !DefaultLogger.sample(TraceLevel, 1) {
 		return nil
 	}
 	e = DefaultLogger.header(TraceLevel)
@@ -238,7 +320,7 @@ func Trace() (e *Entry) {
 
 // Debug starts a new message with debug level.
 func Debug() (e *Entry) {
-	if DefaultLogger.silent(DebugLevel) {
+	if DefaultLogger.silent(DebugLevel) || DefaultLogger.Sampler != nil && !DefaultLogger.sample(DebugLevel, 1) {
 		return nil
 	}
 	e = DefaultLogger.header(DebugLevel)
@@ -254,7 +336,7 @@ func Debug() (e *Entry) {
 
 // Info starts a new message with info level.
 func Info() (e *Entry) {
-	if DefaultLogger.silent(InfoLevel) {
+	if DefaultLogger.silent(InfoLevel) || DefaultLogger.Sampler != nil && !DefaultLogger.sample(InfoLevel, 1) {
 		return nil
 	}
 	e = DefaultLogger.header(InfoLevel)
@@ -270,7 +352,7 @@ func Info() (e *Entry) {
 
 // Warn starts a new message with warning level.
 func Warn() (e *Entry) {
-	if DefaultLogger.silent(WarnLevel) {
+	if DefaultLogger.silent(WarnLevel) || DefaultLogger.Sampler != nil && !DefaultLogger.sample(WarnLevel, 1) {
 		return nil
 	}
 	e = DefaultLogger.header(WarnLevel)
@@ -286,7 +368,7 @@ func Warn() (e *Entry) {
 
 // Error starts a new message with error level.
 func Error() (e *Entry) {
-	if DefaultLogger.silent(ErrorLevel) {
+	if DefaultLogger.silent(ErrorLevel) || DefaultLogger.Sampler != nil && !DefaultLogger.sample(ErrorLevel, 1) {
 		return nil
 	}
 	e = DefaultLogger.header(ErrorLevel)
@@ -347,7 +429,7 @@ func Printf(format string, v ...any) {
 
 // Trace starts a new message with trace level.
 func (l *Logger) Trace() (e *Entry) {
-	if l.silent(TraceLevel) {
+	if l.silent(TraceLevel) || l.Sampler != nil && !l.sample(TraceLevel, 1) {
 		return nil
 	}
 	e = l.header(TraceLevel)
@@ -363,7 +445,7 @@ func (l *Logger) Trace() (e *Entry) {
 
 // Debug starts a new message with debug level.
 func (l *Logger) Debug() (e *Entry) {
-	if l.silent(DebugLevel) {
+	if l.silent(DebugLevel) || l.Sampler != nil && !l.sample(DebugLevel, 1) {
 		return nil
 	}
 	e = l.header(DebugLevel)
@@ -379,7 +461,7 @@ func (l *Logger) Debug() (e *Entry) {
 
 // Info starts a new message with info level.
 func (l *Logger) Info() (e *Entry) {
-	if l.silent(InfoLevel) {
+	if l.silent(InfoLevel) || l.Sampler != nil && !l.sample(InfoLevel, 1) {
 		return nil
 	}
 	e = l.header(InfoLevel)
@@ -395,7 +477,7 @@ func (l *Logger) Info() (e *Entry) {
 
 // Warn starts a new message with warning level.
 func (l *Logger) Warn() (e *Entry) {
-	if l.silent(WarnLevel) {
+	if l.silent(WarnLevel) || l.Sampler != nil && !l.sample(WarnLevel, 1) {
 		return nil
 	}
 	e = l.header(WarnLevel)
@@ -411,7 +493,7 @@ func (l *Logger) Warn() (e *Entry) {
 
 // Error starts a new message with error level.
 func (l *Logger) Error() (e *Entry) {
-	if l.silent(ErrorLevel) {
+	if l.silent(ErrorLevel) || l.Sampler != nil && !l.sample(ErrorLevel, 1) {
 		return nil
 	}
 	e = l.header(ErrorLevel)
@@ -472,7 +554,7 @@ func (l *Logger) Log() (e *Entry) {
 
 // WithLevel starts a new message with level.
 func (l *Logger) WithLevel(level Level) (e *Entry) {
-	if l.silent(level) {
+	if l.silent(level) || l.Sampler != nil && !l.sample(level, 1) {
 		return nil
 	}
 	e = l.header(level)
@@ -492,7 +574,7 @@ func (l *Logger) Err(err error) (e *Entry) {
 	if err != nil {
 		level = ErrorLevel
 	}
-	if l.silent(level) {
+	if l.silent(level) || l.Sampler != nil && !l.sample(level, 1) {
 		return nil
 	}
 	e = l.header(level)
@@ -512,8 +594,12 @@ func (l *Logger) Err(err error) (e *Entry) {
 	return
 }
 
-// SetLevel changes logger default level.
+// SetLevel changes logger default level, or the level of its LevelHandle if any.
 func (l *Logger) SetLevel(level Level) {
+	if l.LevelHandle != nil {
+		l.LevelHandle.SetLevel(level)
+		return
+	}
 	atomic.StoreUint32((*uint32)(&l.Level), uint32(level))
 }
 
@@ -554,6 +640,21 @@ const smallsString = "00010203040506070809" +
 	"90919293949596979899"
 
 var timeNow = time.Now
+
+// timeNowSet reports whether timeNow is replaced by SetTimeNow, see now.
+var timeNowSet bool
+
+// SetTimeNow replaces the clock of the package with f, e.g. of the times of entries, samplers
+// and file rotations, or restores time.Now if f is nil. It is intended for deterministic
+// output of tests, see the logtest package, and must not be called concurrently with logging.
+func SetTimeNow(f func() time.Time) {
+	if f == nil {
+		timeNow, timeNowSet = time.Now, false
+		return
+	}
+	timeNow, timeNowSet = f, true
+}
+
 var timeOffset, timeZone = func() (int64, string) {
 	now := timeNow()
 	_, n := now.Zone()
@@ -563,19 +664,27 @@ var timeOffset, timeZone = func() (int64, string) {
 
 func (l *Logger) header(level Level) *Entry {
 	e := epool.Get().(*Entry)
-	e.buf = e.buf[:0]
+	e.reset(l)
 	e.Level = level
 	if l.Writer != nil {
 		e.w = l.Writer
 	} else {
 		e.w = IOWriter{os.Stderr}
 	}
+	if l.Encoding == EncodingCBOR {
+		l.cborHeader(e, level)
+		return e
+	}
 	// time
-	if l.TimeField == "" {
+	if l.Encoding == EncodingLogfmt {
+		e.logfmt = true
+		e.buf = append(e.buf, l.timeField()...)
+		e.buf = append(e.buf, '=')
+	} else if l.TimeField == "" && l.Schema == nil {
 		e.buf = append(e.buf, "{\"time\":"...)
 	} else {
 		e.buf = append(e.buf, '{', '"')
-		e.buf = append(e.buf, l.TimeField...)
+		e.buf = append(e.buf, l.timeField()...)
 		e.buf = append(e.buf, '"', ':')
 	}
 	offset := timeOffset
@@ -598,70 +707,18 @@ func (l *Logger) header(level Level) *Entry {
 	switch l.TimeFormat {
 	case "":
 		sec, nsec, _ := now()
-		var tmp [32]byte
-		var buf []byte
-		if offset == 0 {
-			// "2006-01-02T15:04:05.999Z"
-			tmp[25] = '"'
-			tmp[24] = 'Z'
-			buf = tmp[:26]
-		} else {
-			// "2006-01-02T15:04:05.999Z07:00"
-			tmp[30] = '"'
-			tmp[29] = timeZone[5]
-			tmp[28] = timeZone[4]
-			tmp[27] = timeZone[3]
-			tmp[26] = timeZone[2]
-			tmp[25] = timeZone[1]
-			tmp[24] = timeZone[0]
-			buf = tmp[:31]
-		}
-		// date time
-		sec += 9223372028715321600 + offset // unixToInternal + internalToAbsolute + timeOffset
-		year, month, day, _ := absDate(uint64(sec), true)
-		hour, minute, second := absClock(uint64(sec))
-		// year
-		a := year / 100 * 2
-		b := year % 100 * 2
-		tmp[0] = '"'
-		tmp[1] = smallsString[a]
-		tmp[2] = smallsString[a+1]
-		tmp[3] = smallsString[b]
-		tmp[4] = smallsString[b+1]
-		// month
-		month *= 2
-		tmp[5] = '-'
-		tmp[6] = smallsString[month]
-		tmp[7] = smallsString[month+1]
-		// day
-		day *= 2
-		tmp[8] = '-'
-		tmp[9] = smallsString[day]
-		tmp[10] = smallsString[day+1]
-		// hour
-		hour *= 2
-		tmp[11] = 'T'
-		tmp[12] = smallsString[hour]
-		tmp[13] = smallsString[hour+1]
-		// minute
-		minute *= 2
-		tmp[14] = ':'
-		tmp[15] = smallsString[minute]
-		tmp[16] = smallsString[minute+1]
-		// second
-		second *= 2
-		tmp[17] = ':'
-		tmp[18] = smallsString[second]
-		tmp[19] = smallsString[second+1]
-		// milli seconds
-		a = int(nsec) / 1000000
-		b = a % 100 * 2
-		tmp[20] = '.'
-		tmp[21] = byte('0' + a/100)
-		tmp[22] = smallsString[b]
-		tmp[23] = smallsString[b+1]
-		// append to e.buf
-		e.buf = append(e.buf, buf...)
+		// "2006-01-02T15:04:05.999Z07:00"
+		e.buf = appendTimeRFC3339(e.buf, sec, nsec, offset, 3)
+	case TimeFormatRFC3339Micro:
+		sec, nsec, _ := now()
+		e.buf = appendTimeRFC3339(e.buf, sec, nsec, offset, 6)
+	case TimeFormatRFC3339Nano:
+		sec, nsec, _ := now()
+		e.buf = appendTimeRFC3339(e.buf, sec, nsec, offset, 9)
+	case TimeFormatUnixMicro, TimeFormatUnixNano:
+		sec, nsec, _ := now()
+		// 1595759807105123
+		e.buf = appendTimeUnix(e.buf, sec, nsec, l.TimeFormat)
 	case TimeFormatUnix:
 		sec, _, _ := now()
 		// 1595759807
@@ -763,7 +820,17 @@ func (l *Logger) header(level Level) *Entry {
 		e.buf = append(e.buf, '"')
 	}
 headerlevel:
+	if e.logfmt {
+		l.logfmtHeader(e, level)
+		return e
+	}
 	// level
+	e.levelAt = len(e.buf)
+	if l.Schema != nil {
+		e.buf = l.Schema.appendLevel(e.buf, level)
+		e.buf = append(e.buf, l.Schema.Context...)
+		goto headercontext
+	}
 	switch level {
 	case DebugLevel:
 		e.buf = append(e.buf, ",\"level\":\"debug\""...)
@@ -779,16 +846,81 @@ headerlevel:
 		e.buf = append(e.buf, ",\"level\":\"fatal\""...)
 	case PanicLevel:
 		e.buf = append(e.buf, ",\"level\":\"panic\""...)
+	case noLevel:
+	default:
+		e.buf = append(e.buf, ",\"level\":\""...)
+		e.buf = append(e.buf, level.String()...)
+		e.buf = append(e.buf, '"')
 	}
+headercontext:
 	// context
 	if l.Context != nil {
-		e.buf = append(e.buf, l.Context...)
+		e.appendContext(l)
 	}
 	return e
 }
 
-// WithContext use context
+// reset clears the pooled entry e for a new entry of l, l is nil for entries without logger.
+func (e *Entry) reset(l *Logger) {
+	e.buf = e.buf[:0]
+	e.logger = l
+	e.context = nil
+	e.scanner = nil
+	e.nest = e.nest[:0]
+	e.lazy = e.lazy[:0]
+	e.cbor = false
+	e.logfmt = false
+	e.prefix = e.prefix[:0]
+	e.labels = e.labels[:0]
+	e.keyed = false
+	e.keying = false
+	e.field = -1
+	e.levelAt = -1
+	if l != nil {
+		e.scanner = l.PII
+		e.keyed = l.keyed()
+	}
+}
+
+// context returns the Context of l with the values matching the Redactor of l replaced.
+func (l *Logger) context() Context {
+	if l.Redactor != nil {
+		return l.Redactor.context(l.Context)
+	}
+	return l.Context
+}
+
+// keyed reports whether the keys of the fields of entries of l are checked, see keyField.
+func (l *Logger) keyed() bool {
+	return l.Redactor != nil || l.Schema != nil && l.Schema.Labels != ""
+}
+
+// appendContext adds the fields of the Context of l, the ones which are not reserved by the
+// Labels of the Schema of l are added to the labels.
+func (e *Entry) appendContext(l *Logger) {
+	ctx := l.context()
+	var labels Context
+	if s := l.Schema; s != nil && s.Labels != "" && len(ctx) != 0 {
+		c := s.context(ctx, l)
+		ctx, labels = c.top, c.labels
+	}
+	switch {
+	case e.cbor:
+		e.buf = cborAppendFields(e.buf, ctx)
+	case e.logfmt:
+		e.buf = logfmtAppendFields(e.buf, nil, ctx)
+	default:
+		e.buf = append(e.buf, ctx...)
+	}
+	e.labels = append(e.labels, labels...)
+}
+
+// WithContext sets the context of entry, the trace id and the fields of the logger's
+// ContextExtractor are taken from it.
 func (e *Entry) WithContext(ctx context.Context) *Entry {
+	if e == nil {
+		return nil
+	}
 	e.context = ctx
 	return e
 }
@@ -799,8 +931,18 @@ func (e *Entry) ToMap() (map[string]interface{}, error) {
 		return nil, nil
 	}
 
+	data := e.buf
+	if e.cbor {
+		var err error
+		if data, err = CBORToJSON(nil, e.buf); err != nil {
+			return nil, err
+		}
+	} else if e.logfmt {
+		data = logfmtToJSON(nil, e.buf)
+	}
+
 	var result map[string]interface{}
-	err := json.Unmarshal(e.buf, &result)
+	err := json.Unmarshal(data, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -818,6 +960,16 @@ func (e *Entry) ToMapBeforeMsg() (map[string]interface{}, error) {
 	// Create a copy of the buffer and finalize it as JSON
 	jsonData := make([]byte, len(e.buf))
 	copy(jsonData, e.buf)
+	if e.cbor {
+		var err error
+		if jsonData, err = CBORToJSON(nil, append(jsonData, cborBreak)); err != nil {
+			return nil, err
+		}
+		jsonData = jsonData[:len(jsonData)-1]
+	} else if e.logfmt {
+		jsonData = logfmtToJSON(nil, e.buf)
+		jsonData = jsonData[:len(jsonData)-1]
+	}
 
 	// If it doesn't end with }, complete the JSON
 	if len(jsonData) == 0 || jsonData[len(jsonData)-1] != '}' {
@@ -838,10 +990,18 @@ func (e *Entry) Time(key string, t time.Time) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendTime(e.buf, t.Unix(), int64(t.Nanosecond()))
+		return e
+	}
+
+	if e.logfmt {
+		e.buf = t.AppendFormat(e.buf, "2006-01-02T15:04:05.999Z07:00")
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '"')
+	e.buf = append(e.buf, '"')
 	e.buf = t.AppendFormat(e.buf, "2006-01-02T15:04:05.999Z07:00")
 	e.buf = append(e.buf, '"')
 	return e
@@ -852,10 +1012,19 @@ func (e *Entry) TimeFormat(key string, timefmt string, t time.Time) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.TimeFormat(key, timefmt, t)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendTimeFormat(e.buf, timefmt, t)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	switch timefmt {
 	case TimeFormatUnix:
 		e.buf = strconv.AppendInt(e.buf, t.Unix(), 10)
@@ -865,6 +1034,10 @@ func (e *Entry) TimeFormat(key string, timefmt string, t time.Time) *Entry {
 		e.buf = strconv.AppendInt(e.buf, t.Unix(), 10)
 		e.buf = append(e.buf, '.')
 		e.buf = strconv.AppendInt(e.buf, t.UnixNano()/1000000%1000, 10)
+	case TimeFormatUnixMicro:
+		e.buf = strconv.AppendInt(e.buf, t.UnixMicro(), 10)
+	case TimeFormatUnixNano:
+		e.buf = strconv.AppendInt(e.buf, t.UnixNano(), 10)
 	default:
 		e.buf = append(e.buf, '"')
 		e.buf = t.AppendFormat(e.buf, timefmt)
@@ -878,10 +1051,23 @@ func (e *Entry) Times(key string, a []time.Time) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Times(key, a)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(a)))
+		for _, t := range a {
+			e.buf = cborAppendTime(e.buf, t.Unix(), int64(t.Nanosecond()))
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.buf = append(e.buf, '[')
 	for i, t := range a {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -900,10 +1086,23 @@ func (e *Entry) TimesFormat(key string, timefmt string, a []time.Time) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.TimesFormat(key, timefmt, a)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(a)))
+		for _, t := range a {
+			e.buf = cborAppendTimeFormat(e.buf, timefmt, t)
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.buf = append(e.buf, '[')
 	for i, t := range a {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -917,6 +1116,10 @@ func (e *Entry) TimesFormat(key string, timefmt string, a []time.Time) *Entry {
 			e.buf = strconv.AppendInt(e.buf, t.Unix(), 10)
 			e.buf = append(e.buf, '.')
 			e.buf = strconv.AppendInt(e.buf, t.UnixNano()/1000000%1000, 10)
+		case TimeFormatUnixMicro:
+			e.buf = strconv.AppendInt(e.buf, t.UnixMicro(), 10)
+		case TimeFormatUnixNano:
+			e.buf = strconv.AppendInt(e.buf, t.UnixNano(), 10)
 		default:
 			e.buf = append(e.buf, '"')
 			e.buf = t.AppendFormat(e.buf, timefmt)
@@ -933,10 +1136,12 @@ func (e *Entry) Bool(key string, b bool) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendBool(e.buf, b)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	e.buf = strconv.AppendBool(e.buf, b)
 	return e
 }
@@ -946,10 +1151,23 @@ func (e *Entry) Bools(key string, b []bool) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Bools(key, b)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(b)))
+		for _, a := range b {
+			e.buf = cborAppendBool(e.buf, a)
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.buf = append(e.buf, '[')
 	for i, a := range b {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -965,10 +1183,12 @@ func (e *Entry) Dur(key string, d time.Duration) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendDur(e.buf, d)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	if d < 0 {
 		d = -d
 		e.buf = append(e.buf, '-')
@@ -1000,14 +1220,17 @@ func (e *Entry) TimeDiff(key string, t time.Time, start time.Time) *Entry {
 	if e == nil {
 		return nil
 	}
-
 	var d time.Duration
 	if t.After(start) {
 		d = t.Sub(start)
 	}
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendDur(e.buf, d)
+		return e
+	}
+
 	e.buf = strconv.AppendInt(e.buf, int64(d/time.Millisecond), 10)
 	if n := (d % time.Millisecond); n != 0 {
 		var tmp [7]byte
@@ -1033,10 +1256,23 @@ func (e *Entry) Durs(key string, d []time.Duration) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Durs(key, d)
+		e.endJSON(n, enc)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(d)))
+		for _, a := range d {
+			e.buf = cborAppendDur(e.buf, a)
+		}
+		return e
+	}
+
+	e.buf = append(e.buf, '[')
 	for i, a := range d {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -1067,9 +1303,17 @@ func (e *Entry) Durs(key string, d []time.Duration) *Entry {
 	return e
 }
 
-// Err adds the field "error" with serialized err to the entry.
+// Err adds the field "error", or the ErrorKey of Schema, with serialized err to the entry.
 func (e *Entry) Err(err error) *Entry {
-	return e.AnErr("error", err)
+	if e == nil {
+		return nil
+	}
+	s := e.schema()
+	e.AnErr(s.errorKey(), err)
+	if s != nil && s.ErrorTypeKey != "" && err != nil {
+		e.Str(s.ErrorTypeKey, reflect.TypeOf(err).String())
+	}
+	return e
 }
 
 // AnErr adds the field key with serialized err to the logger context.
@@ -1077,24 +1321,41 @@ func (e *Entry) AnErr(key string, err error) *Entry {
 	if e == nil {
 		return nil
 	}
-
 	if err == nil {
-		e.buf = append(e.buf, ',', '"')
-		e.buf = append(e.buf, key...)
-		e.buf = append(e.buf, "\":null"...)
+		e.key(key)
+		if e.cbor {
+			e.buf = append(e.buf, cborNull)
+		} else {
+			e.buf = append(e.buf, "null"...)
+		}
+		return e
+	}
+
+	if e.logger != nil && e.logger.ErrorMarshaler != nil {
+		n, enc := e.beginJSON()
+		e.logger.ErrorMarshaler.MarshalError(e, key, err)
+		e.endJSON(n, enc)
 		return e
 	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	if o, ok := err.(ObjectMarshaler); ok {
-		o.MarshalObject(e)
-	} else {
-		e.buf = append(e.buf, '"')
+		return e.Object(key, o)
+	}
+
+	e.key(key)
+	if e.cbor {
 		e.string(err.Error())
-		e.buf = append(e.buf, '"')
+		return e
+	}
+
+	if e.logfmt {
+		e.logfmtString(err.Error())
+		return e
 	}
+
+	e.buf = append(e.buf, '"')
+	e.string(err.Error())
+	e.buf = append(e.buf, '"')
 	return e
 }
 
@@ -1103,10 +1364,27 @@ func (e *Entry) Errs(key string, errs []error) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Errs(key, errs)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(errs)))
+		for _, err := range errs {
+			if err == nil {
+				e.buf = append(e.buf, cborNull)
+			} else {
+				e.string(err.Error())
+			}
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.buf = append(e.buf, '[')
 	for i, err := range errs {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -1158,10 +1436,12 @@ func (e *Entry) Float64(key string, f float64) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendFloat64(e.buf, f)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	e.buf = appendFloat(e.buf, f, 64)
 	return e
 }
@@ -1171,10 +1451,12 @@ func (e *Entry) Float32(key string, f float32) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendFloat32(e.buf, f)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	e.buf = appendFloat(e.buf, float64(f), 32)
 	return e
 }
@@ -1184,10 +1466,23 @@ func (e *Entry) Floats64(key string, f []float64) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Floats64(key, f)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(f)))
+		for _, a := range f {
+			e.buf = cborAppendFloat64(e.buf, a)
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.buf = append(e.buf, '[')
 	for i, a := range f {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -1203,10 +1498,23 @@ func (e *Entry) Floats32(key string, f []float32) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Floats32(key, f)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(f)))
+		for _, a := range f {
+			e.buf = cborAppendFloat32(e.buf, a)
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.buf = append(e.buf, '[')
 	for i, a := range f {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -1222,10 +1530,12 @@ func (e *Entry) Int64(key string, i int64) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendInt(e.buf, i)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	e.buf = strconv.AppendInt(e.buf, i, 10)
 	return e
 }
@@ -1235,10 +1545,12 @@ func (e *Entry) Uint(key string, i uint) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendUint(e.buf, uint64(i))
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	e.buf = strconv.AppendUint(e.buf, uint64(i), 10)
 	return e
 }
@@ -1248,10 +1560,12 @@ func (e *Entry) Uint64(key string, i uint64) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendUint(e.buf, i)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	e.buf = strconv.AppendUint(e.buf, i, 10)
 	return e
 }
@@ -1261,10 +1575,12 @@ func (e *Entry) Int(key string, i int) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendInt(e.buf, int64(i))
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	e.buf = strconv.AppendInt(e.buf, int64(i), 10)
 	return e
 }
@@ -1274,10 +1590,12 @@ func (e *Entry) Int32(key string, i int32) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendInt(e.buf, int64(i))
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	e.buf = strconv.AppendInt(e.buf, int64(i), 10)
 	return e
 }
@@ -1287,10 +1605,12 @@ func (e *Entry) Int16(key string, i int16) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendInt(e.buf, int64(i))
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	e.buf = strconv.AppendInt(e.buf, int64(i), 10)
 	return e
 }
@@ -1300,10 +1620,12 @@ func (e *Entry) Int8(key string, i int8) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendInt(e.buf, int64(i))
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	e.buf = strconv.AppendInt(e.buf, int64(i), 10)
 	return e
 }
@@ -1313,10 +1635,12 @@ func (e *Entry) Uint32(key string, i uint32) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendUint(e.buf, uint64(i))
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	e.buf = strconv.AppendUint(e.buf, uint64(i), 10)
 	return e
 }
@@ -1326,10 +1650,12 @@ func (e *Entry) Uint16(key string, i uint16) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendUint(e.buf, uint64(i))
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	e.buf = strconv.AppendUint(e.buf, uint64(i), 10)
 	return e
 }
@@ -1339,10 +1665,12 @@ func (e *Entry) Uint8(key string, i uint8) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendUint(e.buf, uint64(i))
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	e.buf = strconv.AppendUint(e.buf, uint64(i), 10)
 	return e
 }
@@ -1352,10 +1680,23 @@ func (e *Entry) Ints64(key string, a []int64) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Ints64(key, a)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(a)))
+		for _, i := range a {
+			e.buf = cborAppendInt(e.buf, i)
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.buf = append(e.buf, '[')
 	for i, n := range a {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -1371,10 +1712,23 @@ func (e *Entry) Ints32(key string, a []int32) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Ints32(key, a)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(a)))
+		for _, i := range a {
+			e.buf = cborAppendInt(e.buf, int64(i))
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.buf = append(e.buf, '[')
 	for i, n := range a {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -1390,10 +1744,23 @@ func (e *Entry) Ints16(key string, a []int16) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Ints16(key, a)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(a)))
+		for _, i := range a {
+			e.buf = cborAppendInt(e.buf, int64(i))
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.buf = append(e.buf, '[')
 	for i, n := range a {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -1409,10 +1776,23 @@ func (e *Entry) Ints8(key string, a []int8) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Ints8(key, a)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(a)))
+		for _, i := range a {
+			e.buf = cborAppendInt(e.buf, int64(i))
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.buf = append(e.buf, '[')
 	for i, n := range a {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -1428,10 +1808,23 @@ func (e *Entry) Ints(key string, a []int) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Ints(key, a)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(a)))
+		for _, i := range a {
+			e.buf = cborAppendInt(e.buf, int64(i))
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.buf = append(e.buf, '[')
 	for i, n := range a {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -1447,10 +1840,23 @@ func (e *Entry) Uints64(key string, a []uint64) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Uints64(key, a)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(a)))
+		for _, i := range a {
+			e.buf = cborAppendUint(e.buf, i)
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.buf = append(e.buf, '[')
 	for i, n := range a {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -1466,10 +1872,23 @@ func (e *Entry) Uints32(key string, a []uint32) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Uints32(key, a)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(a)))
+		for _, i := range a {
+			e.buf = cborAppendUint(e.buf, uint64(i))
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.buf = append(e.buf, '[')
 	for i, n := range a {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -1485,10 +1904,23 @@ func (e *Entry) Uints16(key string, a []uint16) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Uints16(key, a)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(a)))
+		for _, i := range a {
+			e.buf = cborAppendUint(e.buf, uint64(i))
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.buf = append(e.buf, '[')
 	for i, n := range a {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -1504,13 +1936,26 @@ func (e *Entry) Uints8(key string, a []uint8) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Uints8(key, a)
+		e.endJSON(n, enc)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
-	for i, n := range a {
-		if i != 0 {
-			e.buf = append(e.buf, ',')
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(a)))
+		for _, i := range a {
+			e.buf = cborAppendUint(e.buf, uint64(i))
+		}
+		return e
+	}
+
+	e.buf = append(e.buf, '[')
+	for i, n := range a {
+		if i != 0 {
+			e.buf = append(e.buf, ',')
 		}
 		e.buf = strconv.AppendUint(e.buf, uint64(n), 10)
 	}
@@ -1523,10 +1968,23 @@ func (e *Entry) Uints(key string, a []uint) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Uints(key, a)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(a)))
+		for _, i := range a {
+			e.buf = cborAppendUint(e.buf, uint64(i))
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.buf = append(e.buf, '[')
 	for i, n := range a {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -1542,10 +2000,19 @@ func (e *Entry) RawJSON(key string, b []byte) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		k := e.keyObject(key)
+		e.buf, _ = logfmtAppendJSON(e.buf, e.prefix, key, b, 0)
+		e.keyFields(k)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf, _ = cborAppendJSON(e.buf, b, 0)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	e.buf = append(e.buf, b...)
 	return e
 }
@@ -1555,10 +2022,19 @@ func (e *Entry) RawJSONStr(key string, s string) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		k := e.keyObject(key)
+		e.buf, _ = logfmtAppendJSON(e.buf, e.prefix, key, unsafe.Slice(unsafe.StringData(s), len(s)), 0)
+		e.keyFields(k)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf, _ = cborAppendJSON(e.buf, unsafe.Slice(unsafe.StringData(s), len(s)), 0)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	e.buf = append(e.buf, s...)
 	return e
 }
@@ -1568,10 +2044,18 @@ func (e *Entry) Str(key string, val string) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.string(val)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '"')
+	if e.logfmt {
+		e.logfmtString(val)
+		return e
+	}
+
+	e.buf = append(e.buf, '"')
 	e.string(val)
 	e.buf = append(e.buf, '"')
 	return e
@@ -1582,10 +2066,19 @@ func (e *Entry) StrInt(key string, val int64) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		var tmp [20]byte
+		e.buf = cborAppendText(e.buf, b2s(strconv.AppendInt(tmp[:0], val, 10)))
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '"')
+	if e.logfmt {
+		e.buf = strconv.AppendInt(e.buf, val, 10)
+		return e
+	}
+
+	e.buf = append(e.buf, '"')
 	e.buf = strconv.AppendInt(e.buf, val, 10)
 	e.buf = append(e.buf, '"')
 	return e
@@ -1596,10 +2089,25 @@ func (e *Entry) Stringer(key string, val fmt.Stringer) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		if val != nil {
+			e.string(val.String())
+		} else {
+			e.buf = append(e.buf, cborNull)
+		}
+		return e
+	}
+
+	if e.logfmt {
+		if val != nil {
+			e.logfmtString(val.String())
+		} else {
+			e.buf = append(e.buf, "null"...)
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	if val != nil {
 		e.buf = append(e.buf, '"')
 		e.string(val.String())
@@ -1615,10 +2123,25 @@ func (e *Entry) GoStringer(key string, val fmt.GoStringer) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		if val != nil {
+			e.string(val.GoString())
+		} else {
+			e.buf = append(e.buf, cborNull)
+		}
+		return e
+	}
+
+	if e.logfmt {
+		if val != nil {
+			e.logfmtString(val.GoString())
+		} else {
+			e.buf = append(e.buf, "null"...)
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	if val != nil {
 		e.buf = append(e.buf, '"')
 		e.string(val.GoString())
@@ -1634,10 +2157,23 @@ func (e *Entry) Strs(key string, vals []string) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Strs(key, vals)
+		e.endJSON(n, enc)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(vals)))
+		for _, val := range vals {
+			e.string(val)
+		}
+		return e
+	}
+
+	e.buf = append(e.buf, '[')
 	for i, val := range vals {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -1655,10 +2191,17 @@ func (e *Entry) Byte(key string, val byte) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = append(e.buf, cborText|1, val)
+		return e
+	}
+
+	if e.logfmt {
+		e.buf = logfmtAppendString(e.buf, string(val))
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	switch val {
 	case '"':
 		e.buf = append(e.buf, "\"\\\"\""...)
@@ -1691,10 +2234,18 @@ func (e *Entry) Bytes(key string, val []byte) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.bytes(val)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '"')
+	if e.logfmt {
+		e.logfmtString(b2s(val))
+		return e
+	}
+
+	e.buf = append(e.buf, '"')
 	e.bytes(val)
 	e.buf = append(e.buf, '"')
 	return e
@@ -1705,10 +2256,25 @@ func (e *Entry) BytesOrNil(key string, val []byte) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		if val == nil {
+			e.buf = append(e.buf, cborNull)
+		} else {
+			e.bytes(val)
+		}
+		return e
+	}
+
+	if e.logfmt {
+		if val == nil {
+			e.buf = append(e.buf, "null"...)
+		} else {
+			e.logfmtString(b2s(val))
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
 	if val == nil {
 		e.buf = append(e.buf, "null"...)
 	} else {
@@ -1726,10 +2292,26 @@ func (e *Entry) Hex(key string, val []byte) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborText, uint64(len(val)*2))
+		for _, v := range val {
+			e.buf = append(e.buf, hex[v>>4], hex[v&0x0f])
+		}
+		return e
+	}
+
+	if e.logfmt {
+		if len(val) == 0 {
+			e.buf = append(e.buf, '"', '"')
+		}
+		for _, v := range val {
+			e.buf = append(e.buf, hex[v>>4], hex[v&0x0f])
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '"')
+	e.buf = append(e.buf, '"')
 	for _, v := range val {
 		e.buf = append(e.buf, hex[v>>4], hex[v&0x0f])
 	}
@@ -1744,10 +2326,25 @@ func (e *Entry) Encode(key string, val []byte, enc interface {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		b := bbpool.Get().(*bb)
+		b.B = enc.AppendEncode(b.B[:0], val)
+		e.buf = cborAppendText(e.buf, b2s(b.B))
+		if cap(b.B) <= bbcap {
+			bbpool.Put(b)
+		}
+		return e
+	}
+
+	if e.logfmt {
+		n := len(e.buf)
+		e.buf = enc.AppendEncode(e.buf, val)
+		e.logfmtQuote(n)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '"')
+	e.buf = append(e.buf, '"')
 	e.buf = enc.AppendEncode(e.buf, val)
 	e.buf = append(e.buf, '"')
 	return e
@@ -1758,10 +2355,18 @@ func (e *Entry) Xid(key string, id string) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendText(e.buf, id)
+		return e
+	}
+
+	if e.logfmt {
+		e.logfmtString(id)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '"')
+	e.buf = append(e.buf, '"')
 	e.buf = append(e.buf, []byte(id)...)
 	e.buf = append(e.buf, '"')
 
@@ -1773,10 +2378,24 @@ func (e *Entry) IPAddr(key string, ip net.IP) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendIP(e.buf, ip)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '"')
+	if e.logfmt {
+		n := len(e.buf)
+		if ip4 := ip.To4(); ip4 != nil {
+			e.buf = netip.AddrFrom4([4]byte(ip4)).AppendTo(e.buf)
+		} else if a, ok := netip.AddrFromSlice(ip); ok {
+			e.buf = a.AppendTo(e.buf)
+		}
+		e.logfmtQuote(n)
+		return e
+	}
+
+	e.buf = append(e.buf, '"')
 	if ip4 := ip.To4(); ip4 != nil {
 		_ = ip4[3]
 		e.buf = strconv.AppendInt(e.buf, int64(ip4[0]), 10)
@@ -1798,10 +2417,18 @@ func (e *Entry) IPPrefix(key string, pfx net.IPNet) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendIPNet(e.buf, pfx)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '"')
+	if e.logfmt {
+		e.buf = append(e.buf, pfx.String()...)
+		return e
+	}
+
+	e.buf = append(e.buf, '"')
 	e.buf = append(e.buf, pfx.String()...)
 	e.buf = append(e.buf, '"')
 	return e
@@ -1812,10 +2439,25 @@ func (e *Entry) MACAddr(key string, ha net.HardwareAddr) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendMAC(e.buf, ha)
+		return e
+	}
+
+	if e.logfmt {
+		n := len(e.buf)
+		for i, c := range ha {
+			if i > 0 {
+				e.buf = append(e.buf, ':')
+			}
+			e.buf = append(e.buf, hex[c>>4], hex[c&0xF])
+		}
+		e.logfmtQuote(n)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '"')
+	e.buf = append(e.buf, '"')
 	for i, c := range ha {
 		if i > 0 {
 			e.buf = append(e.buf, ':')
@@ -1832,10 +2474,20 @@ func (e *Entry) NetIPAddr(key string, ip netip.Addr) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendAddr(e.buf, ip)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '"')
+	if e.logfmt {
+		n := len(e.buf)
+		e.buf = ip.AppendTo(e.buf)
+		e.logfmtQuote(n)
+		return e
+	}
+
+	e.buf = append(e.buf, '"')
 	e.buf = ip.AppendTo(e.buf)
 	e.buf = append(e.buf, '"')
 	return e
@@ -1846,10 +2498,23 @@ func (e *Entry) NetIPAddrs(key string, ips []netip.Addr) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.NetIPAddrs(key, ips)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(len(ips)))
+		for _, ip := range ips {
+			e.buf = cborAppendAddr(e.buf, ip)
+		}
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.buf = append(e.buf, '[')
 	for i, ip := range ips {
 		if i > 0 {
 			e.buf = append(e.buf, ',')
@@ -1867,10 +2532,21 @@ func (e *Entry) NetIPAddrPort(key string, ipPort netip.AddrPort) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		var tmp [64]byte
+		e.buf = cborAppendText(e.buf, b2s(ipPort.AppendTo(tmp[:0])))
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '"')
+	if e.logfmt {
+		n := len(e.buf)
+		e.buf = ipPort.AppendTo(e.buf)
+		e.logfmtQuote(n)
+		return e
+	}
+
+	e.buf = append(e.buf, '"')
 	e.buf = ipPort.AppendTo(e.buf)
 	e.buf = append(e.buf, '"')
 	return e
@@ -1881,10 +2557,20 @@ func (e *Entry) NetIPPrefix(key string, pfx netip.Prefix) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendPrefix(e.buf, pfx)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '"')
+	if e.logfmt {
+		n := len(e.buf)
+		e.buf = pfx.AppendTo(e.buf)
+		e.logfmtQuote(n)
+		return e
+	}
+
+	e.buf = append(e.buf, '"')
 	e.buf = pfx.AppendTo(e.buf)
 	e.buf = append(e.buf, '"')
 	return e
@@ -1895,10 +2581,18 @@ func (e *Entry) Type(key string, v any) *Entry {
 	if e == nil {
 		return nil
 	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendText(e.buf, reflect.TypeOf(v).String())
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '"')
+	if e.logfmt {
+		e.logfmtString(reflect.TypeOf(v).String())
+		return e
+	}
+
+	e.buf = append(e.buf, '"')
 	e.buf = append(e.buf, reflect.TypeOf(v).String()...)
 	e.buf = append(e.buf, '"')
 	return e
@@ -1921,12 +2615,29 @@ func (e *Entry) Caller(depth int) *Entry {
 }
 
 // Stack enables stack trace printing for the error passed to Err().
+// It adds structured frames if the StackOptions of logger is set, see StackFrames.
 func (e *Entry) Stack() *Entry {
 	if e == nil {
 		return nil
 	}
 
-	e.buf = append(e.buf, ",\"stack\":\""...)
+	if e.logger != nil && e.logger.StackOptions != nil {
+		e.stackFrames(e.logger.StackOptions)
+		return e
+	}
+
+	e.key(e.schema().stackKey())
+	if e.cbor {
+		e.string(b2s(stacks(false)))
+		return e
+	}
+
+	if e.logfmt {
+		e.logfmtString(b2s(stacks(false)))
+		return e
+	}
+
+	e.buf = append(e.buf, '"')
 	e.bytes(stacks(false))
 	e.buf = append(e.buf, '"')
 	return e
@@ -1956,43 +2667,214 @@ func (e *Entry) Msg(msg string) {
 	if e == nil {
 		return
 	}
-	if DefaultLogger.EnableTracing {
-		if e.context == nil {
-			e.Str(DefaultLogger.TraceIDField, xid.New().String())
-		} else {
-			traceID := e.context.Value(DefaultLogger.TraceIDField)
-			if traceID == nil {
-				e.Str(DefaultLogger.TraceIDField, xid.New().String())
-			} else {
-				switch v := traceID.(type) {
-				case string:
-					if v == "" {
-						e.Str(DefaultLogger.TraceIDField, xid.New().String())
-					} else {
-						e.Str(DefaultLogger.TraceIDField, v)
-					}
-				case int64:
-					e.Int64(DefaultLogger.TraceIDField, v)
-				}
-			}
-		}
-	}
-	if DefaultLogger.LogNode {
-		hostname, _ := fqdn.Hostname()
-		e.Str("host_platform", hostname)
+	e.msg(msg)
+}
+
+func (e *Entry) msg(msg string) {
+	e.send(msg, msg != "", true)
+}
+
+// message adds msg as the message field of the entry, the nested objects and arrays which
+// are not ended are ended first.
+func (e *Entry) message(msg string) {
+	if len(e.nest) != 0 {
+		e.end()
 	}
-	if msg != "" {
-		e.buf = append(e.buf, ",\"message\":\""...)
+	e.key(e.schema().messageKey())
+	switch {
+	case e.cbor:
+		e.string(msg)
+	case e.logfmt:
+		e.logfmtString(msg)
+	default:
+		e.buf = append(e.buf, '"')
 		e.string(msg)
-		e.buf = append(e.buf, "\"}\n"...)
+		e.buf = append(e.buf, '"')
+	}
+}
+
+// key adds the key of a field. The top-level fields of keyed entries are checked by keyField
+// once they end, i.e. when the next field starts or the entry is sent.
+func (e *Entry) key(key string) {
+	top := e.keyed && !e.keying && len(e.nest) == 0
+	if top {
+		e.keyField()
+		e.field = len(e.buf)
+	}
+	switch {
+	case e.cbor:
+		e.buf = cborAppendText(e.buf, key)
+	case e.logfmt:
+		e.logfmtKey(key)
+	default:
+		e.buf = append(e.buf, ',', '"')
+		e.buf = append(e.buf, key...)
+		e.buf = append(e.buf, '"', ':')
+	}
+	if top {
+		e.value = len(e.buf)
+	}
+}
+
+// keyField checks the pending top-level field started by key or keyStart, it replaces the
+// values matching the Redactor of the logger and moves the field to the labels unless its
+// key is reserved by the Labels of its Schema.
+func (e *Entry) keyField() {
+	n := e.field
+	if n < 0 {
+		return
+	}
+	e.field = -1
+	if n >= len(e.buf) || e.logger == nil {
+		return
+	}
+	r, s := e.logger.Redactor, e.logger.Schema
+	if s != nil && s.Labels == "" {
+		s = nil
+	}
+	if e.value >= 0 && !e.logfmt && e.keyValue(r, s, n) {
+		return
+	}
+	// raw fields, or flattened objects of logfmt, are checked by their keys
+	if r != nil {
+		e.redact(r, n)
+	}
+	if s != nil {
+		e.labelFields(s, n)
+	}
+}
+
+// keyValue is keyField of fields added by key whose value is the rest of the entry, it
+// reports false for the others, e.g. fields followed by the raw fields of Caller.
+func (e *Entry) keyValue(r *Redactor, s *Schema, n int) bool {
+	if e.value >= len(e.buf) {
+		return false
+	}
+	var key []byte
+	var end int
+	var err error
+	if e.cbor {
+		_, key, _, _ = cborString(e.buf, n)
+		end, err = cborSkip(e.buf, e.value, 0)
 	} else {
+		key = e.buf[n+2 : e.value-2]
+		end, _, _, _ = jsonParseAny(e.buf, e.value, false)
+	}
+	if err != nil || end != len(e.buf) {
+		return false
+	}
+	if r != nil {
+		e.redactValue(r, b2s(key))
+	}
+	if s != nil && !s.reserved(b2s(key), e.logger) {
+		e.labelValue(key, n)
+	}
+	return true
+}
+
+// keyStart starts the top-level fields about to be added by producers of raw fields, e.g.
+// Context, which are checked together by keyField. It returns their start, or -1 for nested
+// fields and entries which are not keyed.
+func (e *Entry) keyStart() int {
+	if !e.keyed || e.keying || len(e.nest) != 0 {
+		return -1
+	}
+	e.keyField()
+	e.keying = true
+	e.field, e.value = len(e.buf), -1
+	return e.field
+}
+
+// keyObject is keyStart of the logfmt pairs of the object of key flattened to its dotted
+// keys, they are labeled as the members of key, see logfmtLabel.
+func (e *Entry) keyObject(key string) int {
+	n := e.keyStart()
+	if n >= 0 {
+		// the pairs start with " key." or " key="
+		e.value = n + len(key) + 2
+	}
+	return n
+}
+
+// keyFields ends the fields started by keyStart at n, e.g.
+//
+//	k := e.keyStart()
+//	e.buf = append(e.buf, ctx...)
+//	e.keyFields(k)
+func (e *Entry) keyFields(n int) {
+	if n >= 0 {
+		e.keying = false
+	}
+}
+
+// marshalObject adds the fields of obj, the members of the field being added.
+func (e *Entry) marshalObject(obj ObjectMarshaler) {
+	keying := e.keying
+	e.keying = true
+	obj.MarshalObject(e)
+	e.keying = keying
+}
+
+// send writes the entry with msg, which is added as the message field if message. It exits
+// or panics for fatal and panic levels if terminate.
+func (e *Entry) send(msg string, message, terminate bool) {
+	if len(e.nest) != 0 {
+		e.end()
+	}
+	e.keyField()
+	if e.logger != nil && len(e.logger.Hooks) != 0 && !e.hook(msg) {
+		return
+	}
+	if len(e.lazy) != 0 {
+		e.resolve()
+	}
+	l := e.logger
+	if l == nil {
+		l = &DefaultLogger
+	}
+	var traced bool
+	if e.context != nil && l.ContextExtractor != nil {
+		k := e.keyStart()
+		n, enc := e.beginJSON()
+		traced = l.ContextExtractor.Extract(e.context, e)
+		e.endJSON(n, enc)
+		e.keyFields(k)
+	}
+	if l.EnableTracing && !traced {
+		e.traceID(l)
+	}
+	if l.LogNode {
+		e.Str("host_platform", nodeName())
+	}
+	if message {
+		e.message(msg)
+	}
+	if l.Schema != nil && l.Schema.ErrorReportType != "" {
+		e.errorReport(l.Schema)
+	}
+	e.keyField()
+	if len(e.labels) != 0 && l.Schema != nil && l.Schema.Labels != "" {
+		e.appendLabels(l.Schema)
+	}
+	switch {
+	case e.cbor:
+		e.buf = append(e.buf, cborBreak)
+	case e.logfmt:
+		e.buf = append(e.buf, '\n')
+	default:
 		e.buf = append(e.buf, '}', '\n')
+		switch l.Encoding {
+		case EncodingCBOR:
+			e.transcode()
+		case EncodingLogfmt:
+			e.logfmtTranscode()
+		}
 	}
 	_, _ = e.w.WriteEntry(e)
-	if (e.Level == FatalLevel) && notTest {
-		os.Exit(255)
+	if (e.Level == FatalLevel) && terminate && notTest {
+		exit(e.w)
 	}
-	if (e.Level == PanicLevel) && notTest {
+	if (e.Level == PanicLevel) && terminate && notTest {
 		panic(msg)
 	}
 	if cap(e.buf) <= bbcap {
@@ -2016,6 +2898,9 @@ var bbpool = sync.Pool{
 }
 
 func (e *Entry) Copy() Logger {
+	if len(e.lazy) != 0 {
+		e.resolve()
+	}
 	logger := Logger{
 		Context: e.buf,
 	}
@@ -2024,11 +2909,22 @@ func (e *Entry) Copy() Logger {
 		logger.LogNode = e.logger.LogNode
 		logger.EnableTracing = e.logger.EnableTracing
 		logger.TraceIDField = e.logger.TraceIDField
+		logger.TraceIDGenerator = e.logger.TraceIDGenerator
+		logger.ContextExtractor = e.logger.ContextExtractor
 		logger.Caller = e.logger.Caller
 		logger.TimeFormat = e.logger.TimeFormat
 		logger.TimeLocation = e.logger.TimeLocation
+		logger.Schema = e.logger.Schema
+		logger.Encoding = e.logger.Encoding
 		logger.Writer = e.logger.Writer
 		logger.Level = e.logger.Level
+		logger.LevelHandle = e.logger.LevelHandle
+		logger.Sampler = e.logger.Sampler
+		logger.Hooks = e.logger.Hooks
+		logger.Redactor = e.logger.Redactor
+		logger.PII = e.logger.PII
+		logger.ErrorMarshaler = e.logger.ErrorMarshaler
+		logger.StackOptions = e.logger.StackOptions
 	}
 	return logger
 }
@@ -2041,14 +2937,11 @@ func (e *Entry) Msgf(format string, v ...any) {
 
 	b := bbpool.Get().(*bb)
 	b.B = b.B[:0]
-	e.buf = append(e.buf, ",\"message\":\""...)
 	fmt.Fprintf(b, format, v...)
-	e.bytes(b.B)
-	e.buf = append(e.buf, '"')
+	e.send(b2s(b.B), true, true)
 	if cap(b.B) <= bbcap {
 		bbpool.Put(b)
 	}
-	e.Msg("")
 }
 
 // Msgs sends the entry with msgs added as the message field if not empty.
@@ -2059,14 +2952,11 @@ func (e *Entry) Msgs(args ...any) {
 
 	b := bbpool.Get().(*bb)
 	b.B = b.B[:0]
-	e.buf = append(e.buf, ",\"message\":\""...)
 	fmt.Fprint(b, args...)
-	e.bytes(b.B)
-	e.buf = append(e.buf, '"')
+	e.send(b2s(b.B), true, true)
 	if cap(b.B) <= bbcap {
 		bbpool.Put(b)
 	}
-	e.Msg("")
 }
 
 func (e *Entry) caller(n int, pc uintptr, fullpath bool) {
@@ -2098,6 +2988,55 @@ func (e *Entry) caller(n int, pc uintptr, fullpath bool) {
 		}
 	}
 
+	if e.cbor {
+		e.cborCaller(file, line, name)
+		return
+	}
+
+	if e.logfmt {
+		e.logfmtCaller(file, line, name)
+		return
+	}
+
+	if s := e.schema(); s != nil && s.SourceLocationKey != "" {
+		e.buf = append(e.buf, ',', '"')
+		e.buf = append(e.buf, s.SourceLocationKey...)
+		e.buf = append(e.buf, "\":{\"file\":\""...)
+		e.buf = append(e.buf, file...)
+		e.buf = append(e.buf, "\",\"line\":\""...)
+		e.buf = strconv.AppendInt(e.buf, int64(line), 10)
+		e.buf = append(e.buf, "\",\"function\":\""...)
+		e.buf = append(e.buf, name...)
+		e.buf = append(e.buf, "\"},\""...)
+		e.buf = append(e.buf, s.goidKey()...)
+		e.buf = append(e.buf, '"', ':')
+		e.buf = strconv.AppendInt(e.buf, int64(goid()), 10)
+		return
+	} else if s != nil {
+		e.buf = append(e.buf, ',', '"')
+		e.buf = append(e.buf, s.callerKey()...)
+		e.buf = append(e.buf, '"', ':', '"')
+		e.buf = append(e.buf, file...)
+		if s.CallerLineKey != "" {
+			e.buf = append(e.buf, '"', ',', '"')
+			e.buf = append(e.buf, s.CallerLineKey...)
+			e.buf = append(e.buf, '"', ':')
+			e.buf = strconv.AppendInt(e.buf, int64(line), 10)
+			e.buf = append(e.buf, ',', '"')
+		} else {
+			e.buf = append(e.buf, ':')
+			e.buf = strconv.AppendInt(e.buf, int64(line), 10)
+			e.buf = append(e.buf, '"', ',', '"')
+		}
+		e.buf = append(e.buf, s.callerFuncKey()...)
+		e.buf = append(e.buf, '"', ':', '"')
+		e.buf = append(e.buf, name...)
+		e.buf = append(e.buf, '"', ',', '"')
+		e.buf = append(e.buf, s.goidKey()...)
+		e.buf = append(e.buf, '"', ':')
+		e.buf = strconv.AppendInt(e.buf, int64(goid()), 10)
+		return
+	}
 	e.buf = append(e.buf, ",\"caller\":\""...)
 	e.buf = append(e.buf, file...)
 	e.buf = append(e.buf, ':')
@@ -2229,6 +3168,18 @@ func (e *Entry) escapes(s string) {
 }
 
 func (e *Entry) string(s string) {
+	if e.scanner != nil && e.pii(s) {
+		return
+	}
+	e.text(s)
+}
+
+// text adds s like string without scanning it for PII, e.g. keys.
+func (e *Entry) text(s string) {
+	if e.cbor {
+		e.buf = cborAppendText(e.buf, s)
+		return
+	}
 	for _, c := range []byte(s) {
 		if escapes[c] {
 			e.escapes(s)
@@ -2239,6 +3190,13 @@ func (e *Entry) string(s string) {
 }
 
 func (e *Entry) bytes(b []byte) {
+	if e.scanner != nil && e.pii(b2s(b)) {
+		return
+	}
+	if e.cbor {
+		e.buf = cborAppendBytes(e.buf, b)
+		return
+	}
 	for _, c := range b {
 		if escapes[c] {
 			e.escapeb(b)
@@ -2253,14 +3211,23 @@ func (e *Entry) Interface(key string, i any) *Entry {
 	if e == nil {
 		return nil
 	}
-
 	if o, ok := i.(ObjectMarshaler); ok {
 		return e.Object(key, o)
 	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Interface(key, i)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.cborInterface(i)
+		return e
+	}
+
 	b := bbpool.Get().(*bb)
 	b.B = b.B[:0]
 	enc := json.NewEncoder(b)
@@ -2285,17 +3252,41 @@ func (e *Entry) Object(key string, obj ObjectMarshaler) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt && obj != nil && (*[2]uintptr)(unsafe.Pointer(&obj))[1] != 0 {
+		// the members are flattened to the dotted keys of key
+		k := e.keyStart()
+		n := len(e.prefix)
+		e.prefix = append(logfmtAppendName(e.prefix, key), '.')
+		obj.MarshalObject(e)
+		e.prefix = e.prefix[:n]
+		e.keyFields(k)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
+	e.key(key)
 	if obj == nil || (*[2]uintptr)(unsafe.Pointer(&obj))[1] == 0 {
-		e.buf = append(e.buf, "null"...)
+		if e.cbor {
+			e.buf = append(e.buf, cborNull)
+		} else {
+			e.buf = append(e.buf, "null"...)
+		}
+		return e
+	}
+
+	if e.cbor {
+		n := len(e.buf)
+		e.buf = append(e.buf, cborMap|cborIndefinite)
+		e.marshalObject(obj)
+		if n+1 < len(e.buf) {
+			e.buf = append(e.buf, cborBreak)
+		} else {
+			e.buf[n] = cborNull
+		}
 		return e
 	}
 
 	n := len(e.buf)
-	obj.MarshalObject(e)
+	e.marshalObject(obj)
 	if n < len(e.buf) {
 		e.buf[n] = '{'
 		e.buf = append(e.buf, '}')
@@ -2311,18 +3302,42 @@ func (e *Entry) Objects(key string, objects any) *Entry {
 	if e == nil {
 		return nil
 	}
-
 	values := reflect.ValueOf(objects)
+	if e.logfmt {
+		n, enc := e.beginJSON()
+		e.Objects(key, objects)
+		e.endJSON(n, enc)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		if values.Kind() != reflect.Slice {
+			e.buf = append(e.buf, cborNull)
+			return e
+		}
+		e.buf = cborAppendHead(e.buf, cborArray, uint64(values.Len()))
+		for i := 0; i < values.Len(); i++ {
+			value := values.Index(i)
+			if value.Kind() == reflect.Ptr && value.IsNil() {
+				e.buf = append(e.buf, cborNull)
+			} else if obj, ok := value.Interface().(ObjectMarshaler); ok {
+				e.buf = append(e.buf, cborMap|cborIndefinite)
+				e.marshalObject(obj)
+				e.buf = append(e.buf, cborBreak)
+			} else {
+				e.buf = append(e.buf, cborNull)
+			}
+		}
+		return e
+	}
+
 	if values.Kind() != reflect.Slice {
-		e.buf = append(e.buf, ',', '"')
-		e.buf = append(e.buf, key...)
-		e.buf = append(e.buf, `":null`...)
+		e.buf = append(e.buf, "null"...)
 		return e
 	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '[')
+	e.buf = append(e.buf, '[')
 	for i := 0; i < values.Len(); i++ {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
@@ -2332,7 +3347,7 @@ func (e *Entry) Objects(key string, objects any) *Entry {
 			e.buf = append(e.buf, "null"...)
 		} else if obj, ok := value.Interface().(ObjectMarshaler); ok {
 			i := len(e.buf)
-			obj.MarshalObject(e)
+			e.marshalObject(obj)
 			e.buf[i] = '{'
 			e.buf = append(e.buf, '}')
 		} else {
@@ -2343,7 +3358,8 @@ func (e *Entry) Objects(key string, objects any) *Entry {
 	return e
 }
 
-// Func allows an anonymous func to run only if the entry is enabled.
+// Func allows an anonymous func to run only if the entry is enabled, see Lazy to run it
+// only if the entry is written.
 //
 //go:nonline
 func (e *Entry) Func(f func(e *Entry)) *Entry {
@@ -2392,20 +3408,20 @@ func (e *Entry) Any(key string, value any) *Entry {
 	if e == nil {
 		return nil
 	}
-
 	if value == nil || (*[2]uintptr)(unsafe.Pointer(&value))[1] == 0 {
-		e.buf = append(e.buf, ',', '"')
-		e.buf = append(e.buf, key...)
-		e.buf = append(e.buf, '"', ':')
-		e.buf = append(e.buf, "null"...)
+		e.key(key)
+		if e.cbor {
+			e.buf = append(e.buf, cborNull)
+		} else {
+			e.buf = append(e.buf, "null"...)
+		}
 		return e
 	}
 	switch value := value.(type) {
+	case LogValuer:
+		e.Lazy(key, value.LogValue)
 	case ObjectMarshaler:
-		e.buf = append(e.buf, ',', '"')
-		e.buf = append(e.buf, key...)
-		e.buf = append(e.buf, '"', ':')
-		value.MarshalObject(e)
+		e.Object(key, value)
 	case Context:
 		e.Dict(key, value)
 	case map[string]any:
@@ -2423,10 +3439,7 @@ func (e *Entry) Any(key string, value any) *Entry {
 	case net.IPNet:
 		e.IPPrefix(key, value)
 	case json.RawMessage:
-		e.buf = append(e.buf, ',', '"')
-		e.buf = append(e.buf, key...)
-		e.buf = append(e.buf, '"', ':')
-		e.buf = append(e.buf, value...)
+		e.RawJSON(key, value)
 	case []bool:
 		e.Bools(key, value)
 	case []byte:
@@ -2472,27 +3485,7 @@ func (e *Entry) Any(key string, value any) *Entry {
 	case fmt.Stringer:
 		e.Stringer(key, value)
 	default:
-		e.buf = append(e.buf, ',', '"')
-		e.buf = append(e.buf, key...)
-		e.buf = append(e.buf, '"', ':')
-		b := bbpool.Get().(*bb)
-		b.B = b.B[:0]
-		enc := json.NewEncoder(b)
-		enc.SetEscapeHTML(false)
-		err := enc.Encode(value)
-		if err != nil {
-			b.B = b.B[:0]
-			fmt.Fprintf(b, `marshaling error: %+v`, err)
-			e.buf = append(e.buf, '"')
-			e.bytes(b.B)
-			e.buf = append(e.buf, '"')
-		} else {
-			b.B = b.B[:len(b.B)-1]
-			e.buf = append(e.buf, b.B...)
-		}
-		if cap(b.B) <= bbcap {
-			bbpool.Put(b)
-		}
+		e.Interface(key, value)
 	}
 	return e
 }
@@ -2536,6 +3529,8 @@ type Context []byte
 func NewContext(dst []byte) (e *Entry) {
 	e = new(Entry)
 	e.buf = dst
+	e.field = -1
+	e.levelAt = -1
 	return
 }
 
@@ -2543,6 +3538,9 @@ func With(logr *Logger) (e *Entry) {
 	e = new(Entry)
 	e.buf = logr.Context
 	e.logger = logr
+	e.scanner = logr.PII
+	e.field = -1
+	e.levelAt = -1
 	return
 }
 
@@ -2551,6 +3549,9 @@ func (e *Entry) Value() Context {
 	if e == nil {
 		return nil
 	}
+	if len(e.lazy) != 0 {
+		e.resolve()
+	}
 	return e.buf
 }
 
@@ -2559,10 +3560,16 @@ func (e *Entry) Context(ctx Context) *Entry {
 	if e == nil {
 		return nil
 	}
-
-	if len(ctx) != 0 {
+	k := e.keyStart()
+	switch {
+	case e.cbor:
+		e.buf = cborAppendFields(e.buf, ctx)
+	case e.logfmt:
+		e.buf = logfmtAppendFields(e.buf, e.prefix, ctx)
+	default:
 		e.buf = append(e.buf, ctx...)
 	}
+	e.keyFields(k)
 	return e
 }
 
@@ -2571,10 +3578,24 @@ func (e *Entry) Dict(key string, ctx Context) *Entry {
 	if e == nil {
 		return nil
 	}
+	if e.logfmt {
+		k := e.keyObject(key)
+		n := len(e.prefix)
+		e.prefix = append(logfmtAppendName(e.prefix, key), '.')
+		e.buf = logfmtAppendFields(e.buf, e.prefix, ctx)
+		e.prefix = e.prefix[:n]
+		e.keyFields(k)
+		return e
+	}
+
+	e.key(key)
+	if e.cbor {
+		e.buf = append(e.buf, cborMap|cborIndefinite)
+		e.buf = append(cborAppendFields(e.buf, ctx), cborBreak)
+		return e
+	}
 
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':', '{')
+	e.buf = append(e.buf, '{')
 	if len(ctx) > 0 {
 		e.buf = append(e.buf, ctx[1:]...)
 	}
@@ -2612,8 +3633,17 @@ func wlprintf(w Writer, level Level, format string, args ...any) (int, error) {
 func b2s(b []byte) string { return *(*string)(unsafe.Pointer(&b)) }
 
 //go:noescape
-//go:linkname now time.now
-func now() (sec int64, nsec int32, mono int64)
+//go:linkname runtimeNow time.now
+func runtimeNow() (sec int64, nsec int32, mono int64)
+
+// now returns the current time of the runtime, or of timeNow if replaced by SetTimeNow.
+func now() (sec int64, nsec int32, mono int64) {
+	if timeNowSet {
+		t := timeNow()
+		return t.Unix(), int32(t.Nanosecond()), 0
+	}
+	return runtimeNow()
+}
 
 //go:noescape
 //go:linkname absDate time.absDate
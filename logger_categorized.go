@@ -1,35 +1,187 @@
 package log
 
 import (
+	"fmt"
+	"strings"
 	"sync"
 )
 
-var categorizedLoggers sync.Map // key: string, value: *Logger
+// categoryRegistry holds the category levels of a root level handle, and the categorized
+// loggers of the root loggers sharing it.
+type categoryRegistry struct {
+	mu      sync.Mutex
+	root    *LevelHandle
+	handles map[string]*LevelHandle
+	loggers map[categoryKey]*CategorizedLogger
+}
+
+// categoryKey is the key of a categorized logger of a registry.
+type categoryKey struct {
+	root *Logger
+	name string
+}
+
+// categoryRegistries holds the category registries by their root LevelHandle, or by their
+// root Logger if it has no LevelHandle.
+var categoryRegistries sync.Map
+
+// loadRegistry returns the category registry of key, it is created with root if none.
+func loadRegistry(key any, root *LevelHandle) *categoryRegistry {
+	if r, ok := categoryRegistries.Load(key); ok {
+		return r.(*categoryRegistry)
+	}
+	r, _ := categoryRegistries.LoadOrStore(key, &categoryRegistry{
+		root:    root,
+		handles: make(map[string]*LevelHandle),
+		loggers: make(map[categoryKey]*CategorizedLogger),
+	})
+	return r.(*categoryRegistry)
+}
+
+// registry returns the category registry of root handle h.
+func (h *LevelHandle) registry() *categoryRegistry {
+	return loadRegistry(h, h)
+}
+
+// categories returns the category registry of root logger l. The categories of a logger
+// without LevelHandle inherit its Level, which is read like a LevelHandle.
+func (l *Logger) categories() *categoryRegistry {
+	if h := l.LevelHandle; h != nil {
+		return h.registry()
+	}
+	if r, ok := categoryRegistries.Load(l); ok {
+		return r.(*categoryRegistry)
+	}
+	return loadRegistry(l, &LevelHandle{base: &l.Level})
+}
+
+// handle returns the level handle of category name, it inherits the level of the parent category.
+func (r *categoryRegistry) handle(name string) *LevelHandle {
+	if h, ok := r.handles[name]; ok {
+		return h
+	}
+	parent := r.root
+	if i := strings.LastIndexByte(name, '.'); i > 0 {
+		parent = r.handle(name[:i])
+	}
+	h := parent.Child()
+	r.handles[name] = h
+	return h
+}
 
+// CategorizedLogger is a logger of a dotted hierarchical category, e.g. "db.pool.conn".
 type CategorizedLogger struct {
 	Logger
 	Category string
+
+	root *Logger
 }
 
-// Categorized returns a cloned logger for category `name`.
+// Categorized returns a cloned logger for category `name`, which is cached per root logger l.
+//
+// Categories are dotted hierarchical names, e.g. "db", "db.pool" and "db.pool.conn". The level
+// of a category is inherited from its nearest ancestor with a level set by SetCategoryLevel,
+// or from the level of l. The category levels are shared by the loggers sharing the LevelHandle
+// of l, or kept for l itself if it has none, so they follow the changes of its Level.
 func (l *Logger) Categorized(name string) *CategorizedLogger {
-	// Inherit logger with added context
-	v, ok := categorizedLoggers.Load(name)
-	if ok {
-		return v.(*CategorizedLogger)
+	r := l.categories()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := categoryKey{l, name}
+	if n, ok := r.loggers[key]; ok {
+		return n
 	}
+	// Inherit logger with added context
 	n := &CategorizedLogger{
-		Logger{
-			Level:        l.Level,
-			Caller:       l.Caller,
-			TimeField:    l.TimeField,
-			TimeFormat:   l.TimeFormat,
-			TimeLocation: l.TimeLocation,
-			Context:      NewContext(l.Context).Str("category", name).Value(),
-			Writer:       l.Writer,
+		Logger: Logger{
+			Level:            l.Level,
+			LevelHandle:      r.handle(name),
+			LogNode:          l.LogNode,
+			EnableTracing:    l.EnableTracing,
+			TraceIDField:     l.TraceIDField,
+			TraceIDGenerator: l.TraceIDGenerator,
+			ContextExtractor: l.ContextExtractor,
+			Caller:           l.Caller,
+			TimeField:        l.TimeField,
+			TimeFormat:       l.TimeFormat,
+			TimeLocation:     l.TimeLocation,
+			Schema:           l.Schema,
+			Encoding:         l.Encoding,
+			ErrorMarshaler:   l.ErrorMarshaler,
+			StackOptions:     l.StackOptions,
+			Context:          NewContext(l.Context).Str("category", name).Value(),
+			Writer:           l.Writer,
+			Sampler:          l.Sampler,
+			Hooks:            l.Hooks,
+			Redactor:         l.Redactor,
+			PII:              l.PII,
 		},
-		name,
+		Category: name,
+		root:     l,
 	}
-	categorizedLoggers.Store(name, n)
+	r.loggers[key] = n
 	return n
 }
+
+// Categorized returns the logger for subcategory `name` of c, e.g. "pool" of "db" is "db.pool".
+func (c *CategorizedLogger) Categorized(name string) *CategorizedLogger {
+	return c.root.Categorized(c.Category + "." + name)
+}
+
+// CategoryLevelHandle returns the level handle of category `name` of root logger l.
+func (l *Logger) CategoryLevelHandle(name string) *LevelHandle {
+	r := l.categories()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.handle(name)
+}
+
+// SetCategoryLevel overrides the level of category `name` and its subcategories which
+// are not set. The level 0 makes the category inherit the level of its parent again.
+func (l *Logger) SetCategoryLevel(name string, level Level) {
+	l.CategoryLevelHandle(name).SetLevel(level)
+}
+
+// SetCategoryLevels overrides the category levels of spec, see ParseLevels. The level
+// without category sets the level of l, e.g.
+//
+//	err := logger.SetCategoryLevels(os.Getenv("LOG_LEVELS"))
+func (l *Logger) SetCategoryLevels(spec string) error {
+	levels, err := ParseLevels(spec)
+	if err != nil {
+		return err
+	}
+	for name, level := range levels {
+		if name == "" {
+			l.SetLevel(level)
+			continue
+		}
+		l.SetCategoryLevel(name, level)
+	}
+	return nil
+}
+
+// ParseLevels parses a spec of category levels in the form of "info,db=debug,http.client=warn".
+// The level without category, or of category "*", is returned with the category "".
+func ParseLevels(spec string) (map[string]Level, error) {
+	levels := make(map[string]Level)
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			name, value = "", name
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if name == "*" {
+			name = ""
+		}
+		level := ParseLevel(value)
+		if level == noLevel {
+			return nil, fmt.Errorf("invalid level %q of category %q", value, name)
+		}
+		levels[name] = level
+	}
+	return levels, nil
+}
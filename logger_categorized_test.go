@@ -0,0 +1,148 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCategorized(t *testing.T) {
+	var b bytes.Buffer
+	logger := testLogger(t, &b)
+	logger.Level = InfoLevel
+
+	db := logger.Categorized("db")
+	pool := db.Categorized("pool")
+	if pool.Category != "db.pool" || logger.Categorized("db.pool") != pool {
+		t.Fatalf("subcategory = %q, not cached", pool.Category)
+	}
+
+	cases := []struct {
+		name  string
+		set   func()
+		entry func() *Entry
+		want  bool
+	}{
+		{"inherit", func() {}, pool.Debug, false},
+		{"root", func() { logger.SetLevel(DebugLevel) }, pool.Debug, true},
+		{"category", func() { logger.SetCategoryLevel("db", WarnLevel) }, pool.Info, false},
+		{"subcategory", func() { logger.SetCategoryLevel("db.pool", TraceLevel) }, pool.Trace, true},
+		{"other", func() {}, db.Info, false},
+		{"spec", func() { _ = logger.SetCategoryLevels("error,db=info") }, db.Info, true},
+		{"spec-root", func() {}, logger.Info, false},
+	}
+	for _, c := range cases {
+		c.set()
+		if got := c.entry() != nil; got != c.want {
+			t.Errorf("%s: enabled = %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	b.Reset()
+	db.Info().Msg("hi")
+	if want := `{"time":"2019-07-10T05:35:54.277Z","level":"info","category":"db","message":"hi"}` + "\n"; b.String() != want {
+		t.Errorf("got %s\nwant %s", b.String(), want)
+	}
+}
+
+func TestCategorizedRoots(t *testing.T) {
+	handle := NewLevelHandle(InfoLevel)
+	var b1, b2 bytes.Buffer
+	l1 := Logger{LevelHandle: handle, Writer: IOWriter{&b1}}
+	l2 := Logger{LevelHandle: handle, Writer: IOWriter{&b2}}
+
+	l1.Categorized("db").Info().Msg("one")
+	l2.Categorized("db").Info().Msg("two")
+	if b1.Len() == 0 || b2.Len() == 0 {
+		t.Errorf("categorized loggers of roots sharing a handle share writers: %q %q", b1.String(), b2.String())
+	}
+	if l1.CategoryLevelHandle("db") != l2.CategoryLevelHandle("db") {
+		t.Errorf("roots sharing a handle do not share category levels")
+	}
+}
+
+func TestCategorizedRootUnchanged(t *testing.T) {
+	handle := NewLevelHandle(InfoLevel)
+	cases := []struct {
+		name   string
+		logger *Logger
+	}{
+		{"level", &Logger{Level: InfoLevel, Writer: IOWriter{io.Discard}}},
+		{"handle", &Logger{LevelHandle: handle, Writer: IOWriter{io.Discard}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			root := *c.logger
+			db := c.logger.Categorized("db")
+			if c.logger.LevelHandle != root.LevelHandle {
+				t.Errorf("Categorized() changed the LevelHandle of the root logger")
+			}
+
+			// the entries of the root and its categories are started concurrently
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(2)
+				go func() { defer wg.Done(); c.logger.Info().Msg("root") }()
+				go func() { defer wg.Done(); c.logger.Categorized("db.pool").Info().Msg("pool") }()
+			}
+			wg.Wait()
+
+			if c.logger.LevelHandle == nil {
+				c.logger.Level = ErrorLevel
+			} else {
+				c.logger.LevelHandle.SetLevel(ErrorLevel)
+			}
+			if db.Warn() != nil || c.logger.Categorized("db.pool").Warn() != nil || db.Error() == nil {
+				t.Errorf("categories do not follow the level of the root logger")
+			}
+		})
+	}
+}
+
+func TestCategorizedCache(t *testing.T) {
+	handle := NewLevelHandle(InfoLevel)
+	l1 := &Logger{LevelHandle: handle, Writer: IOWriter{io.Discard}}
+	l2 := &Logger{LevelHandle: handle, Writer: IOWriter{io.Discard}}
+
+	cases := []struct {
+		name string
+		a, b *CategorizedLogger
+		same bool
+	}{
+		{"root", l1.Categorized("db"), l1.Categorized("db"), true},
+		{"shared-handle", l1.Categorized("db"), l2.Categorized("db"), false},
+		{"after-other-root", l2.Categorized("db"), l2.Categorized("db"), true},
+		{"kept", l1.Categorized("db"), l1.Categorized("db"), true},
+		{"subcategory", l1.Categorized("db").Categorized("pool"), l1.Categorized("db.pool"), true},
+	}
+	for _, c := range cases {
+		if got := c.a == c.b; got != c.same {
+			t.Errorf("%s: same = %v, want %v", c.name, got, c.same)
+		}
+	}
+	if l1.Categorized("db").LevelHandle != l2.Categorized("db").LevelHandle {
+		t.Errorf("roots sharing a handle do not share the category handles")
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	cases := []struct {
+		spec string
+		want map[string]Level
+		err  bool
+	}{
+		{"", map[string]Level{}, false},
+		{"info", map[string]Level{"": InfoLevel}, false},
+		{" warn , db=debug,http.client = error,", map[string]Level{"": WarnLevel, "db": DebugLevel, "http.client": ErrorLevel}, false},
+		{"*=trace", map[string]Level{"": TraceLevel}, false},
+		{"db=loud", nil, true},
+	}
+	for _, c := range cases {
+		got, err := ParseLevels(c.spec)
+		if (err != nil) != c.err || !c.err && !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ParseLevels(%q) = %v, %v, want %v", c.spec, got, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package log
+
+import (
+	"context"
+)
+
+type loggerContextKey struct{}
+
+type fieldsContextKey struct{}
+
+// IntoContext returns a copy of ctx carrying the logger l.
+func IntoContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or the DefaultLogger if none.
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerContextKey{}).(*Logger); ok && l != nil {
+			return l
+		}
+	}
+	return &DefaultLogger
+}
+
+// ContextWithFields returns a copy of ctx carrying fields after the fields already carried by ctx.
+// The fields are added to entries started by the Ctx level methods, e.g.
+//
+//	ctx = log.ContextWithFields(ctx, log.NewContext(nil).Str("user_id", id).Value())
+//	log.InfoCtx(ctx).Msg("user request")
+func ContextWithFields(ctx context.Context, fields Context) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+	if parent := FieldsFromContext(ctx); len(parent) != 0 {
+		fields = append(append(make(Context, 0, len(parent)+len(fields)), parent...), fields...)
+	}
+	return context.WithValue(ctx, fieldsContextKey{}, fields)
+}
+
+// FieldsFromContext returns the fields carried by ctx.
+func FieldsFromContext(ctx context.Context) Context {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsContextKey{}).(Context)
+	return fields
+}
+
+// ctxHeader starts a new message with level, the context and fields of ctx.
+func (l *Logger) ctxHeader(ctx context.Context, level Level) (e *Entry) {
+	if l.silent(level) || l.Sampler != nil && !l.sample(level, 2) {
+		return nil
+	}
+	e = l.header(level)
+	if caller, full := l.Caller, false; caller != 0 {
+		if caller < 0 {
+			caller, full = -caller, true
+		}
+		var pc uintptr
+		e.caller(caller1(caller+1, &pc, 1, 1), pc, full)
+	}
+	e.context = ctx
+	if fields := FieldsFromContext(ctx); len(fields) != 0 {
+		e.Context(fields)
+	}
+	return
+}
+
+// TraceCtx starts a new message with trace level using the logger and fields of ctx.
+func TraceCtx(ctx context.Context) (e *Entry) {
+	return FromContext(ctx).ctxHeader(ctx, TraceLevel)
+}
+
+// DebugCtx starts a new message with debug level using the logger and fields of ctx.
+func DebugCtx(ctx context.Context) (e *Entry) {
+	return FromContext(ctx).ctxHeader(ctx, DebugLevel)
+}
+
+// InfoCtx starts a new message with info level using the logger and fields of ctx.
+func InfoCtx(ctx context.Context) (e *Entry) {
+	return FromContext(ctx).ctxHeader(ctx, InfoLevel)
+}
+
+// WarnCtx starts a new message with warning level using the logger and fields of ctx.
+func WarnCtx(ctx context.Context) (e *Entry) {
+	return FromContext(ctx).ctxHeader(ctx, WarnLevel)
+}
+
+// ErrorCtx starts a new message with error level using the logger and fields of ctx.
+func ErrorCtx(ctx context.Context) (e *Entry) {
+	return FromContext(ctx).ctxHeader(ctx, ErrorLevel)
+}
+
+// FatalCtx starts a new message with fatal level using the logger and fields of ctx.
+func FatalCtx(ctx context.Context) (e *Entry) {
+	return FromContext(ctx).ctxHeader(ctx, FatalLevel)
+}
+
+// PanicCtx starts a new message with panic level using the logger and fields of ctx.
+func PanicCtx(ctx context.Context) (e *Entry) {
+	return FromContext(ctx).ctxHeader(ctx, PanicLevel)
+}
+
+// TraceCtx starts a new message with trace level and the fields of ctx.
+func (l *Logger) TraceCtx(ctx context.Context) (e *Entry) {
+	return l.ctxHeader(ctx, TraceLevel)
+}
+
+// DebugCtx starts a new message with debug level and the fields of ctx.
+func (l *Logger) DebugCtx(ctx context.Context) (e *Entry) {
+	return l.ctxHeader(ctx, DebugLevel)
+}
+
+// InfoCtx starts a new message with info level and the fields of ctx.
+func (l *Logger) InfoCtx(ctx context.Context) (e *Entry) {
+	return l.ctxHeader(ctx, InfoLevel)
+}
+
+// WarnCtx starts a new message with warning level and the fields of ctx.
+func (l *Logger) WarnCtx(ctx context.Context) (e *Entry) {
+	return l.ctxHeader(ctx, WarnLevel)
+}
+
+// ErrorCtx starts a new message with error level and the fields of ctx.
+func (l *Logger) ErrorCtx(ctx context.Context) (e *Entry) {
+	return l.ctxHeader(ctx, ErrorLevel)
+}
+
+// FatalCtx starts a new message with fatal level and the fields of ctx.
+func (l *Logger) FatalCtx(ctx context.Context) (e *Entry) {
+	return l.ctxHeader(ctx, FatalLevel)
+}
+
+// PanicCtx starts a new message with panic level and the fields of ctx.
+func (l *Logger) PanicCtx(ctx context.Context) (e *Entry) {
+	return l.ctxHeader(ctx, PanicLevel)
+}
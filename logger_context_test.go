@@ -0,0 +1,65 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestContextLogger(t *testing.T) {
+	var b bytes.Buffer
+	logger := testLogger(t, &b)
+	logger.Context = NewContext(nil).Str("service", "api").Value()
+
+	ctx := IntoContext(context.Background(), &logger)
+	ctx = ContextWithFields(ctx, NewContext(nil).Str("user_id", "u1").Value())
+	ctx = ContextWithFields(ctx, NewContext(nil).Str("tenant", "t1").Value())
+
+	cases := []struct {
+		name  string
+		entry func() *Entry
+		want  string
+	}{
+		{"func", func() *Entry { return InfoCtx(ctx) },
+			`{"time":"2019-07-10T05:35:54.277Z","level":"info","service":"api","user_id":"u1","tenant":"t1","message":"hi"}`},
+		{"method", func() *Entry { return logger.WarnCtx(ctx) },
+			`{"time":"2019-07-10T05:35:54.277Z","level":"warn","service":"api","user_id":"u1","tenant":"t1","message":"hi"}`},
+		{"no-fields", func() *Entry { return logger.ErrorCtx(context.Background()) },
+			`{"time":"2019-07-10T05:35:54.277Z","level":"error","service":"api","message":"hi"}`},
+		{"empty-fields", func() *Entry { return logger.InfoCtx(ContextWithFields(context.Background(), nil)) },
+			`{"time":"2019-07-10T05:35:54.277Z","level":"info","service":"api","message":"hi"}`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b.Reset()
+			c.entry().Msg("hi")
+			if got := b.String(); got != c.want+"\n" {
+				t.Errorf("got %s\nwant %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	logger := &Logger{Level: ErrorLevel}
+	cases := []struct {
+		name string
+		ctx  context.Context
+		want *Logger
+	}{
+		{"nil", nil, &DefaultLogger},
+		{"none", context.Background(), &DefaultLogger},
+		{"nil-logger", IntoContext(context.Background(), nil), &DefaultLogger},
+		{"logger", IntoContext(context.Background(), logger), logger},
+	}
+	for _, c := range cases {
+		if got := FromContext(c.ctx); got != c.want {
+			t.Errorf("%s: FromContext() = %p, want %p", c.name, got, c.want)
+		}
+	}
+
+	if e := InfoCtx(IntoContext(context.Background(), logger)); e != nil {
+		t.Errorf("InfoCtx of an error level logger = %v, want nil", e)
+	}
+}
@@ -4,5 +4,13 @@ package log
 
 //gcassert:inline
 func (l *Logger) silent(level Level) bool {
-	return level < l.Level
+	threshold := l.Level
+	if l.LevelHandle != nil {
+		threshold = l.LevelHandle.Level()
+	}
+	if level > noLevel || threshold > noLevel {
+		// custom levels are filtered as their rank
+		return level.rank() < threshold.rank()
+	}
+	return level < threshold
 }
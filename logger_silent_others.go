@@ -7,5 +7,15 @@ import (
 )
 
 func (l *Logger) silent(level Level) bool {
-	return uint32(level) < atomic.LoadUint32((*uint32)(&l.Level))
+	var threshold Level
+	if l.LevelHandle != nil {
+		threshold = l.LevelHandle.Level()
+	} else {
+		threshold = Level(atomic.LoadUint32((*uint32)(&l.Level)))
+	}
+	if level > noLevel || threshold > noLevel {
+		// custom levels are filtered as their rank
+		return level.rank() < threshold.rank()
+	}
+	return level < threshold
 }
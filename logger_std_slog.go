@@ -100,31 +100,31 @@ func (h stdSlogHandler) WithGroup(name string) slog.Handler {
 func (h *stdSlogHandler) Enabled(_ context.Context, level slog.Level) bool {
 	switch level {
 	case slog.LevelDebug:
-		return h.logger.Level <= DebugLevel
+		return !h.logger.silent(DebugLevel)
 	case slog.LevelInfo:
-		return h.logger.Level <= InfoLevel
+		return !h.logger.silent(InfoLevel)
 	case slog.LevelWarn:
-		return h.logger.Level <= WarnLevel
+		return !h.logger.silent(WarnLevel)
 	case slog.LevelError:
-		return h.logger.Level <= ErrorLevel
+		return !h.logger.silent(ErrorLevel)
 	}
 	return false
 }
 
 func (h *stdSlogHandler) header(now time.Time) *Entry {
 	e := epool.Get().(*Entry)
-	e.buf = e.buf[:0]
+	e.reset(&h.logger)
 	if h.logger.Writer != nil {
 		e.w = h.logger.Writer
 	} else {
 		e.w = IOWriter{os.Stderr}
 	}
 	// time
-	if h.logger.TimeField == "" {
+	if h.logger.TimeField == "" && h.logger.Schema == nil {
 		e.buf = append(e.buf, "{\"time\":"...)
 	} else {
 		e.buf = append(e.buf, '{', '"')
-		e.buf = append(e.buf, h.logger.TimeField...)
+		e.buf = append(e.buf, h.logger.timeField()...)
 		e.buf = append(e.buf, '"', ':')
 	}
 	if h.logger.TimeLocation != nil {
@@ -132,71 +132,14 @@ func (h *stdSlogHandler) header(now time.Time) *Entry {
 	}
 	switch h.logger.TimeFormat {
 	case "":
-		sec, nsec := now.Unix(), now.Nanosecond()
-		var tmp [32]byte
-		var buf []byte
-		if timeOffset == 0 {
-			// "2006-01-02T15:04:05.999Z"
-			tmp[25] = '"'
-			tmp[24] = 'Z'
-			buf = tmp[:26]
-		} else {
-			// "2006-01-02T15:04:05.999Z07:00"
-			tmp[30] = '"'
-			tmp[29] = timeZone[5]
-			tmp[28] = timeZone[4]
-			tmp[27] = timeZone[3]
-			tmp[26] = timeZone[2]
-			tmp[25] = timeZone[1]
-			tmp[24] = timeZone[0]
-			buf = tmp[:31]
-		}
-		// date time
-		sec += 9223372028715321600 + timeOffset // unixToInternal + internalToAbsolute + timeOffset
-		year, month, day, _ := absDate(uint64(sec), true)
-		hour, minute, second := absClock(uint64(sec))
-		// year
-		a := year / 100 * 2
-		b := year % 100 * 2
-		tmp[0] = '"'
-		tmp[1] = smallsString[a]
-		tmp[2] = smallsString[a+1]
-		tmp[3] = smallsString[b]
-		tmp[4] = smallsString[b+1]
-		// month
-		month *= 2
-		tmp[5] = '-'
-		tmp[6] = smallsString[month]
-		tmp[7] = smallsString[month+1]
-		// day
-		day *= 2
-		tmp[8] = '-'
-		tmp[9] = smallsString[day]
-		tmp[10] = smallsString[day+1]
-		// hour
-		hour *= 2
-		tmp[11] = 'T'
-		tmp[12] = smallsString[hour]
-		tmp[13] = smallsString[hour+1]
-		// minute
-		minute *= 2
-		tmp[14] = ':'
-		tmp[15] = smallsString[minute]
-		tmp[16] = smallsString[minute+1]
-		// second
-		second *= 2
-		tmp[17] = ':'
-		tmp[18] = smallsString[second]
-		tmp[19] = smallsString[second+1]
-		// milli seconds
-		a = int(nsec) / 1000000
-		b = a % 100 * 2
-		tmp[20] = '.'
-		tmp[21] = byte('0' + a/100)
-		tmp[22] = smallsString[b]
-		tmp[23] = smallsString[b+1]
-		// append to e.buf
-		e.buf = append(e.buf, buf...)
+		// "2006-01-02T15:04:05.999Z07:00"
+		e.buf = appendTimeRFC3339(e.buf, now.Unix(), int32(now.Nanosecond()), timeOffset, 3)
+	case TimeFormatRFC3339Micro:
+		e.buf = appendTimeRFC3339(e.buf, now.Unix(), int32(now.Nanosecond()), timeOffset, 6)
+	case TimeFormatRFC3339Nano:
+		e.buf = appendTimeRFC3339(e.buf, now.Unix(), int32(now.Nanosecond()), timeOffset, 9)
+	case TimeFormatUnixMicro, TimeFormatUnixNano:
+		e.buf = appendTimeUnix(e.buf, now.Unix(), int32(now.Nanosecond()), h.logger.TimeFormat)
 	case TimeFormatUnix:
 		sec := now.Unix()
 		// 1595759807
@@ -298,24 +241,30 @@ func (h *stdSlogHandler) header(now time.Time) *Entry {
 }
 
 func (h *stdSlogHandler) Handle(_ context.Context, r slog.Record) error {
-	e := h.header(r.Time)
-
 	// level
+	var level Level
 	switch r.Level {
 	case slog.LevelDebug:
-		e.Level = DebugLevel
-		e.buf = append(e.buf, ",\"level\":\"debug\""...)
+		level = DebugLevel
 	case slog.LevelInfo:
-		e.Level = InfoLevel
-		e.buf = append(e.buf, ",\"level\":\"info\""...)
+		level = InfoLevel
 	case slog.LevelWarn:
-		e.Level = WarnLevel
-		e.buf = append(e.buf, ",\"level\":\"warn\""...)
+		level = WarnLevel
 	case slog.LevelError:
-		e.Level = ErrorLevel
-		e.buf = append(e.buf, ",\"level\":\"error\""...)
+		level = ErrorLevel
 	default:
-		e.Level = noLevel
+		level = noLevel
+	}
+	if h.logger.Sampler != nil && !h.logger.sampleCaller(level, r.PC) {
+		return nil
+	}
+
+	e := h.header(r.Time)
+	e.Level = level
+	e.levelAt = len(e.buf)
+	e.buf = h.logger.Schema.appendLevel(e.buf, e.Level)
+	if h.logger.Schema != nil {
+		e.buf = append(e.buf, h.logger.Schema.Context...)
 	}
 
 	if caller := h.logger.Caller; caller != 0 && r.PC != 0 {
@@ -324,11 +273,14 @@ func (h *stdSlogHandler) Handle(_ context.Context, r slog.Record) error {
 
 	// context
 	if h.logger.Context != nil {
-		e.buf = append(e.buf, h.logger.Context...)
+		e.appendContext(&h.logger)
 	}
 
 	// msg
-	e = e.Str("message", r.Message)
+	e.message(r.Message)
+
+	// the fields of groups are checked as they are closed
+	k := e.keyStart()
 
 	// with
 	if b := h.entry.buf; len(b) != 0 {
@@ -386,8 +338,9 @@ func (h *stdSlogHandler) Handle(_ context.Context, r slog.Record) error {
 			e.buf = append(e.buf, '}')
 		}
 	}
+	e.keyFields(k)
 
-	e.Msg("")
+	e.send(r.Message, false, true)
 	return nil
 }
 
@@ -0,0 +1,35 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSlogMessage(t *testing.T) {
+	cases := []struct {
+		name string
+		log  func(l *Logger)
+		want string
+	}{
+		{"with", func(l *Logger) { l.Slog().With("a", 1).Info("hello", "b", 2) }, `{"level":"info","message":"hello","a":1,"b":2}`},
+		{"empty", func(l *Logger) { l.Slog().Info("", "b", 2) }, `{"level":"info","message":"","b":2}`},
+		{"group", func(l *Logger) { l.Slog().WithGroup("g").Info("hello", "b", 2) }, `{"level":"info","message":"hello","g":{"b":2}}`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			c.log(&logger)
+			// the times of records are stamped by slog
+			got := b.String()
+			if i := strings.Index(got, `"level"`); i > 0 {
+				got = "{" + got[i:]
+			}
+			if got != c.want+"\n" {
+				t.Errorf("got %s\nwant %s", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func init() {
+	// Fatal and panic entries do not exit or panic in tests
+	notTest = false
+}
+
+// testTime is the fixed time of the entries of testLogger.
+var testTime = time.Date(2019, 7, 10, 5, 35, 54, 277e6, time.UTC)
+
+// testLogger returns a logger of UTC times fixed to testTime which writes to b.
+func testLogger(t *testing.T, b *bytes.Buffer) Logger {
+	t.Helper()
+	SetTimeNow(func() time.Time { return testTime })
+	t.Cleanup(func() { SetTimeNow(nil) })
+	return Logger{
+		Level:        TraceLevel,
+		TimeLocation: time.UTC,
+		Writer:       IOWriter{b},
+	}
+}
+
+func TestMsgfMessage(t *testing.T) {
+	cases := []struct {
+		name string
+		log  func(l *Logger)
+		want string
+	}{
+		{"msg", func(l *Logger) { l.Info().Str("a", "b").Msg("hello") }, `{"time":"2019-07-10T05:35:54.277Z","level":"info","a":"b","message":"hello"}`},
+		{"msg-empty", func(l *Logger) { l.Info().Str("a", "b").Msg("") }, `{"time":"2019-07-10T05:35:54.277Z","level":"info","a":"b"}`},
+		{"msgf", func(l *Logger) { l.Info().Msgf("n=%d", 1) }, `{"time":"2019-07-10T05:35:54.277Z","level":"info","message":"n=1"}`},
+		{"msgf-empty", func(l *Logger) { l.Info().Str("a", "b").Msgf("") }, `{"time":"2019-07-10T05:35:54.277Z","level":"info","a":"b","message":""}`},
+		{"msgf-nested", func(l *Logger) { l.Info().BeginObject("a").Int("b", 1).Msgf("x") }, `{"time":"2019-07-10T05:35:54.277Z","level":"info","a":{"b":1},"message":"x"}`},
+		{"msgs-empty", func(l *Logger) { l.Info().Msgs() }, `{"time":"2019-07-10T05:35:54.277Z","level":"info","message":""}`},
+		{"msgf-after-node", func(l *Logger) { l.LogNode = true; l.Info().Msgf("x") }, `{"time":"2019-07-10T05:35:54.277Z","level":"info","host_platform":"` + nodeName() + `","message":"x"}`},
+		{"msg-after-node", func(l *Logger) { l.LogNode = true; l.Info().Msg("x") }, `{"time":"2019-07-10T05:35:54.277Z","level":"info","host_platform":"` + nodeName() + `","message":"x"}`},
+		{"msgs-after-node", func(l *Logger) { l.LogNode = true; l.Info().Msgs("x", 1) }, `{"time":"2019-07-10T05:35:54.277Z","level":"info","host_platform":"` + nodeName() + `","message":"x1"}`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			c.log(&logger)
+			if got := b.String(); got != c.want+"\n" {
+				t.Errorf("got %s\nwant %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestEntryReset(t *testing.T) {
+	keyed := &Logger{Redactor: &Redactor{Keys: []string{"password"}}, PII: &PIIScanner{}}
+	cases := []struct {
+		name   string
+		logger *Logger
+		keyed  bool
+	}{
+		{"nil", nil, false},
+		{"plain", &Logger{}, false},
+		{"keyed", keyed, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := &Entry{
+				buf:     []byte(`{"a":1`),
+				logger:  keyed,
+				scanner: keyed.PII,
+				nest:    []int{1},
+				prefix:  []byte("a."),
+				labels:  []byte(`,"b":2`),
+				cbor:    true,
+				keyed:   true,
+				keying:  true,
+				field:   3,
+				value:   5,
+				levelAt: 1,
+			}
+			e.reset(c.logger)
+
+			var scanner *PIIScanner
+			if c.logger != nil {
+				scanner = c.logger.PII
+			}
+			if len(e.buf) != 0 || len(e.nest) != 0 || len(e.prefix) != 0 || len(e.labels) != 0 || e.cbor || e.keying {
+				t.Errorf("reset() kept the state of the previous entry %+v", e)
+			}
+			if e.logger != c.logger || e.scanner != scanner || e.keyed != c.keyed || e.field != -1 || e.levelAt != -1 {
+				t.Errorf("reset() = logger %p scanner %p keyed %v field %d levelAt %d, want %p %p %v -1 -1",
+					e.logger, e.scanner, e.keyed, e.field, e.levelAt, c.logger, scanner, c.keyed)
+			}
+		})
+	}
+
+	for name, e := range map[string]*Entry{"NewContext": NewContext(nil), "With": With(keyed)} {
+		if e.field != -1 || e.levelAt != -1 {
+			t.Errorf("%s() field %d levelAt %d, want -1 -1", name, e.field, e.levelAt)
+		}
+	}
+}
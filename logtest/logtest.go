@@ -0,0 +1,252 @@
+// Package logtest provides a recording writer, assertion helpers and deterministic clocks
+// and trace ids for tests of code which logs with the log package, e.g.
+//
+//	func TestHandler(t *testing.T) {
+//		logger, rec := logtest.NewTestLogger(t)
+//		logtest.SetTimeNow(t, logtest.FixedTime(time.Date(2019, 7, 10, 5, 35, 54, 0, time.UTC)))
+//
+//		logger.Info().Str("method", "GET").Int("status", 200).Msg("served")
+//
+//		rec.AssertLogged(t, log.InfoLevel, "served", log.Fields{"method": "GET", "status": 200})
+//	}
+package logtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oarkflow/log"
+)
+
+// Entry is a recorded entry decoded from its JSON, CBOR or logfmt encoding.
+type Entry struct {
+	// Time is the time field of RFC3339 times, it is zero for other time formats.
+	Time time.Time
+
+	// Level is the level of the entry.
+	Level log.Level
+
+	// Message is the message field of the entry.
+	Message string
+
+	// Fields are the other fields of the entry as decoded by encoding/json, e.g. numbers
+	// are float64 and nested objects are map[string]any.
+	Fields map[string]any
+}
+
+// Recorder is a Writer that records the entries written to it, it is safe for concurrent use.
+type Recorder struct {
+	// Schema specifies the key names of the recorded entries, e.g. the Schema of the logger.
+	// It uses the default names if empty.
+	Schema *log.Schema
+
+	// TimeField specifies the key of time, see Logger.TimeField.
+	TimeField string
+
+	// Writer specifies an optional writer which the entries are passed to after they are recorded.
+	Writer log.Writer
+
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// WriteEntry implements log.Writer.
+func (r *Recorder) WriteEntry(e *log.Entry) (int, error) {
+	fields, err := e.ToMap()
+	if err != nil {
+		return 0, err
+	}
+
+	timeKey, levelKey, messageKey := "time", "level", "message"
+	if r.Schema != nil && r.Schema.TimeKey != "" {
+		timeKey = r.Schema.TimeKey
+	}
+	if r.TimeField != "" {
+		timeKey = r.TimeField
+	}
+	if r.Schema != nil && r.Schema.LevelKey != "" {
+		levelKey = r.Schema.LevelKey
+	}
+	if r.Schema != nil && r.Schema.MessageKey != "" {
+		messageKey = r.Schema.MessageKey
+	}
+
+	entry := Entry{Level: e.Level, Fields: fields}
+	if s, ok := fields[timeKey].(string); ok {
+		entry.Time, _ = time.Parse(time.RFC3339Nano, s)
+	}
+	entry.Message, _ = fields[messageKey].(string)
+	delete(fields, timeKey)
+	delete(fields, levelKey)
+	delete(fields, messageKey)
+
+	r.mu.Lock()
+	r.entries = append(r.entries, entry)
+	r.mu.Unlock()
+
+	if r.Writer != nil {
+		return r.Writer.WriteEntry(e)
+	}
+	return len(e.Encoded()), nil
+}
+
+// Entries returns a copy of the recorded entries in the order they were written.
+func (r *Recorder) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Entry(nil), r.entries...)
+}
+
+// Len returns the number of recorded entries.
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.entries)
+}
+
+// Reset discards the recorded entries.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.entries = nil
+	r.mu.Unlock()
+}
+
+// Find returns the recorded entries of level and msg which have fields, see AssertLogged.
+func (r *Recorder) Find(level log.Level, msg string, fields log.Fields) []Entry {
+	var found []Entry
+	for _, entry := range r.Entries() {
+		if entry.Level == level && entry.Message == msg && entry.has(fields) {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+// AssertLogged reports an error to tb unless an entry of level and msg has been recorded
+// which has fields. Other fields of the entry are ignored, the values of fields are compared
+// to the recorded ones by their JSON encoding, e.g. an int 200 equals a recorded 200.
+func (r *Recorder) AssertLogged(tb testing.TB, level log.Level, msg string, fields log.Fields) bool {
+	tb.Helper()
+	if len(r.Find(level, msg, fields)) != 0 {
+		return true
+	}
+	tb.Errorf("logtest: no %s entry %q with fields %v was logged, recorded entries:\n%s", level, msg, fields, r)
+	return false
+}
+
+// AssertNotLogged reports an error to tb if an entry of level and msg has been recorded.
+func (r *Recorder) AssertNotLogged(tb testing.TB, level log.Level, msg string) bool {
+	tb.Helper()
+	if found := r.Find(level, msg, nil); len(found) != 0 {
+		tb.Errorf("logtest: unexpected %s entry %q was logged %d times", level, msg, len(found))
+		return false
+	}
+	return true
+}
+
+// String returns the recorded entries one per line.
+func (r *Recorder) String() string {
+	var b strings.Builder
+	for _, entry := range r.Entries() {
+		b.WriteString(entry.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// String returns the level, the message and the fields of the entry.
+func (e Entry) String() string {
+	fields, _ := json.Marshal(e.Fields)
+	return fmt.Sprintf("%s %q %s", e.Level, e.Message, fields)
+}
+
+// has reports whether the entry has fields.
+func (e Entry) has(fields log.Fields) bool {
+	for key, want := range fields {
+		got, ok := e.Fields[key]
+		if !ok || !reflect.DeepEqual(normalize(want), got) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize returns v as decoded from its JSON encoding like the recorded fields.
+func normalize(v any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var n any
+	if err = json.Unmarshal(b, &n); err != nil {
+		return v
+	}
+	return n
+}
+
+// Observe replaces the Writer of l with a Recorder until the end of the test.
+func Observe(tb testing.TB, l *log.Logger) *Recorder {
+	r := &Recorder{Schema: l.Schema, TimeField: l.TimeField}
+	w := l.Writer
+	l.Writer = r
+	tb.Cleanup(func() { l.Writer = w })
+	return r
+}
+
+// NewTestLogger returns a logger of all levels whose entries are recorded and written
+// by a ConsoleWriter to tb.Log.
+func NewTestLogger(tb testing.TB) (*log.Logger, *Recorder) {
+	r := &Recorder{
+		Writer: &log.ConsoleWriter{
+			QuoteString: true,
+			Writer:      tbWriter{tb},
+		},
+	}
+	return &log.Logger{Level: log.TraceLevel, Writer: r}, r
+}
+
+// tbWriter writes the lines of ConsoleWriter to tb.Log.
+type tbWriter struct {
+	tb testing.TB
+}
+
+func (w tbWriter) Write(p []byte) (int, error) {
+	w.tb.Helper()
+	w.tb.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// SetTimeNow replaces the clock of the log package with now until the end of the test,
+// see log.SetTimeNow. Tests which call it must not run in parallel.
+func SetTimeNow(tb testing.TB, now func() time.Time) {
+	log.SetTimeNow(now)
+	tb.Cleanup(func() { log.SetTimeNow(nil) })
+}
+
+// FixedTime returns a clock of SetTimeNow which always returns t.
+func FixedTime(t time.Time) func() time.Time {
+	return func() time.Time { return t }
+}
+
+// SetTraceIDGenerator replaces log.DefaultTraceIDGenerator with gen until the end of the test.
+// Loggers with a TraceIDGenerator keep using theirs. Tests which call it must not run in parallel.
+func SetTraceIDGenerator(tb testing.TB, gen log.TraceIDGenerator) {
+	prev := log.DefaultTraceIDGenerator
+	log.DefaultTraceIDGenerator = gen
+	tb.Cleanup(func() { log.DefaultTraceIDGenerator = prev })
+}
+
+// SequentialTraceIDs returns a generator of the trace ids prefix+"1", prefix+"2" and so on.
+func SequentialTraceIDs(prefix string) log.TraceIDGenerator {
+	var n atomic.Uint64
+	return log.TraceIDGeneratorFunc(func() string {
+		return prefix + strconv.FormatUint(n.Add(1), 10)
+	})
+}
@@ -0,0 +1,273 @@
+package logtest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oarkflow/log"
+)
+
+// fakeTB records the errors reported by the assertions.
+type fakeTB struct {
+	testing.TB
+	errors []string
+}
+
+func (tb *fakeTB) Helper() {}
+
+func (tb *fakeTB) Errorf(format string, args ...any) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecorder(t *testing.T) {
+	at := time.Date(2019, 7, 10, 5, 35, 54, 277000000, time.UTC)
+	cases := []struct {
+		name   string
+		logger log.Logger
+		time   time.Time
+		req    log.Fields
+	}{
+		{"json", log.Logger{}, at, log.Fields{"req": map[string]bool{"ok": true}}},
+		{"cbor", log.Logger{Encoding: log.EncodingCBOR}, at, log.Fields{"req": map[string]bool{"ok": true}}},
+		// logfmt keys of nested objects are flat
+		{"logfmt", log.Logger{Encoding: log.EncodingLogfmt}, at, log.Fields{"req.ok": true}},
+		{"schema", log.Logger{Schema: &log.Schema{TimeKey: "ts", LevelKey: "severity", MessageKey: "msg"}}, at, log.Fields{"req": map[string]bool{"ok": true}}},
+		{"time-field", log.Logger{TimeField: "@t"}, at, log.Fields{"req": map[string]bool{"ok": true}}},
+		{"unix", log.Logger{TimeFormat: log.TimeFormatUnix}, time.Time{}, log.Fields{"req": map[string]bool{"ok": true}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			SetTimeNow(t, FixedTime(at))
+			logger := c.logger
+			logger.Level = log.TraceLevel
+			logger.TimeLocation = time.UTC
+			rec := Observe(t, &logger)
+
+			logger.Info().Str("method", "GET").Int("status", 200).Dict("req", log.NewContext(nil).Bool("ok", true).Value()).Msg("served")
+			logger.Warn().Msg("slow")
+
+			entries := rec.Entries()
+			if len(entries) != 2 || rec.Len() != 2 {
+				t.Fatalf("recorded %d entries, want 2:\n%s", len(entries), rec)
+			}
+			e := entries[0]
+			if e.Level != log.InfoLevel || e.Message != "served" || !e.Time.Equal(c.time) {
+				t.Errorf("entry = %s %q %s, want info served %s", e.Level, e.Message, e.Time, c.time)
+			}
+			if len(e.Fields) != 3 {
+				t.Errorf("fields = %v, want method, status and req", e.Fields)
+			}
+			rec.AssertLogged(t, log.InfoLevel, "served", log.Fields{"method": "GET", "status": 200})
+			rec.AssertLogged(t, log.InfoLevel, "served", c.req)
+			rec.AssertLogged(t, log.WarnLevel, "slow", nil)
+			rec.AssertNotLogged(t, log.ErrorLevel, "served")
+		})
+	}
+}
+
+func TestRecorderAssertions(t *testing.T) {
+	cases := []struct {
+		name   string
+		assert func(tb testing.TB, r *Recorder) bool
+		ok     bool
+		err    string
+	}{
+		{"logged", func(tb testing.TB, r *Recorder) bool {
+			return r.AssertLogged(tb, log.InfoLevel, "served", log.Fields{"status": 200})
+		}, true, ""},
+		{"no-fields", func(tb testing.TB, r *Recorder) bool {
+			return r.AssertLogged(tb, log.InfoLevel, "served", nil)
+		}, true, ""},
+		{"float", func(tb testing.TB, r *Recorder) bool {
+			return r.AssertLogged(tb, log.InfoLevel, "served", log.Fields{"status": 200.0})
+		}, true, ""},
+		{"level", func(tb testing.TB, r *Recorder) bool {
+			return r.AssertLogged(tb, log.WarnLevel, "served", nil)
+		}, false, `logtest: no warn entry "served" with fields map[] was logged, recorded entries:` + "\n" + `info "served" {"method":"GET","status":200}` + "\n"},
+		{"value", func(tb testing.TB, r *Recorder) bool {
+			return r.AssertLogged(tb, log.InfoLevel, "served", log.Fields{"status": "200"})
+		}, false, `logtest: no info entry "served" with fields map[status:200] was logged`},
+		{"missing", func(tb testing.TB, r *Recorder) bool {
+			return r.AssertLogged(tb, log.InfoLevel, "served", log.Fields{"path": "/"})
+		}, false, `logtest: no info entry "served" with fields map[path:/] was logged`},
+		{"not-logged", func(tb testing.TB, r *Recorder) bool {
+			return r.AssertNotLogged(tb, log.ErrorLevel, "served")
+		}, true, ""},
+		{"unexpected", func(tb testing.TB, r *Recorder) bool {
+			return r.AssertNotLogged(tb, log.InfoLevel, "served")
+		}, false, `logtest: unexpected info entry "served" was logged 1 times`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			logger := log.Logger{Level: log.TraceLevel}
+			rec := Observe(t, &logger)
+			logger.Info().Str("method", "GET").Int("status", 200).Msg("served")
+
+			tb := &fakeTB{TB: t}
+			if ok := c.assert(tb, rec); ok != c.ok {
+				t.Errorf("assertion = %v, want %v", ok, c.ok)
+			}
+			switch {
+			case c.err == "" && len(tb.errors) != 0:
+				t.Errorf("unexpected errors %q", tb.errors)
+			case c.err != "" && (len(tb.errors) != 1 || !strings.HasPrefix(tb.errors[0], c.err)):
+				t.Errorf("errors = %q, want %q", tb.errors, c.err)
+			}
+		})
+	}
+}
+
+func TestRecorderFind(t *testing.T) {
+	logger := log.Logger{Level: log.TraceLevel}
+	rec := Observe(t, &logger)
+	for i := 0; i < 3; i++ {
+		logger.Info().Int("n", i%2).Msg("tick")
+	}
+	logger.Debug().Int("n", 0).Msg("tick")
+
+	cases := []struct {
+		name   string
+		level  log.Level
+		msg    string
+		fields log.Fields
+		want   int
+	}{
+		{"all", log.InfoLevel, "tick", nil, 3},
+		{"field", log.InfoLevel, "tick", log.Fields{"n": 0}, 2},
+		{"level", log.DebugLevel, "tick", log.Fields{"n": 0}, 1},
+		{"message", log.InfoLevel, "tock", nil, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := len(rec.Find(c.level, c.msg, c.fields)); got != c.want {
+				t.Errorf("Find() found %d, want %d", got, c.want)
+			}
+		})
+	}
+
+	rec.Reset()
+	if rec.Len() != 0 || len(rec.Entries()) != 0 {
+		t.Errorf("Reset() kept %d entries", rec.Len())
+	}
+}
+
+func TestRecorderWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := log.IOWriter{Writer: &b}
+	logger := log.Logger{Level: log.InfoLevel, Writer: w}
+	rec := Observe(t, &logger)
+	rec.Writer = w
+
+	logger.Info().Msg("hello")
+
+	if rec.Len() != 1 || !strings.Contains(b.String(), `"message":"hello"`) {
+		t.Errorf("recorded %d entries, written %q", rec.Len(), b.String())
+	}
+
+	t.Run("restore", func(t *testing.T) {
+		Observe(t, &logger)
+	})
+	if logger.Writer != rec {
+		t.Errorf("Writer = %T, want the recorder restored after the subtest", logger.Writer)
+	}
+}
+
+func TestRecorderConcurrency(t *testing.T) {
+	logger := log.Logger{Level: log.InfoLevel}
+	rec := Observe(t, &logger)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Info().Int("worker", i).Msg("work")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := rec.Len(); got != 800 {
+		t.Errorf("Len() = %d, want 800", got)
+	}
+	if got := len(rec.Find(log.InfoLevel, "work", log.Fields{"worker": 3})); got != 100 {
+		t.Errorf("Find() found %d, want 100", got)
+	}
+}
+
+func TestNewTestLogger(t *testing.T) {
+	logger, rec := NewTestLogger(t)
+	SetTimeNow(t, FixedTime(time.Date(2019, 7, 10, 5, 35, 54, 0, time.UTC)))
+
+	logger.Trace().Str("s", "a b").Msg("traced")
+
+	if logger.Level != log.TraceLevel {
+		t.Errorf("Level = %s, want trace", logger.Level)
+	}
+	rec.AssertLogged(t, log.TraceLevel, "traced", log.Fields{"s": "a b"})
+}
+
+func TestSetTimeNow(t *testing.T) {
+	at := time.Date(2019, 7, 10, 5, 35, 54, 123000000, time.UTC)
+
+	t.Run("fixed", func(t *testing.T) {
+		SetTimeNow(t, FixedTime(at))
+		logger := log.Logger{Level: log.InfoLevel}
+		rec := Observe(t, &logger)
+		logger.Info().Msg("a")
+		logger.Info().Msg("b")
+		for _, e := range rec.Entries() {
+			if !e.Time.Equal(at) {
+				t.Errorf("time = %s, want %s", e.Time, at)
+			}
+		}
+	})
+
+	logger := log.Logger{Level: log.InfoLevel}
+	rec := Observe(t, &logger)
+	logger.Info().Msg("now")
+	if e := rec.Entries()[0]; e.Time.Equal(at) || time.Since(e.Time) > time.Minute {
+		t.Errorf("time = %s, want the restored clock", e.Time)
+	}
+}
+
+func TestSequentialTraceIDs(t *testing.T) {
+	cases := []struct {
+		name string
+		gen  log.TraceIDGenerator
+		want []string
+	}{
+		{"default", nil, []string{"req-1", "req-2", "req-3"}},
+		{"logger", SequentialTraceIDs("own-"), []string{"own-1", "own-2", "own-3"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			SetTraceIDGenerator(t, SequentialTraceIDs("req-"))
+			logger := log.Logger{Level: log.InfoLevel, EnableTracing: true, TraceIDGenerator: c.gen}
+			rec := Observe(t, &logger)
+
+			for range c.want {
+				logger.Info().Msg("traced")
+			}
+
+			for i, e := range rec.Entries() {
+				if got := e.Fields["trace_id"]; got != c.want[i] {
+					t.Errorf("trace_id = %v, want %s", got, c.want[i])
+				}
+			}
+		})
+	}
+
+	if id := log.DefaultTraceIDGenerator.NewTraceID(); strings.HasPrefix(id, "req-") {
+		t.Errorf("DefaultTraceIDGenerator is not restored, generated %s", id)
+	}
+}
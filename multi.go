@@ -48,7 +48,7 @@ func (w *MultiLevelWriter) Close() (err error) {
 // WriteEntry implements entryWriter.
 func (w *MultiLevelWriter) WriteEntry(e *Entry) (n int, err error) {
 	var err1 error
-	switch e.Level {
+	switch e.Level.rank() {
 	case noLevel, PanicLevel, FatalLevel, ErrorLevel:
 		if w.ErrorWriter != nil {
 			n, err1 = w.ErrorWriter.WriteEntry(e)
@@ -74,7 +74,7 @@ func (w *MultiLevelWriter) WriteEntry(e *Entry) (n int, err error) {
 		}
 	}
 
-	if w.ConsoleWriter != nil && e.Level >= w.ConsoleLevel {
+	if w.ConsoleWriter != nil && e.Level.rank() >= w.ConsoleLevel {
 		_, _ = w.ConsoleWriter.WriteEntry(e)
 	}
 
@@ -161,7 +161,16 @@ func (w *FormattedMultiLevelWriter) WriteEntry(e *Entry) (n int, err error) {
 		var formatErr error
 
 		if formatter != nil {
-			formattedEntry, formatErr = formatter.Format(entry)
+			src := entry
+			if entry.cbor || entry.logfmt {
+				// formatters read JSON entries
+				src = &Entry{
+					buf:    entry.appendJSON(nil),
+					Level:  entry.Level,
+					logger: entry.logger,
+				}
+			}
+			formattedEntry, formatErr = formatter.Format(src)
 			if formatErr != nil {
 				// If formatting fails, fall back to original buffer
 				_, _ = writer.WriteEntry(entry)
@@ -186,7 +195,7 @@ func (w *FormattedMultiLevelWriter) WriteEntry(e *Entry) (n int, err error) {
 		}
 	}
 
-	switch e.Level {
+	switch e.Level.rank() {
 	case noLevel, PanicLevel, FatalLevel, ErrorLevel:
 		writeWithFormatter(w.ErrorWriter, w.ErrorFormatter, e)
 	case WarnLevel:
@@ -195,7 +204,7 @@ func (w *FormattedMultiLevelWriter) WriteEntry(e *Entry) (n int, err error) {
 		writeWithFormatter(w.InfoWriter, w.InfoFormatter, e)
 	}
 
-	if w.ConsoleWriter != nil && e.Level >= w.ConsoleLevel {
+	if w.ConsoleWriter != nil && e.Level.rank() >= w.ConsoleLevel {
 		writeWithFormatter(w.ConsoleWriter, w.ConsoleFormatter, e)
 	}
 
@@ -0,0 +1,614 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OTLPWriter is a Writer that converts entries into OpenTelemetry log records and exports
+// them in batches as the JSON of ExportLogsServiceRequest over HTTP, e.g.
+//
+//	logger := log.Logger{
+//		Writer: &log.OTLPWriter{
+//			Endpoint: "http://localhost:4318/v1/logs",
+//			Resource: map[string]string{"service.name": "checkout"},
+//			Gzip:     true,
+//		},
+//	}
+//
+// The message is the body of a record and the other fields are its attributes, except the
+// trace and span ids of hex which are the ids of the record. Objects and arrays are kvlist
+// and array values. Caller, goid, error and stack are mapped to the "code.*", "thread.id" and
+// "exception.*" semantic conventions.
+type OTLPWriter struct {
+	// Endpoint specifies the URL of the logs endpoint, e.g. "http://localhost:4318/v1/logs".
+	Endpoint string
+
+	// Headers specifies the additional headers of requests, e.g. authorization.
+	Headers map[string]string
+
+	// Resource specifies the attributes of the resource, e.g. "service.name".
+	Resource map[string]string
+
+	// Scope specifies the name of the instrumentation scope, it uses "github.com/oarkflow/log" if empty.
+	Scope string
+
+	// BatchSize specifies the maximum number of records of a request, it uses 512 if zero.
+	BatchSize int
+
+	// FlushInterval specifies the maximum delay of a record before exported, it uses 1s if zero.
+	FlushInterval time.Duration
+
+	// MaxRetries specifies the number of retries of failed requests, it uses 3 if zero.
+	// Requests are not retried if negative.
+	MaxRetries int
+
+	// Timeout specifies the timeout of a request, it uses 10s if zero.
+	Timeout time.Duration
+
+	// Gzip determines if compresses the requests with gzip.
+	Gzip bool
+
+	// MaxConcurrency specifies the maximum number of requests in flight, it uses 4 if zero.
+	// The batches exported meanwhile are queued.
+	MaxConcurrency int
+
+	// MaxQueue specifies the maximum number of batches queued while MaxConcurrency requests
+	// are in flight, it uses 64 if zero. The batches exported while full are dropped, see Dropped.
+	MaxQueue int
+
+	// Client specifies the HTTP client, it uses http.DefaultClient if empty.
+	Client Client
+
+	once     sync.Once
+	resource []byte
+	mu       sync.Mutex
+	batch    []byte
+	count    int
+	timer    *time.Timer
+	queue    [][]byte
+	inflight pending
+	dropped  atomic.Uint64
+}
+
+// WriteEntry implements Writer.
+func (w *OTLPWriter) WriteEntry(e *Entry) (n int, err error) {
+	w.once.Do(w.init)
+
+	b := bbpool.Get().(*bb)
+	b.B = e.appendJSON(b.B[:0])
+	defer bbpool.Put(b)
+
+	var args FormatterArgs
+	parseFormatterArgs(b.B, &args, e.schema())
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.count != 0 {
+		w.batch = append(w.batch, ',')
+	}
+	w.batch = w.record(w.batch, e, &args)
+	w.count++
+
+	switch {
+	case w.count >= w.batchSize():
+		w.export()
+	case w.timer == nil:
+		interval := w.FlushInterval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		w.timer = time.AfterFunc(interval, func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			w.export()
+		})
+	}
+
+	return len(e.buf), nil
+}
+
+// Flush implements Flusher, it waits for the requests in flight and exports the queued and
+// pending records.
+func (w *OTLPWriter) Flush() (err error) {
+	w.mu.Lock()
+	if body, _ := w.take(); body != nil {
+		w.queue = append(w.queue, body)
+	}
+	w.mu.Unlock()
+	w.inflight.wait()
+
+	w.mu.Lock()
+	queue := w.queue
+	w.queue = nil
+	w.mu.Unlock()
+	for _, body := range queue {
+		if e := w.post(body); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// Dropped returns the number of records dropped as the queue of MaxQueue batches was full.
+func (w *OTLPWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Close implements io.Closer, it flushes the pending records.
+func (w *OTLPWriter) Close() error {
+	return w.Flush()
+}
+
+func (w *OTLPWriter) batchSize() int {
+	if w.BatchSize <= 0 {
+		return 512
+	}
+	return w.BatchSize
+}
+
+// init renders the resource and scope of requests.
+func (w *OTLPWriter) init() {
+	keys := make([]string, 0, len(w.Resource))
+	for key := range w.Resource {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	e := &Entry{}
+	e.buf = append(e.buf, "{\"resourceLogs\":[{\"resource\":{\"attributes\":["...)
+	for i, key := range keys {
+		if i != 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = append(e.buf, "{\"key\":\""...)
+		e.string(key)
+		e.buf = append(e.buf, "\",\"value\":{\"stringValue\":\""...)
+		e.string(w.Resource[key])
+		e.buf = append(e.buf, "\"}}"...)
+	}
+	scope := w.Scope
+	if scope == "" {
+		scope = "github.com/oarkflow/log"
+	}
+	e.buf = append(e.buf, "]},\"scopeLogs\":[{\"scope\":{\"name\":\""...)
+	e.string(scope)
+	e.buf = append(e.buf, "\"},\"logRecords\":["...)
+	w.resource = e.buf
+}
+
+// export sends the pending records in a new request, w.mu must be held. The requests are
+// sent by up to MaxConcurrency goroutines, the ones exported meanwhile are queued for them,
+// or for Flush while it drains them.
+func (w *OTLPWriter) export() {
+	body, count := w.take()
+	if body == nil {
+		return
+	}
+
+	limit := w.MaxConcurrency
+	if limit <= 0 {
+		limit = 4
+	}
+	if ok, _ := w.inflight.tryAdd(limit); ok {
+		go w.run(body)
+		return
+	}
+
+	size := w.MaxQueue
+	if size <= 0 {
+		size = 64
+	}
+	if len(w.queue) >= size {
+		w.dropped.Add(uint64(count))
+		return
+	}
+	w.queue = append(w.queue, body)
+}
+
+// run sends body and then the queued requests until the queue is empty.
+func (w *OTLPWriter) run(body []byte) {
+	for {
+		if err := w.post(body); err != nil {
+			log.Printf("OTLPWriter: %v", err)
+		}
+
+		w.mu.Lock()
+		if len(w.queue) == 0 {
+			// uncounted under w.mu, so export starts a new goroutine for the next request
+			w.inflight.done()
+			w.mu.Unlock()
+			return
+		}
+		body = w.queue[0]
+		w.queue[0] = nil
+		w.queue = w.queue[1:]
+		w.mu.Unlock()
+	}
+}
+
+// take returns the request of the pending records and their count, or nil if none. w.mu must be held.
+func (w *OTLPWriter) take() (body []byte, count int) {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if w.count == 0 {
+		return nil, 0
+	}
+
+	body = make([]byte, 0, len(w.resource)+len(w.batch)+8)
+	body = append(body, w.resource...)
+	body = append(body, w.batch...)
+	body = append(body, "]}]}]}"...)
+	count = w.count
+	w.batch, w.count = w.batch[:0], 0
+	return
+}
+
+// post sends body to the endpoint with retries.
+func (w *OTLPWriter) post(body []byte) (err error) {
+	if w.Gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err = zw.Write(body); err == nil {
+			err = zw.Close()
+		}
+		if err != nil {
+			return err
+		}
+		body = buf.Bytes()
+	}
+
+	retries := w.MaxRetries
+	if retries == 0 {
+		retries = 3
+	}
+	backoff := 500 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		var retry time.Duration
+		retry, err = w.send(body)
+		if err == nil || retry < 0 || attempt >= retries {
+			return err
+		}
+		if retry == 0 {
+			retry = backoff
+			backoff *= 2
+		}
+		time.Sleep(retry)
+	}
+}
+
+// send sends body once. It returns the delay before retry, or a negative delay if not retryable.
+func (w *OTLPWriter) send(body []byte) (time.Duration, error) {
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for key, value := range w.Headers {
+		req.Header.Set(key, value)
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable, resp.StatusCode == http.StatusGatewayTimeout:
+		var retry time.Duration
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			retry = time.Duration(seconds) * time.Second
+		}
+		return retry, fmt.Errorf("received status code %d", resp.StatusCode)
+	default:
+		return -1, fmt.Errorf("received status code %d", resp.StatusCode)
+	}
+}
+
+// record appends the LogRecord JSON of entry e with parsed args to dst.
+func (w *OTLPWriter) record(dst []byte, e *Entry, args *FormatterArgs) []byte {
+	r := Entry{buf: dst}
+	observed := timeNow().UnixNano()
+	r.buf = append(r.buf, "{\"timeUnixNano\":\""...)
+	r.buf = strconv.AppendInt(r.buf, otlpTime(args.Time, observed), 10)
+	r.buf = append(r.buf, "\",\"observedTimeUnixNano\":\""...)
+	r.buf = strconv.AppendInt(r.buf, observed, 10)
+	r.buf = append(r.buf, '"')
+	if e.Level != noLevel {
+		r.buf = append(r.buf, ",\"severityNumber\":"...)
+		r.buf = strconv.AppendInt(r.buf, int64(otlpSeverity(e.Level)), 10)
+		r.buf = append(r.buf, ",\"severityText\":\""...)
+		r.string(e.Level.String())
+		r.buf = append(r.buf, '"')
+	}
+	if args.Message != "" {
+		r.buf = append(r.buf, ",\"body\":{\"stringValue\":\""...)
+		r.string(args.Message)
+		r.buf = append(r.buf, "\"}"...)
+	}
+
+	traceKey, spanKey := "trace_id", e.schema().spanIDKey()
+	if e.logger != nil {
+		traceKey = e.logger.traceIDField()
+	}
+	errorKey := e.schema().errorKey()
+	var traceID, spanID string
+
+	r.buf = append(r.buf, ",\"attributes\":["...)
+	n := len(r.buf)
+	if args.Caller != "" {
+		file, line := args.Caller, ""
+		if i := strings.LastIndexByte(file, ':'); i > 0 {
+			file, line = file[:i], file[i+1:]
+		}
+		r.otlpAttr("code.filepath", file, 's')
+		if line != "" {
+			r.otlpAttr("code.lineno", line, 'n')
+		}
+	}
+	if args.CallerFunc != "" {
+		r.otlpAttr("code.function", args.CallerFunc, 's')
+	}
+	if args.Goid != "" {
+		r.otlpAttr("thread.id", args.Goid, 'n')
+	}
+	if args.Stack != "" {
+		r.otlpAttr("exception.stacktrace", args.Stack, 's')
+	}
+	if args.Category != "" {
+		r.otlpAttr("category", args.Category, 's')
+	}
+	for _, kv := range args.KeyValues {
+		switch {
+		case kv.Key == traceKey && otlpID(&kv.Value, 32):
+			traceID = kv.Value
+		case kv.Key == spanKey && otlpID(&kv.Value, 16):
+			spanID = kv.Value
+		case kv.Key == errorKey && kv.ValueType == 's':
+			r.otlpAttr("exception.message", kv.Value, kv.ValueType)
+		default:
+			r.otlpAttr(kv.Key, kv.Value, kv.ValueType)
+		}
+	}
+	if len(r.buf) > n {
+		// remove the leading comma of the first attribute
+		r.buf = append(r.buf[:n], r.buf[n+1:]...)
+	}
+	r.buf = append(r.buf, ']')
+
+	if traceID != "" {
+		r.buf = append(r.buf, ",\"traceId\":\""...)
+		r.buf = append(r.buf, traceID...)
+		r.buf = append(r.buf, '"')
+	}
+	if spanID != "" {
+		r.buf = append(r.buf, ",\"spanId\":\""...)
+		r.buf = append(r.buf, spanID...)
+		r.buf = append(r.buf, '"')
+	}
+	r.buf = append(r.buf, '}')
+	return r.buf
+}
+
+// otlpAttr appends an attribute of key and value of parsed type typ.
+func (e *Entry) otlpAttr(key, value string, typ byte) {
+	e.buf = append(e.buf, ",{\"key\":\""...)
+	e.string(key)
+	e.buf = append(e.buf, "\",\"value\":"...)
+	switch typ {
+	case 't', 'f':
+		e.buf = append(e.buf, "{\"boolValue\":"...)
+		e.buf = append(e.buf, value...)
+		e.buf = append(e.buf, '}')
+	case 'n':
+		if strings.ContainsAny(value, ".eE") {
+			e.buf = append(e.buf, "{\"doubleValue\":"...)
+			e.buf = append(e.buf, value...)
+			e.buf = append(e.buf, '}')
+		} else {
+			e.buf = append(e.buf, "{\"intValue\":\""...)
+			e.buf = append(e.buf, value...)
+			e.buf = append(e.buf, "\"}"...)
+		}
+	case 0:
+		// null
+		e.buf = append(e.buf, '{', '}')
+	case 'o':
+		e.otlpValue([]byte(value))
+	default:
+		e.buf = append(e.buf, "{\"stringValue\":\""...)
+		e.string(value)
+		e.buf = append(e.buf, "\"}"...)
+	}
+	e.buf = append(e.buf, '}')
+}
+
+// otlpValue appends the JSON value json as an AnyValue, objects as kvlistValue and arrays as
+// arrayValue. It returns the index after the value.
+func (e *Entry) otlpValue(json []byte) int {
+	i := skipSpaces(json, 0)
+	if i >= len(json) {
+		e.buf = append(e.buf, '{', '}')
+		return i
+	}
+	switch json[i] {
+	case '{':
+		e.buf = append(e.buf, "{\"kvlistValue\":{\"values\":["...)
+		n := len(e.buf)
+		for i++; i < len(json) && json[i] != '}'; {
+			if json[i] != '"' {
+				i++
+				continue
+			}
+			j, key, _, ok := jsonParseString(json, i+1)
+			if !ok {
+				break
+			}
+			j = skipSpaces(json, j)
+			if j < len(json) && json[j] == ':' {
+				j++
+			}
+			if len(e.buf) != n {
+				e.buf = append(e.buf, ',')
+			}
+			e.buf = append(e.buf, "{\"key\":"...)
+			e.buf = append(e.buf, key...)
+			e.buf = append(e.buf, ",\"value\":"...)
+			i = j + e.otlpValue(json[j:])
+			e.buf = append(e.buf, '}')
+		}
+		e.buf = append(e.buf, "]}}"...)
+		return i + 1
+	case '[':
+		e.buf = append(e.buf, "{\"arrayValue\":{\"values\":["...)
+		n := len(e.buf)
+		for i++; i < len(json) && json[i] != ']'; {
+			if json[i] <= ' ' || json[i] == ',' {
+				i++
+				continue
+			}
+			if len(e.buf) != n {
+				e.buf = append(e.buf, ',')
+			}
+			i += e.otlpValue(json[i:])
+		}
+		e.buf = append(e.buf, "]}}"...)
+		return i + 1
+	}
+
+	j, typ, val, ok := jsonParseAny(json, i, true)
+	if !ok {
+		e.buf = append(e.buf, '{', '}')
+		return j
+	}
+	switch typ {
+	case 's', 'S':
+		// quoted JSON strings are valid as they are
+		e.buf = append(e.buf, "{\"stringValue\":"...)
+		e.buf = append(e.buf, val...)
+		e.buf = append(e.buf, '}')
+	case 'n':
+		if bytes.ContainsAny(val, ".eE") {
+			e.buf = append(e.buf, "{\"doubleValue\":"...)
+			e.buf = append(e.buf, val...)
+			e.buf = append(e.buf, '}')
+		} else {
+			e.buf = append(e.buf, "{\"intValue\":\""...)
+			e.buf = append(e.buf, val...)
+			e.buf = append(e.buf, "\"}"...)
+		}
+	case 't', 'f':
+		e.buf = append(e.buf, "{\"boolValue\":"...)
+		e.buf = append(e.buf, val...)
+		e.buf = append(e.buf, '}')
+	default:
+		// null
+		e.buf = append(e.buf, '{', '}')
+	}
+	return j
+}
+
+// otlpID reports whether id ends with an id of n hex digits, id is trimmed to the hex id.
+// Prefixed ids, e.g. the trace ids of GCPSchema, are supported.
+func otlpID(id *string, n int) bool {
+	s := *id
+	if i := strings.LastIndexByte(s, '/'); i >= 0 {
+		s = s[i+1:]
+	}
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	*id = s
+	return true
+}
+
+// otlpSeverity returns the OpenTelemetry severity number of level.
+func otlpSeverity(level Level) int {
+	switch level {
+	case TraceLevel:
+		return 1 // TRACE
+	case DebugLevel:
+		return 5 // DEBUG
+	case InfoLevel:
+		return 9 // INFO
+	case WarnLevel:
+		return 13 // WARN
+	case ErrorLevel:
+		return 17 // ERROR
+	case FatalLevel:
+		return 21 // FATAL
+	case PanicLevel:
+		return 24 // FATAL4
+	}
+	// custom levels by their syslog severity
+	return [8]int{23, 22, 21, 17, 13, 10, 9, 5}[level.syslog()]
+}
+
+// otlpTime returns the Unix nanoseconds of time value s of entries, or def if unknown.
+func otlpTime(s string, def int64) int64 {
+	if s == "" {
+		return def
+	}
+	if sec, frac, ok := strings.Cut(s, "."); ok && !strings.ContainsAny(s, "-:") {
+		// TimeFormatUnixWithMs
+		n, err1 := strconv.ParseInt(sec, 10, 64)
+		ms, err2 := strconv.ParseInt(frac, 10, 64)
+		if err1 == nil && err2 == nil {
+			return n*int64(time.Second) + ms*int64(time.Millisecond)
+		}
+		return def
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		switch {
+		case len(s) <= 10:
+			return n * int64(time.Second)
+		case len(s) <= 13:
+			return n * int64(time.Millisecond)
+		case len(s) <= 16:
+			return n * int64(time.Microsecond)
+		}
+		return n
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t.UnixNano()
+	}
+	return def
+}
@@ -0,0 +1,263 @@
+package log
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// otlpRequest is the JSON of ExportLogsServiceRequest.
+type otlpRequest struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []otlpKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			Scope struct {
+				Name string `json:"name"`
+			} `json:"scope"`
+			LogRecords []otlpRecord `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+type otlpRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	Body           map[string]any `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes"`
+	TraceID        string         `json:"traceId"`
+	SpanID         string         `json:"spanId"`
+}
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+// otlpServer returns a server which decodes the posted requests to the returned channel.
+func otlpServer(t *testing.T, handler func()) (*httptest.Server, chan otlpRequest) {
+	t.Helper()
+	requests := make(chan otlpRequest, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if handler != nil {
+			handler()
+		}
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("gzip.NewReader() error = %v", err)
+				return
+			}
+			body = zr
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			t.Errorf("decode request error = %v", err)
+		}
+		requests <- req
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func TestOTLPWriter(t *testing.T) {
+	cases := []struct {
+		name     string
+		log      func(l *Logger)
+		severity int
+		text     string
+		body     map[string]any
+		attrs    []otlpKeyValue
+		traceID  string
+		spanID   string
+	}{
+		{
+			name:     "scalars",
+			log:      func(l *Logger) { l.Info().Str("s", "a\"b").Int("n", 1).Float64("f", 1.5).Bool("ok", true).Msg("hello") },
+			severity: 9,
+			text:     "info",
+			body:     map[string]any{"stringValue": "hello"},
+			attrs: []otlpKeyValue{
+				{"s", map[string]any{"stringValue": "a\"b"}},
+				{"n", map[string]any{"intValue": "1"}},
+				{"f", map[string]any{"doubleValue": 1.5}},
+				{"ok", map[string]any{"boolValue": true}},
+			},
+		},
+		{
+			name: "object",
+			log: func(l *Logger) {
+				l.Warn().Dict("req", NewContext(nil).Str("method", "GET").Ints("codes", []int{200, 404}).Value()).Msg("slow")
+			},
+			severity: 13,
+			text:     "warn",
+			body:     map[string]any{"stringValue": "slow"},
+			attrs: []otlpKeyValue{
+				{"req", map[string]any{"kvlistValue": map[string]any{"values": []any{
+					map[string]any{"key": "method", "value": map[string]any{"stringValue": "GET"}},
+					map[string]any{"key": "codes", "value": map[string]any{"arrayValue": map[string]any{"values": []any{
+						map[string]any{"intValue": "200"},
+						map[string]any{"intValue": "404"},
+					}}}},
+				}}}},
+			},
+		},
+		{
+			name:     "array",
+			log:      func(l *Logger) { l.Debug().Strs("tags", []string{"x", "y"}).Msg("tagged") },
+			severity: 5,
+			text:     "debug",
+			body:     map[string]any{"stringValue": "tagged"},
+			attrs: []otlpKeyValue{
+				{"tags", map[string]any{"arrayValue": map[string]any{"values": []any{
+					map[string]any{"stringValue": "x"},
+					map[string]any{"stringValue": "y"},
+				}}}},
+			},
+		},
+		{
+			name: "error-trace",
+			log: func(l *Logger) {
+				l.Error().Str("trace_id", "4bf92f3577b34da6a3ce929d0e0e4736").Str("span_id", "00f067aa0ba902b7").Str("error", "refused").Msg("dial")
+			},
+			severity: 17,
+			text:     "error",
+			body:     map[string]any{"stringValue": "dial"},
+			attrs: []otlpKeyValue{
+				{"exception.message", map[string]any{"stringValue": "refused"}},
+			},
+			traceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			spanID:  "00f067aa0ba902b7",
+		},
+		{
+			name:     "trace",
+			log:      func(l *Logger) { l.Trace().Msg("step") },
+			severity: 1,
+			text:     "trace",
+			body:     map[string]any{"stringValue": "step"},
+		},
+		{
+			name:     "custom",
+			log:      func(l *Logger) { l.WithLevel(testNoticeLevel).Msg("changed") },
+			severity: 10,
+			text:     "notice",
+			body:     map[string]any{"stringValue": "changed"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server, requests := otlpServer(t, nil)
+			w := &OTLPWriter{
+				Endpoint: server.URL,
+				Resource: map[string]string{"service.name": "checkout"},
+				Gzip:     true,
+			}
+			logger := Logger{Level: TraceLevel, Writer: w}
+
+			c.log(&logger)
+			if err := w.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			var req otlpRequest
+			select {
+			case req = <-requests:
+			default:
+				t.Fatal("no request posted")
+			}
+			if len(req.ResourceLogs) != 1 || len(req.ResourceLogs[0].ScopeLogs) != 1 || len(req.ResourceLogs[0].ScopeLogs[0].LogRecords) != 1 {
+				t.Fatalf("request = %+v, want a record", req)
+			}
+			resource := req.ResourceLogs[0].Resource.Attributes
+			if want := []otlpKeyValue{{"service.name", map[string]any{"stringValue": "checkout"}}}; !reflect.DeepEqual(resource, want) {
+				t.Errorf("resource = %v, want %v", resource, want)
+			}
+			if name := req.ResourceLogs[0].ScopeLogs[0].Scope.Name; name != "github.com/oarkflow/log" {
+				t.Errorf("scope = %q", name)
+			}
+
+			r := req.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+			if r.SeverityNumber != c.severity || r.SeverityText != c.text {
+				t.Errorf("severity = %d %q, want %d %q", r.SeverityNumber, r.SeverityText, c.severity, c.text)
+			}
+			if !reflect.DeepEqual(r.Body, c.body) {
+				t.Errorf("body = %v, want %v", r.Body, c.body)
+			}
+			if len(r.Attributes) != 0 || len(c.attrs) != 0 {
+				if !reflect.DeepEqual(r.Attributes, c.attrs) {
+					t.Errorf("attributes = %v, want %v", r.Attributes, c.attrs)
+				}
+			}
+			if r.TraceID != c.traceID || r.SpanID != c.spanID {
+				t.Errorf("ids = %q %q, want %q %q", r.TraceID, r.SpanID, c.traceID, c.spanID)
+			}
+		})
+	}
+}
+
+func TestOTLPWriterConcurrency(t *testing.T) {
+	release := make(chan struct{})
+	var once sync.Once
+	server, requests := otlpServer(t, func() { <-release })
+	w := &OTLPWriter{Endpoint: server.URL, BatchSize: 1, MaxConcurrency: 1, MaxQueue: 1, MaxRetries: -1}
+	logger := Logger{Level: InfoLevel, Writer: w}
+	defer once.Do(func() { close(release) })
+
+	// the first batch is in flight until released, the second one is queued and the third dropped
+	logger.Info().Msg("first")
+	logger.Info().Msg("second")
+	logger.Info().Msg("third")
+	if got := w.Dropped(); got != 1 {
+		t.Errorf("Dropped() = %d, want 1", got)
+	}
+
+	done := make(chan error)
+	go func() { done <- w.Flush() }()
+	select {
+	case <-done:
+		t.Fatal("Flush() returned with a request in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// entries are not blocked by the requests drained by Flush
+	logged := make(chan struct{})
+	go func() {
+		logger.Info().Msg("fourth")
+		close(logged)
+	}()
+	select {
+	case <-logged:
+	case <-time.After(time.Second):
+		t.Fatal("WriteEntry() blocked while Flush drains the requests")
+	}
+	if got := w.Dropped(); got != 2 {
+		t.Errorf("Dropped() = %d, want 2", got)
+	}
+
+	once.Do(func() { close(release) })
+	if err := <-done; err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	for _, want := range []string{"first", "second"} {
+		req := <-requests
+		if got := req.ResourceLogs[0].ScopeLogs[0].LogRecords[0].Body["stringValue"]; got != want {
+			t.Errorf("exported %v, want %s", got, want)
+		}
+	}
+	select {
+	case req := <-requests:
+		t.Errorf("unexpected request %+v", req)
+	default:
+	}
+}
@@ -0,0 +1,67 @@
+package log
+
+import (
+	"reflect"
+)
+
+// RecoverOptions specifies the behavior of Recover.
+type RecoverOptions struct {
+	// Message specifies the message of the entry, it uses "panic recovered" if empty.
+	Message string
+
+	// Stack specifies the options of the structured stack, it uses DefaultStackOptions if nil.
+	Stack *StackOptions
+
+	// Handler specifies an optional function called with the recovered value after it is logged.
+	Handler func(v any)
+
+	// Repanic determines if panics again with the recovered value after it is logged.
+	Repanic bool
+}
+
+// Recover recovers a panic and logs it at panic level with the "panic" value, its
+// "panic_type" and a structured stack, without panicking again unless opts.Repanic.
+// It must be deferred directly, e.g.
+//
+//	defer log.Recover(logger, log.RecoverOptions{})
+func Recover(logger *Logger, opts RecoverOptions) {
+	v := recover()
+	if v == nil {
+		return
+	}
+
+	if logger == nil {
+		logger = &DefaultLogger
+	}
+	if !logger.silent(PanicLevel) {
+		stack := opts.Stack
+		if stack == nil {
+			stack = &DefaultStackOptions
+		}
+		msg := opts.Message
+		if msg == "" {
+			msg = "panic recovered"
+		}
+		e := logger.header(PanicLevel)
+		e.Any("panic", v)
+		e.Str("panic_type", reflect.TypeOf(v).String())
+		e.stackFrames(stack)
+		e.send(msg, true, false)
+	}
+
+	if opts.Handler != nil {
+		opts.Handler(v)
+	}
+	if opts.Repanic {
+		panic(v)
+	}
+}
+
+// Go starts fn in a new goroutine guarded by Recover, so a panic of fn is logged
+// by logger instead of crashing the program.
+func Go(logger *Logger, fn func()) {
+	go func() {
+		defer Recover(logger, RecoverOptions{})
+		fn()
+	}()
+}
@@ -0,0 +1,100 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   any
+		opts    RecoverOptions
+		message string
+		typ     string
+	}{
+		{"string", "boom", RecoverOptions{}, "panic recovered", "string"},
+		{"error", errors.New("bad"), RecoverOptions{Message: "worker crashed"}, "worker crashed", "*errors.errorString"},
+		{"int", 42, RecoverOptions{Stack: &StackOptions{Limit: 1}}, "panic recovered", "int"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			var handled any
+			c.opts.Handler = func(v any) { handled = v }
+			func() {
+				defer Recover(&logger, c.opts)
+				panic(c.value)
+			}()
+
+			var entry struct {
+				Level     string
+				Message   string
+				Panic     any
+				PanicType string `json:"panic_type"`
+				Stack     []stackFrame
+			}
+			if err := json.Unmarshal(b.Bytes(), &entry); err != nil {
+				t.Fatalf("invalid JSON %s: %v", b.Bytes(), err)
+			}
+			// the frames of this package are hidden by DefaultStackOptions
+			if entry.Level != "panic" || entry.Message != c.message || entry.PanicType != c.typ || entry.Stack == nil {
+				t.Errorf("got %s", b.Bytes())
+			}
+			if c.opts.Stack != nil && len(entry.Stack) != c.opts.Stack.Limit {
+				t.Errorf("got %d frames, want %d", len(entry.Stack), c.opts.Stack.Limit)
+			}
+			if handled != c.value {
+				t.Errorf("handler got %v, want %v", handled, c.value)
+			}
+		})
+	}
+}
+
+func TestRecoverRepanic(t *testing.T) {
+	var b bytes.Buffer
+	logger := testLogger(t, &b)
+	defer func() {
+		if v := recover(); v != "again" || b.Len() == 0 {
+			t.Errorf("recovered %v, logged %q", v, b.String())
+		}
+	}()
+	defer Recover(&logger, RecoverOptions{Repanic: true})
+	panic("again")
+}
+
+func TestRecoverNoPanic(t *testing.T) {
+	var b bytes.Buffer
+	logger := testLogger(t, &b)
+	func() {
+		defer Recover(&logger, RecoverOptions{Handler: func(any) { t.Error("handler called without panic") }})
+	}()
+	if b.Len() != 0 {
+		t.Errorf("logged %s", b.Bytes())
+	}
+}
+
+func TestGo(t *testing.T) {
+	var b bytes.Buffer
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	logger := testLogger(t, &b)
+	logger.Writer = WriterFunc(func(e *Entry) (int, error) {
+		defer wg.Done()
+		mu.Lock()
+		defer mu.Unlock()
+		return b.Write(e.buf)
+	})
+
+	wg.Add(1)
+	Go(&logger, func() { panic("in goroutine") })
+	wg.Wait()
+	if !bytes.Contains(b.Bytes(), []byte(`"panic":"in goroutine"`)) {
+		t.Errorf("got %s", b.Bytes())
+	}
+}
@@ -0,0 +1,438 @@
+package log
+
+import (
+	"crypto/sha256"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// RedactMode defines how a redacted value is replaced.
+type RedactMode uint8
+
+const (
+	// RedactMask replaces values with "***".
+	RedactMask RedactMode = iota
+	// RedactHash replaces values with a stable hash, e.g. "sha256:8a1f0c2b3d4e5f60".
+	RedactHash
+	// RedactPartial masks all but the last 4 characters of values, e.g. "************4242".
+	RedactPartial
+)
+
+// Redactor replaces the values of matching fields as they are added to entries, before
+// hooks and writers see them. It covers the fields of an entry, including nested objects
+// of Dict, Object, Interface and Any, and the fields of Logger.Context and the entry context.
+type Redactor struct {
+	// Keys specifies field names redacted at any depth, matched case-insensitively.
+	Keys []string
+
+	// Paths specifies dotted paths of fields, each segment is a pattern of path.Match,
+	// e.g. "card.number" or "*.password". Elements of arrays share the path of the array.
+	Paths []string
+
+	// Values specifies patterns of string values to redact, e.g. `^Bearer `.
+	Values []*regexp.Regexp
+
+	// Mode specifies the replacement of redacted values.
+	Mode RedactMode
+
+	// Salt specifies an optional salt of hashes for RedactHash.
+	Salt string
+
+	once  sync.Once
+	paths [][]string
+	ctx   atomic.Pointer[redactedContext]
+}
+
+type redactedContext struct {
+	src, dst Context
+}
+
+// redact replaces the matching values of the top-level fields of the entry added from n.
+func (e *Entry) redact(r *Redactor, n int) {
+	r.init()
+
+	b := bbpool.Get().(*bb)
+	b.B = b.B[:0]
+	var stack [16]string
+	switch {
+	case e.cbor:
+		b.B, _ = r.cborMap(b.B, e.buf, n, -1, stack[:0])
+	case e.logfmt:
+		b.B = r.logfmt(b.B, e.buf[n:], stack[:0])
+	default:
+		// the fields have leading commas, see Context
+		b.B, _ = r.object(b.B, e.buf[n:], 0, stack[:0])
+		b.B[0] = ','
+	}
+	e.buf = append(e.buf[:n], b.B...)
+	if cap(b.B) <= bbcap {
+		bbpool.Put(b)
+	}
+}
+
+// redactValue replaces the value of the top-level field key at e.value if it matches. Scalars
+// only match by key, and strings by Values, so the others are kept without being copied.
+func (e *Entry) redactValue(r *Redactor, key string) {
+	r.init()
+
+	var stack [16]string
+	keys := append(stack[:0], key)
+	redacted := r.match(key, keys)
+	if !redacted {
+		switch c := e.buf[e.value]; {
+		case e.cbor && c&0xe0 == cborMap, e.cbor && c&0xe0 == cborArray, !e.cbor && (c == '{' || c == '['):
+		case e.cbor && (c&0xe0 == cborText || c&0xe0 == cborBytes), !e.cbor && c == '"':
+			if len(r.Values) == 0 {
+				return
+			}
+		default:
+			return
+		}
+	}
+
+	b := bbpool.Get().(*bb)
+	if e.cbor {
+		b.B, _ = r.cborValue(b.B[:0], e.buf, e.value, keys, redacted)
+	} else {
+		b.B, _ = r.value(b.B[:0], e.buf, e.value, keys, redacted)
+	}
+	e.buf = append(e.buf[:e.value], b.B...)
+	if cap(b.B) <= bbcap {
+		bbpool.Put(b)
+	}
+}
+
+// context returns the fields of ctx with the matching values replaced. The result of the
+// last ctx is kept, so the Context of a logger is only redacted once.
+func (r *Redactor) context(ctx Context) Context {
+	if len(ctx) == 0 {
+		return ctx
+	}
+	if c := r.ctx.Load(); c != nil && len(c.src) == len(ctx) && unsafe.SliceData(c.src) == unsafe.SliceData(ctx) {
+		return c.dst
+	}
+	r.init()
+	var stack [16]string
+	dst, _ := r.object(nil, ctx, 0, stack[:0])
+	dst[0] = ','
+	r.ctx.Store(&redactedContext{ctx, dst})
+	return dst
+}
+
+func (r *Redactor) init() {
+	r.once.Do(func() {
+		for _, p := range r.Paths {
+			r.paths = append(r.paths, strings.Split(p, "."))
+		}
+	})
+}
+
+func (r *Redactor) match(key string, keys []string) bool {
+	for _, k := range r.Keys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	for _, segs := range r.paths {
+		if len(segs) != len(keys) {
+			continue
+		}
+		matched := true
+		for i, seg := range segs {
+			if ok, _ := path.Match(seg, keys[i]); !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func skipSpaces(json []byte, i int) int {
+	for i < len(json) && json[i] <= ' ' {
+		i++
+	}
+	return i
+}
+
+// object copies the object members of json starting at i to dst, the opening brace is
+// already consumed. It returns the index after the closing brace, or len(json) if unterminated.
+func (r *Redactor) object(dst, json []byte, i int, keys []string) ([]byte, int) {
+	dst = append(dst, '{')
+	first := true
+	for i < len(json) {
+		switch json[i] {
+		case '}':
+			return append(dst, '}'), i + 1
+		case '"':
+		default:
+			i++
+			continue
+		}
+		j, key, esc, ok := jsonParseString(json, i+1)
+		if !ok {
+			return append(dst, json[i:]...), len(json)
+		}
+		if !first {
+			dst = append(dst, ',')
+		}
+		first = false
+		dst = append(dst, key...)
+		dst = append(dst, ':')
+
+		name := b2s(key[1 : len(key)-1])
+		if esc {
+			name = string(jsonUnescape(key[1:len(key)-1], nil))
+		}
+
+		i = skipSpaces(json, j)
+		if i < len(json) && json[i] == ':' {
+			i = skipSpaces(json, i+1)
+		}
+		dst, i = r.value(dst, json, i, append(keys, name), r.match(name, append(keys, name)))
+	}
+	return dst, i
+}
+
+// array copies the array elements of json starting at i to dst, the opening bracket is already consumed.
+func (r *Redactor) array(dst, json []byte, i int, keys []string) ([]byte, int) {
+	dst = append(dst, '[')
+	first := true
+	for i < len(json) {
+		switch json[i] {
+		case ']':
+			return append(dst, ']'), i + 1
+		case ',', ' ', '\t', '\r', '\n':
+			i++
+			continue
+		}
+		if !first {
+			dst = append(dst, ',')
+		}
+		first = false
+		dst, i = r.value(dst, json, i, keys, false)
+	}
+	return dst, i
+}
+
+// value copies the value of json at i to dst, replacing it if redacted.
+func (r *Redactor) value(dst, json []byte, i int, keys []string, redacted bool) ([]byte, int) {
+	if i >= len(json) {
+		return dst, i
+	}
+	if !redacted {
+		switch json[i] {
+		case '{':
+			return r.object(dst, json, i+1, keys)
+		case '[':
+			return r.array(dst, json, i+1, keys)
+		}
+	}
+
+	j, typ, val, ok := jsonParseAny(json, i, true)
+	if !ok {
+		return append(dst, json[i:]...), len(json)
+	}
+
+	var s string
+	switch typ {
+	case 's':
+		s = b2s(val[1 : len(val)-1])
+	case 'S':
+		s = string(jsonUnescape(val[1:len(val)-1], nil))
+	default:
+		s = b2s(val)
+	}
+	if !redacted && (typ == 's' || typ == 'S') {
+		for _, re := range r.Values {
+			if re.MatchString(s) {
+				redacted = true
+				break
+			}
+		}
+	}
+	if !redacted {
+		return append(dst, val...), j
+	}
+
+	dst = append(dst, '"')
+	dst = appendRedacted(dst, s, r.Mode, r.Salt)
+	dst = append(dst, '"')
+	return dst, j
+}
+
+// cborMap copies n members of the CBOR map of cbor starting at i to dst, n is negative
+// for indefinite maps. The head is already consumed. It returns the index after the map,
+// or len(cbor) if unterminated.
+func (r *Redactor) cborMap(dst, cbor []byte, i int, n int64, keys []string) ([]byte, int) {
+	for ; n != 0; n-- {
+		if i >= len(cbor) {
+			return dst, i
+		}
+		if n < 0 && cbor[i] == cborBreak {
+			return append(dst, cborBreak), i + 1
+		}
+		major, key, j, err := cborString(cbor, i)
+		if err != nil || major != cborText {
+			return append(dst, cbor[i:]...), len(cbor)
+		}
+		dst = append(dst, cbor[i:j]...)
+		name := b2s(key)
+		dst, i = r.cborValue(dst, cbor, j, append(keys, name), r.match(name, append(keys, name)))
+	}
+	return dst, i
+}
+
+// cborArray copies n elements of the CBOR array of cbor starting at i to dst, see cborMap.
+func (r *Redactor) cborArray(dst, cbor []byte, i int, n int64, keys []string) ([]byte, int) {
+	for ; n != 0; n-- {
+		if i >= len(cbor) {
+			return dst, i
+		}
+		if n < 0 && cbor[i] == cborBreak {
+			return append(dst, cborBreak), i + 1
+		}
+		dst, i = r.cborValue(dst, cbor, i, keys, false)
+	}
+	return dst, i
+}
+
+// cborValue copies the CBOR data item of cbor at i to dst, replacing it with text if redacted.
+func (r *Redactor) cborValue(dst, cbor []byte, i int, keys []string, redacted bool) ([]byte, int) {
+	major, info, arg, j, err := cborHead(cbor, i)
+	if err != nil {
+		return append(dst, cbor[i:]...), len(cbor)
+	}
+	n := int64(arg)
+	if info == cborIndefinite {
+		n = -1
+	}
+	if !redacted {
+		switch major {
+		case cborMap:
+			return r.cborMap(append(dst, cbor[i:j]...), cbor, j, n, keys)
+		case cborArray:
+			return r.cborArray(append(dst, cbor[i:j]...), cbor, j, n, keys)
+		}
+	}
+
+	end, err := cborSkip(cbor, i, 0)
+	if err != nil {
+		return append(dst, cbor[i:]...), len(cbor)
+	}
+	b := bbpool.Get().(*bb)
+	var s string
+	if _, str, _, err := cborString(cbor, i); err == nil {
+		s = b2s(str)
+	} else if redacted {
+		b.B, _, _ = cborToJSON(b.B[:0], cbor, i, 0)
+		s = string(b.B)
+	}
+	if !redacted && (major == cborText || major == cborBytes) {
+		for _, re := range r.Values {
+			if re.MatchString(s) {
+				redacted = true
+				break
+			}
+		}
+	}
+	if !redacted {
+		bbpool.Put(b)
+		return append(dst, cbor[i:end]...), end
+	}
+
+	b.B = appendRedacted(b.B[:0], s, r.Mode, r.Salt)
+	dst = cborAppendText(dst, string(jsonUnescape(b.B, nil)))
+	bbpool.Put(b)
+	return dst, end
+}
+
+// logfmt copies the logfmt pairs of src to dst, replacing the matching values. The dotted
+// keys of flattened objects match as paths, and arrays are redacted as JSON.
+func (r *Redactor) logfmt(dst, src []byte, keys []string) []byte {
+	for i := 0; i < len(src); {
+		key, val, j := logfmtPair(src, i)
+		if val == nil {
+			dst = append(dst, src[i:j]...)
+			i = j
+			continue
+		}
+		dst = append(dst, src[i:j-len(val)]...)
+		i = j
+
+		keys = append(keys[:0], strings.Split(b2s(key), ".")...)
+		redacted := false
+		for n := 1; n <= len(keys) && !redacted; n++ {
+			redacted = r.match(keys[n-1], keys[:n])
+		}
+		s := logfmtValue(val)
+		if !redacted && len(s) != 0 && s[0] == '[' {
+			if n, typ, arr, ok := jsonParseAny([]byte(s), 0, true); ok && typ == 'o' && n == len(s) {
+				b := bbpool.Get().(*bb)
+				b.B, _ = r.value(b.B[:0], arr, 0, keys, false)
+				dst = logfmtAppendString(dst, b2s(b.B))
+				bbpool.Put(b)
+				continue
+			}
+		}
+		if !redacted {
+			for _, re := range r.Values {
+				if re.MatchString(s) {
+					redacted = true
+					break
+				}
+			}
+		}
+		if !redacted {
+			dst = append(dst, val...)
+			continue
+		}
+		b := bbpool.Get().(*bb)
+		b.B = appendRedacted(b.B[:0], s, r.Mode, r.Salt)
+		dst = logfmtAppendString(dst, string(jsonUnescape(b.B, nil)))
+		bbpool.Put(b)
+	}
+	return dst
+}
+
+// appendRedacted appends the JSON escaped replacement of s to dst.
+func appendRedacted(dst []byte, s string, mode RedactMode, salt string) []byte {
+	switch mode {
+	case RedactHash:
+		h := sha256.New()
+		h.Write([]byte(salt))
+		h.Write([]byte(s))
+		var sum [sha256.Size]byte
+		dst = append(dst, "sha256:"...)
+		for _, c := range h.Sum(sum[:0])[:8] {
+			dst = append(dst, hex[c>>4], hex[c&0x0f])
+		}
+	case RedactPartial:
+		n := utf8.RuneCountInString(s)
+		if n <= 4 {
+			return append(dst, strings.Repeat("*", n)...)
+		}
+		i := len(s)
+		for k := 0; k < 4; k++ {
+			_, size := utf8.DecodeLastRuneInString(s[:i])
+			i -= size
+		}
+		for k := 0; k < n-4; k++ {
+			dst = append(dst, '*')
+		}
+		e := Entry{buf: dst}
+		e.string(s[i:])
+		dst = e.buf
+	default:
+		dst = append(dst, "***"...)
+	}
+	return dst
+}
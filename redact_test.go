@@ -0,0 +1,220 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type redactCard struct {
+	Number string `json:"number"`
+	Holder string `json:"holder"`
+}
+
+type redactUser struct{}
+
+func (redactUser) MarshalObject(e *Entry) {
+	e.Str("name", "alice").Str("password", "secret")
+}
+
+func TestRedactor(t *testing.T) {
+	redactor := &Redactor{
+		Keys:   []string{"password", "Token"},
+		Paths:  []string{"card.number", "*.pin"},
+		Values: []*regexp.Regexp{regexp.MustCompile(`^Bearer `)},
+	}
+
+	cases := []struct {
+		name  string
+		entry func(Logger) *Entry
+		want  string
+	}{
+		{"key", func(l Logger) *Entry { return l.Info().Str("user", "alice").Str("password", "secret").Int("token", 42) },
+			`{"time":"2019-07-10T05:35:54.277Z","level":"info","user":"alice","password":"***","token":"***","message":"hi"}`},
+		{"value", func(l Logger) *Entry { return l.Info().Str("auth", "Bearer abc").Str("other", "a Bearer b") },
+			`{"time":"2019-07-10T05:35:54.277Z","level":"info","auth":"***","other":"a Bearer b","message":"hi"}`},
+		{"path", func(l Logger) *Entry {
+			return l.Info().Any("card", redactCard{"4242424242424242", "alice"}).Str("number", "1")
+		}, `{"time":"2019-07-10T05:35:54.277Z","level":"info","card":{"number":"***","holder":"alice"},"number":"1","message":"hi"}`},
+		{"glob", func(l Logger) *Entry {
+			return l.Info().Dict("account", NewContext(nil).Int("pin", 1234).Str("password", "x").Value()).Int("pin", 1)
+		}, `{"time":"2019-07-10T05:35:54.277Z","level":"info","account":{"pin":"***","password":"***"},"pin":1,"message":"hi"}`},
+		{"object", func(l Logger) *Entry { return l.Info().Object("user", redactUser{}) },
+			`{"time":"2019-07-10T05:35:54.277Z","level":"info","user":{"name":"alice","password":"***"},"message":"hi"}`},
+		{"builder", func(l Logger) *Entry {
+			return l.Info().BeginObject("card").Str("number", "4242").BeginArray("tags").AppendStr("Bearer x").EndArray().EndObject()
+		}, `{"time":"2019-07-10T05:35:54.277Z","level":"info","card":{"number":"***","tags":["***"]},"message":"hi"}`},
+		{"fields", func(l Logger) *Entry { return l.Info().Fields(Fields{"password": []int{1, 2}}) },
+			`{"time":"2019-07-10T05:35:54.277Z","level":"info","password":"***","message":"hi"}`},
+		{"context", func(l Logger) *Entry {
+			l.Context = NewContext(nil).Str("token", "t").Str("service", "api").Value()
+			return l.Info()
+		}, `{"time":"2019-07-10T05:35:54.277Z","level":"info","token":"***","service":"api","message":"hi"}`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			logger.Redactor = redactor
+			c.entry(logger).Msg("hi")
+			if got := b.String(); got != c.want+"\n" {
+				t.Errorf("got %s\nwant %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRedactorModes(t *testing.T) {
+	cases := []struct {
+		mode RedactMode
+		salt string
+		want string
+	}{
+		{RedactMask, "", `"card":"***"`},
+		{RedactPartial, "", `"card":"************4242"`},
+		{RedactHash, "", `"card":"sha256:`},
+		{RedactHash, "salt", `"card":"sha256:`},
+	}
+
+	var hashes []string
+	for _, c := range cases {
+		var b bytes.Buffer
+		logger := testLogger(t, &b)
+		logger.Redactor = &Redactor{Keys: []string{"card"}, Mode: c.mode, Salt: c.salt}
+		logger.Info().Str("card", "4242424242424242").Msg("")
+		logger.Info().Str("card", "4242424242424242").Msg("")
+		lines := bytes.Split(bytes.TrimSpace(b.Bytes()), []byte("\n"))
+		if !bytes.Contains(lines[0], []byte(c.want)) || !bytes.Equal(lines[0], lines[1]) {
+			t.Errorf("mode %v: got %s, want %s", c.mode, b.Bytes(), c.want)
+		}
+		if c.mode == RedactHash {
+			hashes = append(hashes, string(lines[0]))
+		}
+	}
+	if len(hashes) != 2 || hashes[0] == hashes[1] {
+		t.Errorf("salted hashes are equal: %v", hashes)
+	}
+}
+
+func TestRedactorEncodings(t *testing.T) {
+	cases := []struct {
+		encoding Encoding
+		want     string
+	}{
+		{EncodingCBOR, `{"time":"2019-07-10T05:35:54.277Z","level":"info","password":"***","card":{"number":"***","holder":"alice"},"message":"hi"}`},
+		{EncodingLogfmt, `time=2019-07-10T05:35:54.277Z level=info password=*** card.number=*** card.holder=alice message=hi`},
+	}
+
+	for _, c := range cases {
+		var b bytes.Buffer
+		logger := testLogger(t, &b)
+		logger.Encoding = c.encoding
+		logger.Redactor = &Redactor{Keys: []string{"password"}, Paths: []string{"card.number"}}
+		logger.Info().Str("password", "secret").BeginObject("card").Str("number", "4242").Str("holder", "alice").EndObject().Msg("hi")
+		got := b.Bytes()
+		if c.encoding == EncodingCBOR {
+			var err error
+			if got, err = CBORToJSON(nil, got); err != nil {
+				t.Fatalf("CBORToJSON: %v", err)
+			}
+		}
+		if string(got) != c.want+"\n" {
+			t.Errorf("encoding %v: got %s\nwant %s", c.encoding, got, c.want)
+		}
+	}
+}
+
+func TestRedactorOnce(t *testing.T) {
+	var b bytes.Buffer
+	logger := testLogger(t, &b)
+	logger.Redactor = &Redactor{Keys: []string{"password"}, Mode: RedactHash}
+	logger.Hooks = []Hook{HookFunc(func(e *Entry, msg string) bool {
+		if v, _ := e.Lookup("password"); v == "secret" {
+			t.Errorf("hook saw the unredacted password")
+		}
+		return true
+	})}
+
+	logger.Info().Str("password", "secret").Object("user", redactUser{}).Msg("")
+	var m struct {
+		Password string
+		User     struct{ Password string }
+	}
+	if err := json.Unmarshal(b.Bytes(), &m); err != nil || m.Password == "secret" || m.Password != m.User.Password {
+		t.Errorf("hashes of nested and top-level values differ: %s", b.Bytes())
+	}
+}
+
+func TestRedactorFields(t *testing.T) {
+	redactor := &Redactor{Keys: []string{"password"}, Paths: []string{"req.token"}, Values: []*regexp.Regexp{regexp.MustCompile(`^Bearer `)}}
+	cases := []struct {
+		name   string
+		fields func(e *Entry) *Entry
+		want   string
+		logfmt string
+	}{
+		{"scalar", func(e *Entry) *Entry { return e.Int("password", 1).Int("n", 2) },
+			`"password":"***","n":2`, `password=*** n=2`},
+		{"array", func(e *Entry) *Entry {
+			return e.Strs("password", []string{"a"}).Strs("auth", []string{"Bearer x", "y"})
+		},
+			`"password":"***","auth":["***","y"]`, `password=*** auth="[\"***\",\"y\"]"`},
+		{"value", func(e *Entry) *Entry { return e.Str("auth", "Bearer x").Bytes("raw", []byte("Bearer y")) },
+			`"auth":"***","raw":"***"`, `auth=*** raw=***`},
+		{"dict", func(e *Entry) *Entry { return e.Dict("req", NewContext(nil).Str("token", "t").Int("id", 1).Value()) },
+			`"req":{"token":"***","id":1}`, `req.token=*** req.id=1`},
+		{"object", func(e *Entry) *Entry { return e.Object("user", redactUser{}).Str("name", "bob") },
+			`"user":{"name":"alice","password":"***"},"name":"bob"`, `user.name=alice user.password=*** name=bob`},
+		{"raw", func(e *Entry) *Entry { return e.RawJSON("req", []byte(`{"token":"t"}`)).Dur("password", time.Second) },
+			`"req":{"token":"***"},"password":"***"`, `req.token=*** password=***`},
+		{"marshaler", func(e *Entry) *Entry {
+			e.logger.ErrorMarshaler = RichErrorMarshaler
+			return e.AnErr("password", errors.New("x")).Int("n", 1)
+		}, `"password":"***","n":1`, `password=*** n=1`},
+		{"last", func(e *Entry) *Entry { return e.Int("n", 1).Str("password", "x") },
+			`"n":1,"password":"***"`, `n=1 password=***`},
+	}
+
+	for _, c := range cases {
+		for i, enc := range []Encoding{EncodingJSON, EncodingCBOR, EncodingLogfmt} {
+			t.Run(c.name+"-"+[]string{"json", "cbor", "logfmt"}[i], func(t *testing.T) {
+				var b bytes.Buffer
+				logger := testLogger(t, &b)
+				logger.Encoding = enc
+				logger.Redactor = redactor
+				c.fields(logger.Info()).Msg("hi")
+
+				got := b.Bytes()
+				want := `{"time":"2019-07-10T05:35:54.277Z","level":"info",` + c.want + `,"message":"hi"}` + "\n"
+				switch enc {
+				case EncodingCBOR:
+					var err error
+					if got, err = CBORToJSON(nil, got); err != nil {
+						t.Fatalf("CBORToJSON() error = %v", err)
+					}
+				case EncodingLogfmt:
+					want = `time=2019-07-10T05:35:54.277Z level=info ` + c.logfmt + ` message=hi` + "\n"
+				}
+				if string(got) != want {
+					t.Errorf("got  %s\nwant %s", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestRedactorCaller(t *testing.T) {
+	var b bytes.Buffer
+	logger := testLogger(t, &b)
+	logger.Redactor = &Redactor{Keys: []string{"password"}}
+	logger.Info().Str("password", "x").Caller(1).Str("user", "alice").Msg("hi")
+	// the raw fields of Caller follow the field, which is checked with them
+	if got := b.String(); !strings.Contains(got, `"password":"***","caller":"`) || !strings.Contains(got, `"user":"alice"`) {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,295 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sampler decides whether a log entry is emitted. Sample is called by the level
+// methods of Logger before the entry is built, so a rejected entry costs no encoding.
+// Fatal and panic entries are never sampled.
+type Sampler interface {
+	Sample(level Level) bool
+}
+
+// SampledOutCounter is implemented by samplers which count the entries they drop, e.g.
+// the built-in samplers. Custom levels are counted as their Rank.
+type SampledOutCounter interface {
+	SampledOut(level Level) uint64
+}
+
+// CallerSampler is implemented by samplers which decide on the call site of an entry.
+// SampleCaller is called instead of Sample with the program counter of the caller of the
+// level method, before the entry is built like Sample.
+type CallerSampler interface {
+	SampleCaller(level Level, pc uintptr) bool
+}
+
+// sampledOut counts the entries dropped by a sampler by the rank of their levels.
+type sampledOut [noLevel + 1]uint64
+
+func (c *sampledOut) add(level Level) {
+	if level = level.rank(); level > noLevel {
+		level = noLevel
+	}
+	atomic.AddUint64(&c[level], 1)
+}
+
+func (c *sampledOut) load(level Level) uint64 {
+	if level = level.rank(); level > noLevel {
+		level = noLevel
+	}
+	return atomic.LoadUint64(&c[level])
+}
+
+// sample reports whether an entry with level passes the Sampler of logger, the call site
+// of a CallerSampler is the caller of skip frames above the caller of sample.
+func (l *Logger) sample(level Level, skip int) bool {
+	if _, ok := l.Sampler.(CallerSampler); !ok || level.rank() >= FatalLevel {
+		return level.rank() >= FatalLevel || l.Sampler.Sample(level)
+	}
+	var pc uintptr
+	caller1(skip+1, &pc, 1, 1)
+	return l.sampleCaller(level, pc)
+}
+
+// sampleCaller reports whether an entry with level of the call site pc passes the Sampler
+// of logger.
+func (l *Logger) sampleCaller(level Level, pc uintptr) bool {
+	if level.rank() >= FatalLevel {
+		return true
+	}
+	if s, ok := l.Sampler.(CallerSampler); ok {
+		return s.SampleCaller(level, pc)
+	}
+	return l.Sampler.Sample(level)
+}
+
+// LevelSampler applies a Sampler by level, levels without a Sampler are always emitted.
+type LevelSampler map[Level]Sampler
+
+// Sample implements Sampler.
+func (s LevelSampler) Sample(level Level) bool {
+	if sampler := s[level]; sampler != nil {
+		return sampler.Sample(level)
+	}
+	return true
+}
+
+// SampleCaller implements CallerSampler.
+func (s LevelSampler) SampleCaller(level Level, pc uintptr) bool {
+	switch sampler := s[level].(type) {
+	case CallerSampler:
+		return sampler.SampleCaller(level, pc)
+	case Sampler:
+		return sampler.Sample(level)
+	}
+	return true
+}
+
+// SampledOut implements SampledOutCounter, it returns the count of the Sampler of level.
+func (s LevelSampler) SampledOut(level Level) uint64 {
+	if counter, ok := s[level].(SampledOutCounter); ok {
+		return counter.SampledOut(level)
+	}
+	return 0
+}
+
+const burstCounters = 4096
+
+type burstCounter struct {
+	resetAt int64
+	n       uint64
+}
+
+func (c *burstCounter) incr(now, period int64) uint64 {
+	resetAt := atomic.LoadInt64(&c.resetAt)
+	if now > resetAt && atomic.CompareAndSwapInt64(&c.resetAt, resetAt, now+period) {
+		atomic.StoreUint64(&c.n, 1)
+		return 1
+	}
+	return atomic.AddUint64(&c.n, 1)
+}
+
+// BurstSampler emits the first Burst entries of each level and call site per Period,
+// then every Every-th entry of them. Call sites are hashed into a fixed table of counters,
+// so it never allocates but rare collisions may share a counter. Entries sampled by Sample,
+// e.g. by a custom Sampler, share a counter per level.
+type BurstSampler struct {
+	// Burst is the number of entries with the same level and call site emitted per Period.
+	Burst uint32
+
+	// Every emits every Mth entry once Burst is exceeded, zero drops all of them.
+	Every uint32
+
+	// Period is the interval of counters reset, the default period is 1 second.
+	Period time.Duration
+
+	once     sync.Once
+	counters *[burstCounters]burstCounter
+	dropped  sampledOut
+}
+
+// Sample implements Sampler.
+func (s *BurstSampler) Sample(level Level) bool {
+	return s.SampleCaller(level, 0)
+}
+
+// SampleCaller implements CallerSampler.
+func (s *BurstSampler) SampleCaller(level Level, pc uintptr) bool {
+	s.once.Do(func() {
+		s.counters = new([burstCounters]burstCounter)
+	})
+
+	// fnv-1a
+	h := uint32(2166136261)
+	h = (h ^ uint32(level)) * 16777619
+	for i := 0; i < 64; i += 8 {
+		h = (h ^ uint32(uint64(pc)>>i&0xff)) * 16777619
+	}
+
+	period := s.Period
+	if period <= 0 {
+		period = time.Second
+	}
+
+	n := s.counters[h%burstCounters].incr(timeNow().UnixNano(), int64(period))
+	if n <= uint64(s.Burst) || s.Every != 0 && (n-uint64(s.Burst))%uint64(s.Every) == 0 {
+		return true
+	}
+	s.dropped.add(level)
+	return false
+}
+
+// SampledOut implements SampledOutCounter.
+func (s *BurstSampler) SampledOut(level Level) uint64 {
+	return s.dropped.load(level)
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   int64
+}
+
+// TokenBucketSampler limits each level to Rate entries per second with bursts of up to
+// Burst entries, using a token bucket per level.
+type TokenBucketSampler struct {
+	// Rate is the number of tokens refilled per second.
+	Rate float64
+
+	// Burst is the capacity of a bucket, it uses 1 if less than 1.
+	Burst float64
+
+	buckets sync.Map // key: Level, value: *tokenBucket
+	dropped sampledOut
+}
+
+// Sample implements Sampler.
+func (s *TokenBucketSampler) Sample(level Level) bool {
+	v, ok := s.buckets.Load(level)
+	if !ok {
+		v, _ = s.buckets.LoadOrStore(level, &tokenBucket{tokens: -1})
+	}
+	b := v.(*tokenBucket)
+
+	burst := s.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	now := timeNow().UnixNano()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens < 0 {
+		b.tokens = burst
+	} else {
+		b.tokens += float64(now-b.last) / float64(time.Second) * s.Rate
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		s.dropped.add(level)
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// SampledOut implements SampledOutCounter.
+func (s *TokenBucketSampler) SampledOut(level Level) uint64 {
+	return s.dropped.load(level)
+}
+
+// AdaptiveSampler emits the first Threshold entries of each Window, then one of every M
+// entries of the window. M is the number of entries of the previous window divided by
+// Threshold and at least 2, so sampling tightens as throughput rises while the emitted
+// rate stays below about 2*Threshold entries per Window.
+type AdaptiveSampler struct {
+	// Threshold is the number of entries per Window which are always emitted.
+	Threshold uint64
+
+	// Window is the interval of throughput measurement, the default window is 1 second.
+	Window time.Duration
+
+	windowEnd int64
+	count     uint64
+	every     uint64
+	dropped   sampledOut
+}
+
+// Sample implements Sampler.
+func (s *AdaptiveSampler) Sample(level Level) bool {
+	window := s.Window
+	if window <= 0 {
+		window = time.Second
+	}
+
+	var n uint64
+	now := timeNow().UnixNano()
+	if end := atomic.LoadInt64(&s.windowEnd); now > end && atomic.CompareAndSwapInt64(&s.windowEnd, end, now+int64(window)) {
+		prev := atomic.SwapUint64(&s.count, 1)
+		if now > end+int64(window) {
+			// the previous window had no entries
+			prev = 0
+		}
+		every := uint64(2)
+		if s.Threshold != 0 && prev/s.Threshold > every {
+			every = prev / s.Threshold
+		}
+		atomic.StoreUint64(&s.every, every)
+		n = 1
+	} else {
+		n = atomic.AddUint64(&s.count, 1)
+	}
+
+	if s.Threshold == 0 || n <= s.Threshold {
+		return true
+	}
+	if every := atomic.LoadUint64(&s.every); every != 0 && (n-s.Threshold)%every == 0 {
+		return true
+	}
+	s.dropped.add(level)
+	return false
+}
+
+// SampledOut implements SampledOutCounter.
+func (s *AdaptiveSampler) SampledOut(level Level) uint64 {
+	return s.dropped.load(level)
+}
+
+var _ Sampler = LevelSampler(nil)
+var _ CallerSampler = LevelSampler(nil)
+var _ Sampler = (*BurstSampler)(nil)
+var _ CallerSampler = (*BurstSampler)(nil)
+var _ Sampler = (*TokenBucketSampler)(nil)
+var _ Sampler = (*AdaptiveSampler)(nil)
+var _ SampledOutCounter = LevelSampler(nil)
+var _ SampledOutCounter = (*BurstSampler)(nil)
+var _ SampledOutCounter = (*TokenBucketSampler)(nil)
+var _ SampledOutCounter = (*AdaptiveSampler)(nil)
@@ -0,0 +1,144 @@
+package log
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSamplerRates(t *testing.T) {
+	start := time.Date(2019, 7, 10, 5, 35, 54, 0, time.UTC)
+	cases := []struct {
+		name    string
+		sampler Sampler
+		windows []int
+		emitted []int
+	}{
+		{"burst", &BurstSampler{Burst: 2, Every: 3}, []int{10, 10}, []int{4, 4}},
+		{"burst-drop", &BurstSampler{Burst: 5}, []int{20}, []int{5}},
+		{"token-bucket", &TokenBucketSampler{Rate: 1, Burst: 3}, []int{10, 10}, []int{3, 1}},
+		{"adaptive", &AdaptiveSampler{Threshold: 10}, []int{100, 100, 5}, []int{55, 19, 5}},
+		{"adaptive-off", &AdaptiveSampler{}, []int{100}, []int{100}},
+		{"level", LevelSampler{InfoLevel: &BurstSampler{Burst: 1}}, []int{10}, []int{1}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			now := start
+			SetTimeNow(func() time.Time { return now })
+			defer SetTimeNow(nil)
+
+			var total, dropped int
+			for i, n := range c.windows {
+				emitted := 0
+				for j := 0; j < n; j++ {
+					if c.sampler.Sample(InfoLevel) {
+						emitted++
+					}
+				}
+				if emitted != c.emitted[i] {
+					t.Errorf("window %d: emitted %d of %d entries, want %d", i, emitted, n, c.emitted[i])
+				}
+				total += n
+				dropped += n - emitted
+				now = now.Add(time.Second + time.Millisecond)
+			}
+
+			counter := c.sampler.(SampledOutCounter)
+			if got := counter.SampledOut(InfoLevel); got != uint64(dropped) {
+				t.Errorf("SampledOut(info) = %d, want %d", got, dropped)
+			}
+			if got := counter.SampledOut(WarnLevel); got != 0 {
+				t.Errorf("SampledOut(warn) = %d, want 0", got)
+			}
+		})
+	}
+}
+
+func TestLoggerSampler(t *testing.T) {
+	var n int
+	sampler := &BurstSampler{Burst: 2, Period: time.Hour}
+	logger := Logger{
+		Level:   InfoLevel,
+		Sampler: sampler,
+		Writer:  WriterFunc(func(e *Entry) (int, error) { n++; return 0, nil }),
+	}
+	for i := 0; i < 10; i++ {
+		logger.Info().Int("i", i).Msg("hot loop")
+		logger.Error().Msgf("failed %d", i)
+		logger.Fatal().Msg("never sampled")
+	}
+	if want := 2 + 2 + 10; n != want {
+		t.Errorf("wrote %d entries, want %d", n, want)
+	}
+	if got := sampler.SampledOut(InfoLevel); got != 8 {
+		t.Errorf("SampledOut(info) = %d, want 8", got)
+	}
+	if got := sampler.SampledOut(ErrorLevel); got != 8 {
+		t.Errorf("SampledOut(error) = %d, want 8", got)
+	}
+}
+
+func TestBurstSamplerCallers(t *testing.T) {
+	cases := []struct {
+		name    string
+		log     func(l *Logger, i int) *Entry
+		emitted int
+	}{
+		{"site", func(l *Logger, i int) *Entry { return l.Info() }, 2},
+		{"sites", func(l *Logger, i int) *Entry {
+			if i%2 == 0 {
+				return l.Info()
+			}
+			return l.Info()
+		}, 4},
+		{"levels", func(l *Logger, i int) *Entry { return l.WithLevel(Level(InfoLevel + Level(i%2))) }, 4},
+		{"ctx-sites", func(l *Logger, i int) *Entry {
+			if i%2 == 0 {
+				return l.InfoCtx(context.Background())
+			}
+			return l.InfoCtx(context.Background())
+		}, 4},
+		{"fatal", func(l *Logger, i int) *Entry { return l.WithLevel(FatalLevel) }, 10},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sampler := &BurstSampler{Burst: 2, Period: time.Hour}
+			logger := Logger{Level: InfoLevel, Sampler: sampler}
+
+			emitted := 0
+			for i := 0; i < 10; i++ {
+				// sampled out entries are rejected before they are built
+				if e := c.log(&logger, i); e != nil {
+					emitted++
+					e.Discard()
+				}
+			}
+			if emitted != c.emitted {
+				t.Errorf("emitted %d entries, want %d", emitted, c.emitted)
+			}
+		})
+	}
+}
+
+func TestSlogSampler(t *testing.T) {
+	var n int
+	sampler := &BurstSampler{Burst: 2, Period: time.Hour}
+	logger := Logger{
+		Level:   InfoLevel,
+		Sampler: sampler,
+		Writer:  WriterFunc(func(e *Entry) (int, error) { n++; return 0, nil }),
+	}
+	slogger := logger.Slog()
+	for i := 0; i < 10; i++ {
+		slogger.Info("hot loop", "i", i)
+		slogger.Warn("other site")
+	}
+	if want := 2 + 2; n != want {
+		t.Errorf("wrote %d entries, want %d", n, want)
+	}
+	if got := sampler.SampledOut(InfoLevel); got != 8 {
+		t.Errorf("SampledOut(info) = %d, want 8", got)
+	}
+}
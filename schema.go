@@ -0,0 +1,644 @@
+package log
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unsafe"
+)
+
+// LevelStyle specifies the value style of the level field, see Schema.
+type LevelStyle uint8
+
+const (
+	// LevelStyleLower writes levels as lower case names, e.g. "info".
+	LevelStyleLower LevelStyle = iota
+	// LevelStyleUpper writes levels as upper case names, e.g. "INFO".
+	LevelStyleUpper
+	// LevelStyleNumeric writes levels as their Level numbers, e.g. 3.
+	LevelStyleNumeric
+	// LevelStyleSyslog writes levels as syslog severity numbers, e.g. 6.
+	LevelStyleSyslog
+	// LevelStyleGCP writes levels as severity names of Google Cloud Logging by their
+	// syslog severity, e.g. "INFO" and "CRITICAL".
+	LevelStyleGCP
+)
+
+// gcpSeverities are the Google Cloud Logging severities of syslog severities.
+var gcpSeverities = [8]string{"EMERGENCY", "ALERT", "CRITICAL", "ERROR", "WARNING", "NOTICE", "INFO", "DEBUG"}
+
+// Schema specifies the key names and the level style of entries, see Logger.Schema.
+// Empty keys use the default names, e.g.
+//
+//	logger := log.Logger{
+//		Schema: &log.Schema{TimeKey: "@timestamp", LevelKey: "severity", MessageKey: "msg", LevelStyle: log.LevelStyleUpper},
+//	}
+//
+//	// Output: {"@timestamp":"2019-07-10T05:35:54.277Z","severity":"INFO","msg":"hello world"}
+//
+// Writers which parse entries back, such as ConsoleWriter, JournalWriter and DedupWriter,
+// use the Schema of the logger of the entry.
+type Schema struct {
+	// TimeKey specifies the key of time, it uses "time" if empty. TimeField of Logger takes precedence.
+	TimeKey string
+
+	// LevelKey specifies the key of level, it uses "level" if empty.
+	LevelKey string
+
+	// MessageKey specifies the key of message, it uses "message" if empty.
+	MessageKey string
+
+	// CallerKey specifies the key of caller, it uses "caller" if empty.
+	CallerKey string
+
+	// CallerLineKey specifies the key of caller line. If set, the caller is split into
+	// the file name of CallerKey and the line number of CallerLineKey.
+	CallerLineKey string
+
+	// CallerFuncKey specifies the key of caller function, it uses "callerfunc" if empty.
+	CallerFuncKey string
+
+	// SourceLocationKey specifies the key of the caller as an object of "file", "line" and
+	// "function". If set, it is used instead of CallerKey, CallerLineKey and CallerFuncKey.
+	SourceLocationKey string
+
+	// GoidKey specifies the key of goroutine id, it uses "goid" if empty.
+	GoidKey string
+
+	// ErrorKey specifies the key of Err, it uses "error" if empty.
+	ErrorKey string
+
+	// ErrorTypeKey specifies the key of the error type added by Err, no type is added if empty.
+	ErrorTypeKey string
+
+	// StackKey specifies the key of Stack, it uses "stack" if empty.
+	StackKey string
+
+	// TraceIDKey specifies the key of trace id, it uses "trace_id" if empty.
+	// TraceIDField of Logger takes precedence.
+	TraceIDKey string
+
+	// TraceIDPrefix specifies the prefix of trace ids, e.g. "projects/my-project/traces/".
+	TraceIDPrefix string
+
+	// SpanIDKey specifies the key of span id of W3CExtractor, it uses "span_id" if empty.
+	SpanIDKey string
+
+	// TraceSampledKey specifies the key of the sampled flag of W3CExtractor as a bool.
+	// If set, it is used instead of "trace_flags".
+	TraceSampledKey string
+
+	// ErrorReportType specifies the value of the "@type" key added to entries of error and
+	// higher levels which have StackKey, e.g. the ReportedErrorEvent type of Google Cloud.
+	ErrorReportType string
+
+	// LevelStyle specifies the value style of level.
+	LevelStyle LevelStyle
+
+	// Context specifies the fields added to all entries after the level, e.g. the version of the schema.
+	Context Context
+
+	// Labels specifies the key of an object which the fields of entries are nested under as
+	// they are added, except the keys of the schema, its Context and dotted keys. Labels are
+	// flat, objects are flattened to their members with keys joined by underscores and arrays
+	// are written as strings of their JSON. Fields are kept top-level if empty.
+	Labels string
+
+	ctx atomic.Pointer[labeledContext]
+}
+
+func (s *Schema) timeKey() string {
+	if s == nil || s.TimeKey == "" {
+		return "time"
+	}
+	return s.TimeKey
+}
+
+func (s *Schema) levelKey() string {
+	if s == nil || s.LevelKey == "" {
+		return "level"
+	}
+	return s.LevelKey
+}
+
+func (s *Schema) messageKey() string {
+	if s == nil || s.MessageKey == "" {
+		return "message"
+	}
+	return s.MessageKey
+}
+
+func (s *Schema) callerKey() string {
+	if s == nil || s.CallerKey == "" {
+		return "caller"
+	}
+	return s.CallerKey
+}
+
+func (s *Schema) callerFuncKey() string {
+	if s == nil || s.CallerFuncKey == "" {
+		return "callerfunc"
+	}
+	return s.CallerFuncKey
+}
+
+func (s *Schema) goidKey() string {
+	if s == nil || s.GoidKey == "" {
+		return "goid"
+	}
+	return s.GoidKey
+}
+
+func (s *Schema) errorKey() string {
+	if s == nil || s.ErrorKey == "" {
+		return "error"
+	}
+	return s.ErrorKey
+}
+
+func (s *Schema) traceIDKey() string {
+	if s == nil || s.TraceIDKey == "" {
+		return "trace_id"
+	}
+	return s.TraceIDKey
+}
+
+func (s *Schema) spanIDKey() string {
+	if s == nil || s.SpanIDKey == "" {
+		return "span_id"
+	}
+	return s.SpanIDKey
+}
+
+func (s *Schema) stackKey() string {
+	if s == nil || s.StackKey == "" {
+		return "stack"
+	}
+	return s.StackKey
+}
+
+// schema returns the Schema of the logger of the entry, or nil if none.
+func (e *Entry) schema() *Schema {
+	if e.logger == nil {
+		return nil
+	}
+	return e.logger.Schema
+}
+
+// timeField returns the key of time of l.
+func (l *Logger) timeField() string {
+	if l.TimeField != "" {
+		return l.TimeField
+	}
+	return l.Schema.timeKey()
+}
+
+// traceIDField returns the key of trace id of l.
+func (l *Logger) traceIDField() string {
+	if l.TraceIDField != "" {
+		return l.TraceIDField
+	}
+	return l.Schema.traceIDKey()
+}
+
+// appendLevel appends the level field of level, nothing for the absent level.
+func (s *Schema) appendLevel(dst []byte, level Level) []byte {
+	if level == noLevel {
+		return dst
+	}
+	dst = append(dst, ',', '"')
+	dst = append(dst, s.levelKey()...)
+	dst = append(dst, '"', ':')
+	var style LevelStyle
+	if s != nil {
+		style = s.LevelStyle
+	}
+	switch style {
+	case LevelStyleNumeric:
+		dst = strconv.AppendUint(dst, uint64(level), 10)
+	case LevelStyleSyslog:
+		dst = strconv.AppendInt(dst, int64(level.syslog()), 10)
+	case LevelStyleGCP:
+		dst = append(dst, '"')
+		dst = append(dst, gcpSeverities[level.syslog()]...)
+		dst = append(dst, '"')
+	case LevelStyleUpper:
+		dst = append(dst, '"')
+		n := len(dst)
+		dst = append(dst, level.String()...)
+		for i := n; i < len(dst); i++ {
+			if 'a' <= dst[i] && dst[i] <= 'z' {
+				dst[i] -= 'a' - 'A'
+			}
+		}
+		dst = append(dst, '"')
+	default:
+		dst = append(dst, '"')
+		dst = append(dst, level.String()...)
+		dst = append(dst, '"')
+	}
+	return dst
+}
+
+// ParseLevel converts a level value written with s into a Level. Syslog severities
+// without a builtin level are converted to the first custom level of the severity.
+func (s *Schema) ParseLevel(value string) Level {
+	if s == nil || s.LevelStyle == LevelStyleLower || s.LevelStyle == LevelStyleUpper {
+		return ParseLevel(value)
+	}
+	if s.LevelStyle == LevelStyleGCP {
+		for n, severity := range gcpSeverities {
+			if severity == value {
+				return severityLevel(uint64(n))
+			}
+		}
+		return ParseLevel(value)
+	}
+	n, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return ParseLevel(value)
+	}
+	if s.LevelStyle == LevelStyleNumeric {
+		if level := Level(n); level >= TraceLevel && level.String() != "????" {
+			return level
+		}
+		return noLevel
+	}
+	return severityLevel(n)
+}
+
+// severityLevel returns the level of syslog severity n.
+func severityLevel(n uint64) Level {
+	switch n {
+	case 7:
+		return DebugLevel
+	case 6:
+		return InfoLevel
+	case 4:
+		return WarnLevel
+	case 3:
+		return ErrorLevel
+	case 2:
+		return FatalLevel
+	case 1, 0:
+		return PanicLevel
+	}
+	if r := levels.Load(); r != nil {
+		for i, info := range r.infos {
+			if uint64(info.Severity) == n {
+				return noLevel + Level(i+1)
+			}
+		}
+	}
+	return InfoLevel
+}
+
+// pos returns the position of key in FormatterArgs, see formatterArgsPos.
+func (s *Schema) pos(key string) int {
+	if s == nil {
+		return formatterArgsPos(key)
+	}
+	switch key {
+	case s.timeKey():
+		return 1
+	case s.levelKey():
+		return 2
+	case s.callerKey():
+		return 3
+	case s.callerFuncKey():
+		return 4
+	case s.goidKey():
+		return 5
+	case s.stackKey():
+		return 6
+	case s.messageKey():
+		return 7
+	case "category":
+		return 8
+	}
+	return 0
+}
+
+// reserved reports whether key is kept top-level by the Labels of s.
+func (s *Schema) reserved(key string, l *Logger) bool {
+	if strings.IndexByte(key, '.') >= 0 || strings.HasPrefix(key, "@") {
+		return true
+	}
+	return s.reservedKey(key, l)
+}
+
+// reservedKey reports whether key is one of the keys of s kept top-level by the Labels of s.
+func (s *Schema) reservedKey(key string, l *Logger) bool {
+	switch key {
+	case s.Labels, s.timeKey(), s.levelKey(), s.messageKey(), s.callerKey(), s.CallerLineKey, s.callerFuncKey(),
+		s.SourceLocationKey, s.goidKey(), s.errorKey(), s.ErrorTypeKey, s.stackKey(), s.spanIDKey(),
+		s.TraceSampledKey, l.timeField(), l.traceIDField():
+		return true
+	}
+	return false
+}
+
+// labeledContext is a Context split by the Labels of a Schema, see context.
+type labeledContext struct {
+	src, top, labels Context
+}
+
+// context splits the fields of ctx into the ones kept top-level and the flattened ones of
+// the labels. The result of the last ctx is kept, so the Context of a logger is
+// only split once.
+func (s *Schema) context(ctx Context, l *Logger) *labeledContext {
+	if c := s.ctx.Load(); c != nil && len(c.src) == len(ctx) && unsafe.SliceData(c.src) == unsafe.SliceData(ctx) {
+		return c
+	}
+	c := &labeledContext{src: ctx}
+	for i := 0; i < len(ctx); i++ {
+		if ctx[i] != '"' {
+			continue
+		}
+		j, key, esc, ok := jsonParseString(ctx, i+1)
+		if !ok {
+			break
+		}
+		j = skipSpaces(ctx, j)
+		if j < len(ctx) && ctx[j] == ':' {
+			j++
+		}
+		k, _, _, ok := jsonParseAny(ctx, skipSpaces(ctx, j), true)
+		if !ok {
+			break
+		}
+		if s.reserved(logfmtJSONKey(key, esc), l) {
+			c.top = append(c.top, ',')
+			c.top = append(c.top, ctx[i:k]...)
+		} else {
+			c.labels, _ = appendLabel(c.labels, nil, key[1:len(key)-1], ctx, j)
+		}
+		i = k - 1
+	}
+	s.ctx.Store(c)
+	return c
+}
+
+// labelFields moves the top-level fields of the entry added from n which are not reserved
+// by s to the labels written under the Labels key of s by send.
+func (e *Entry) labelFields(s *Schema, n int) {
+	if e.logfmt {
+		e.logfmtLabel(s, n)
+		return
+	}
+
+	b := bbpool.Get().(*bb)
+	b.B = b.B[:0]
+	if e.cbor {
+		e.cborLabel(s, n, b)
+	} else {
+		json := e.buf
+		for i := n; i < len(json); i++ {
+			if json[i] != '"' {
+				continue
+			}
+			j, key, esc, ok := jsonParseString(json, i+1)
+			if !ok {
+				break
+			}
+			j = skipSpaces(json, j)
+			if j < len(json) && json[j] == ':' {
+				j++
+			}
+			k, _, _, ok := jsonParseAny(json, skipSpaces(json, j), true)
+			if !ok {
+				break
+			}
+			if s.reserved(logfmtJSONKey(key, esc), e.logger) {
+				b.B = append(b.B, ',')
+				b.B = append(b.B, json[i:k]...)
+			} else {
+				e.labels, _ = appendLabel(e.labels, nil, key[1:len(key)-1], json, j)
+			}
+			i = k - 1
+		}
+	}
+	e.buf = append(e.buf[:n], b.B...)
+	if cap(b.B) <= bbcap {
+		bbpool.Put(b)
+	}
+}
+
+// labelValue moves the top-level field key at n, whose value is at e.value, to the labels.
+func (e *Entry) labelValue(key []byte, n int) {
+	if !e.cbor {
+		e.labels, _ = appendLabel(e.labels, nil, key, e.buf, e.value)
+		e.buf = e.buf[:n]
+		return
+	}
+	json := bbpool.Get().(*bb)
+	var err error
+	if json.B, _, err = cborToJSON(json.B[:0], e.buf, e.value, 0); err == nil {
+		e.labels, _ = appendLabel(e.labels, nil, key, json.B, 0)
+	}
+	e.buf = e.buf[:n]
+	if cap(json.B) <= bbcap {
+		bbpool.Put(json)
+	}
+}
+
+// cborLabel is labelFields of CBOR entries, the fields kept top-level are appended to b.
+func (e *Entry) cborLabel(s *Schema, n int, b *bb) {
+	json := bbpool.Get().(*bb)
+	cbor := e.buf
+	for i := n; i < len(cbor); {
+		major, key, j, err := cborString(cbor, i)
+		if err != nil || major != cborText {
+			break
+		}
+		k, err := cborSkip(cbor, j, 0)
+		if err != nil {
+			break
+		}
+		if s.reserved(b2s(key), e.logger) {
+			b.B = append(b.B, cbor[i:k]...)
+		} else if json.B, _, err = cborToJSON(json.B[:0], cbor, j, 0); err == nil {
+			e.labels, _ = appendLabel(e.labels, nil, key, json.B, 0)
+		}
+		i = k
+	}
+	if cap(json.B) <= bbcap {
+		bbpool.Put(json)
+	}
+}
+
+// appendLabel appends the JSON value of json at i as the label fields of the raw JSON key
+// with prefix, e.g. `,"a":1`, and returns the index after the value. Labels are flat, the
+// members of objects are appended with their keys joined by underscores, e.g. `,"a_b":1`,
+// and arrays as strings of their JSON.
+func appendLabel(dst []byte, prefix []byte, key []byte, json []byte, i int) ([]byte, int) {
+	i = skipSpaces(json, i)
+	if i < len(json) && json[i] == '{' {
+		prefix = append(append(prefix, key...), '_')
+		for i++; i < len(json); {
+			switch json[i] {
+			case '}':
+				return dst, i + 1
+			case '"':
+			default:
+				i++
+				continue
+			}
+			j, k, _, ok := jsonParseString(json, i+1)
+			if !ok {
+				break
+			}
+			j = skipSpaces(json, j)
+			if j < len(json) && json[j] == ':' {
+				j++
+			}
+			dst, i = appendLabel(dst, prefix, k[1:len(k)-1], json, j)
+		}
+		return dst, len(json)
+	}
+
+	dst = append(dst, ',', '"')
+	dst = append(dst, prefix...)
+	dst = append(dst, key...)
+	dst = append(dst, '"', ':')
+	j, typ, val, ok := jsonParseAny(json, i, true)
+	if !ok {
+		return append(dst, "null"...), j
+	}
+	if typ != 'o' {
+		return append(dst, val...), j
+	}
+	dst = append(dst, '"')
+	for _, c := range val {
+		switch {
+		case c == '"' || c == '\\':
+			dst = append(dst, '\\', c)
+		case c < ' ':
+			dst = append(dst, ' ')
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return append(dst, '"'), j
+}
+
+// appendLabels adds the labels of the entry under the Labels key of s.
+func (e *Entry) appendLabels(s *Schema) {
+	if e.cbor {
+		e.buf = cborAppendText(e.buf, s.Labels)
+		e.buf = append(e.buf, cborMap|cborIndefinite)
+		e.buf = cborAppendFields(e.buf, e.labels)
+		e.buf = append(e.buf, cborBreak)
+		return
+	}
+	if e.logfmt {
+		var tmp [64]byte
+		e.buf = logfmtAppendFields(e.buf, append(logfmtAppendName(tmp[:0], s.Labels), '.'), e.labels)
+		return
+	}
+	e.buf = append(e.buf, ',', '"')
+	e.buf = append(e.buf, s.Labels...)
+	e.buf = append(e.buf, '"', ':', '{')
+	e.buf = append(e.buf, e.labels[1:]...)
+	e.buf = append(e.buf, '}')
+}
+
+// logfmtLabel is labelFields of logfmt entries. The pairs of the field which are not reserved
+// are added to the labels like the flattened fields of JSON entries, the dotted keys of the
+// object of keyObject are members of its key, the other dotted keys are reserved.
+func (e *Entry) logfmtLabel(s *Schema, n int) {
+	var top []byte
+	if e.value > n+1 {
+		top = e.buf[n+1 : e.value-1]
+	}
+	b := bbpool.Get().(*bb)
+	b.B = b.B[:0]
+	src := e.buf[n:]
+	for i := 0; i < len(src); {
+		key, val, j := logfmtPair(src, i)
+		name := key
+		if len(top) != 0 && bytes.HasPrefix(key, top) && (len(key) == len(top) || key[len(top)] == '.') {
+			name = top
+		}
+		if len(key) == 0 || val == nil || s.reserved(b2s(name), e.logger) {
+			b.B = append(b.B, src[i:j]...)
+		} else {
+			e.labels = append(e.labels, ',', '"')
+			k := len(e.labels) + len(name)
+			e.labels = append(e.labels, key...)
+			for ; k < len(e.labels); k++ {
+				if e.labels[k] == '.' {
+					e.labels[k] = '_'
+				}
+			}
+			e.labels = append(e.labels, '"', ':')
+			e.labels = logfmtAppendValueJSON(e.labels, val)
+		}
+		i = j
+	}
+	e.buf = append(e.buf[:n], b.B...)
+	if cap(b.B) <= bbcap {
+		bbpool.Put(b)
+	}
+}
+
+// traceIDStr adds the trace id field with the TraceIDPrefix of the schema.
+func (e *Entry) traceIDStr(key, id string) {
+	s := e.schema()
+	if s == nil || s.TraceIDPrefix == "" {
+		e.Str(key, id)
+		return
+	}
+	e.key(key)
+	if e.cbor {
+		e.buf = cborAppendText(e.buf, s.TraceIDPrefix+id)
+		return
+	}
+	if e.logfmt {
+		e.logfmtString(s.TraceIDPrefix + id)
+		return
+	}
+	e.buf = append(e.buf, '"')
+	e.string(s.TraceIDPrefix)
+	e.string(id)
+	e.buf = append(e.buf, '"')
+}
+
+// errorReport adds the "@type" key of ErrorReportType if the entry has a textual stack, i.e.
+// the text of runtime.Stack. Structured frames, see StackOptions, are not reported.
+func (e *Entry) errorReport(s *Schema) {
+	if level := e.Level.rank(); level < ErrorLevel || level == noLevel {
+		return
+	}
+	var tmp [64]byte
+	key := append(tmp[:0], ',', '"')
+	key = append(key, s.stackKey()...)
+	key = append(key, '"', ':')
+	if e.cbor {
+		key = cborAppendText(tmp[:0], s.stackKey())
+	} else if e.logfmt {
+		key = logfmtAppendKey(tmp[:0], nil, s.stackKey())
+	}
+	i := bytes.Index(e.buf, key)
+	if i < 0 || i+len(key) >= len(e.buf) {
+		return
+	}
+	value := e.buf[i+len(key):]
+	switch {
+	case e.cbor:
+		if value[0]&0xe0 != cborText {
+			return
+		}
+	case e.logfmt:
+		// frames are quoted JSON arrays
+		if value[0] == '[' || bytes.HasPrefix(value, []byte(`"[`)) {
+			return
+		}
+	default:
+		if value[0] != '"' {
+			return
+		}
+	}
+	e.Str("@type", s.ErrorReportType)
+}
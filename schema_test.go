@@ -0,0 +1,251 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSchemaKeys(t *testing.T) {
+	cases := []struct {
+		name   string
+		schema *Schema
+		want   string
+	}{
+		{
+			name:   "default",
+			schema: &Schema{},
+			want:   `{"time":"2019-07-10T05:35:54.277Z","level":"warn","error":"refused","message":"dial"}` + "\n",
+		},
+		{
+			name:   "keys",
+			schema: &Schema{TimeKey: "@timestamp", LevelKey: "severity", MessageKey: "msg", ErrorKey: "err"},
+			want:   `{"@timestamp":"2019-07-10T05:35:54.277Z","severity":"warn","err":"refused","msg":"dial"}` + "\n",
+		},
+		{
+			name:   "upper",
+			schema: &Schema{LevelStyle: LevelStyleUpper},
+			want:   `{"time":"2019-07-10T05:35:54.277Z","level":"WARN","error":"refused","message":"dial"}` + "\n",
+		},
+		{
+			name:   "numeric",
+			schema: &Schema{LevelStyle: LevelStyleNumeric},
+			want:   `{"time":"2019-07-10T05:35:54.277Z","level":4,"error":"refused","message":"dial"}` + "\n",
+		},
+		{
+			name:   "syslog",
+			schema: &Schema{LevelStyle: LevelStyleSyslog},
+			want:   `{"time":"2019-07-10T05:35:54.277Z","level":4,"error":"refused","message":"dial"}` + "\n",
+		},
+		{
+			name:   "gcp",
+			schema: &Schema{LevelKey: "severity", LevelStyle: LevelStyleGCP},
+			want:   `{"time":"2019-07-10T05:35:54.277Z","severity":"WARNING","error":"refused","message":"dial"}` + "\n",
+		},
+		{
+			name:   "context",
+			schema: &Schema{Context: NewContext(nil).Str("schema", "v1").Value()},
+			want:   `{"time":"2019-07-10T05:35:54.277Z","level":"warn","schema":"v1","error":"refused","message":"dial"}` + "\n",
+		},
+		{
+			name:   "error-type",
+			schema: &Schema{ErrorTypeKey: "error_type"},
+			want:   `{"time":"2019-07-10T05:35:54.277Z","level":"warn","error":"refused","error_type":"*errors.errorString","message":"dial"}` + "\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			logger.Schema = c.schema
+
+			logger.Warn().Err(errors.New("refused")).Msg("dial")
+
+			if got := b.String(); got != c.want {
+				t.Errorf("got %s, want %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSchemaParseLevel(t *testing.T) {
+	styles := []LevelStyle{LevelStyleLower, LevelStyleUpper, LevelStyleNumeric, LevelStyleSyslog, LevelStyleGCP}
+	cases := []struct {
+		name  string
+		level Level
+		want  Level
+	}{
+		{"trace", TraceLevel, TraceLevel},
+		{"debug", DebugLevel, DebugLevel},
+		{"info", InfoLevel, InfoLevel},
+		{"warn", WarnLevel, WarnLevel},
+		{"error", ErrorLevel, ErrorLevel},
+		{"fatal", FatalLevel, FatalLevel},
+		{"panic", PanicLevel, PanicLevel},
+		{"notice", testNoticeLevel, testNoticeLevel},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for _, style := range styles {
+				schema := &Schema{LevelStyle: style}
+				want := c.want
+				if c.level == TraceLevel && (style == LevelStyleSyslog || style == LevelStyleGCP) {
+					// trace shares the debug severity
+					want = DebugLevel
+				}
+				var args FormatterArgs
+				parseFormatterArgs(schema.appendLevel([]byte(`{"time":"x"`), c.level), &args, schema)
+				if got := ParseLevel(args.Level); got != want {
+					t.Errorf("style %d: parsed level %q = %s, want %s", style, args.Level, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSchemaFormatterArgs(t *testing.T) {
+	cases := []struct {
+		name   string
+		schema *Schema
+		json   string
+		want   FormatterArgs
+	}{
+		{
+			name:   "default",
+			schema: nil,
+			json:   `{"time":"t","level":"info","caller":"a.go:1","message":"hi","foo":"bar"}`,
+			want:   FormatterArgs{Time: "t", Level: "info", Caller: "a.go:1", Message: "hi"},
+		},
+		{
+			name:   "keys",
+			schema: &Schema{TimeKey: "ts", LevelKey: "severity", CallerKey: "src", MessageKey: "msg"},
+			json:   `{"ts":"t","severity":"info","src":"a.go:1","msg":"hi","foo":"bar"}`,
+			want:   FormatterArgs{Time: "t", Level: "info", Caller: "a.go:1", Message: "hi"},
+		},
+		{
+			name:   "gcp",
+			schema: &Schema{LevelKey: "severity", LevelStyle: LevelStyleGCP},
+			json:   `{"time":"t","severity":"ERROR","message":"hi","foo":"bar"}`,
+			want:   FormatterArgs{Time: "t", Level: "error", Message: "hi"},
+		},
+		{
+			name:   "syslog",
+			schema: &Schema{LevelStyle: LevelStyleSyslog},
+			json:   `{"time":"t","level":"4","message":"hi","foo":"bar"}`,
+			want:   FormatterArgs{Time: "t", Level: "warn", Message: "hi"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var args FormatterArgs
+			parseFormatterArgs([]byte(c.json), &args, c.schema)
+			if args.Time != c.want.Time || args.Level != c.want.Level || args.Caller != c.want.Caller || args.Message != c.want.Message {
+				t.Errorf("args = %+v, want %+v", args, c.want)
+			}
+			if got := args.Get("foo"); got != "bar" {
+				t.Errorf("Get(foo) = %q, want bar", got)
+			}
+		})
+	}
+}
+
+func TestSchemaLabels(t *testing.T) {
+	cases := []struct {
+		name     string
+		encoding Encoding
+		redactor *Redactor
+		want     string
+	}{
+		{
+			name: "json",
+			want: `{"time":"2019-07-10T05:35:54.277Z","level":"info","schema":"v1","service.name":"api","error":"refused","http.request.method":"GET","message":"hello","labels":{"app":"shop","user":"alice","ok":true,"n":42,"req_id":7,"req_peer_ip":"10.0.0.1","tags":"[\"a\",\"b\"]"}}` + "\n",
+		},
+		{
+			name:     "cbor",
+			encoding: EncodingCBOR,
+			want:     `{"time":"2019-07-10T05:35:54.277Z","level":"info","schema":"v1","service.name":"api","error":"refused","http.request.method":"GET","message":"hello","labels":{"app":"shop","user":"alice","ok":true,"n":42,"req_id":7,"req_peer_ip":"10.0.0.1","tags":"[\"a\",\"b\"]"}}` + "\n",
+		},
+		{
+			name:     "logfmt",
+			encoding: EncodingLogfmt,
+			want:     `time=2019-07-10T05:35:54.277Z level=info schema=v1 service.name=api error=refused http.request.method=GET message=hello labels.app=shop labels.user=alice labels.ok=true labels.n=42 labels.req_id=7 labels.req_peer_ip=10.0.0.1 labels.tags="[\"a\",\"b\"]"` + "\n",
+		},
+		{
+			name:     "redacted",
+			redactor: &Redactor{Keys: []string{"user", "app"}},
+			want:     `{"time":"2019-07-10T05:35:54.277Z","level":"info","schema":"v1","service.name":"api","error":"refused","http.request.method":"GET","message":"hello","labels":{"app":"***","user":"***","ok":true,"n":42,"req_id":7,"req_peer_ip":"10.0.0.1","tags":"[\"a\",\"b\"]"}}` + "\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			logger.Encoding = c.encoding
+			logger.Redactor = c.redactor
+			logger.Schema = &Schema{Labels: "labels", Context: NewContext(nil).Str("schema", "v1").Value()}
+			logger.Context = NewContext(nil).Str("service.name", "api").Str("app", "shop").Value()
+
+			logger.Info().
+				Str("user", "alice").
+				Bool("ok", true).
+				Int("n", 42).
+				Err(errors.New("refused")).
+				Dict("req", NewContext(nil).Int("id", 7).Dict("peer", NewContext(nil).Str("ip", "10.0.0.1").Value()).Value()).
+				Strs("tags", []string{"a", "b"}).
+				Str("http.request.method", "GET").
+				Msg("hello")
+
+			got := b.Bytes()
+			if c.encoding == EncodingCBOR {
+				var err error
+				if got, err = CBORToJSON(nil, got); err != nil {
+					t.Fatalf("CBORToJSON() error = %v", err)
+				}
+			}
+			if string(got) != c.want {
+				t.Errorf("got  %s\nwant %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSchemaLabelsBuilder(t *testing.T) {
+	cases := []struct {
+		name     string
+		encoding Encoding
+		want     string
+	}{
+		{
+			name: "json",
+			want: `{"@timestamp":"2019-07-10T05:35:54.277Z","log.level":"info","ecs.version":"8.11.0","log.logger":"main","message":"hello","labels":{"user_name":"alice","user_roles":"[\"admin\"]","raw_a_b":1}}` + "\n",
+		},
+		{
+			name:     "logfmt",
+			encoding: EncodingLogfmt,
+			want:     `@timestamp=2019-07-10T05:35:54.277Z log.level=info ecs.version=8.11.0 log.logger=main message=hello labels.user_name=alice labels.user_roles="[\"admin\"]" labels.raw_a_b=1` + "\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			logger.Encoding = c.encoding
+			logger.Schema = ECSSchema(true)
+
+			logger.Info().
+				BeginObject("user").Str("name", "alice").BeginArray("roles").AppendStr("admin").EndArray().EndObject().
+				Str("log.logger", "main").
+				RawJSON("raw", []byte(`{"a":{"b":1}}`)).
+				Msg("hello")
+
+			if got := b.String(); got != c.want {
+				t.Errorf("got  %s\nwant %s", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Flusher is implemented by writers which buffer entries, e.g. AsyncWriter, FileWriter
+// and HTTPWriter. Flush returns after the buffered entries are written.
+type Flusher interface {
+	Flush() error
+}
+
+// The FlusherFunc type is an adapter to allow the use of
+// ordinary functions as flushers. If f is a function
+// with the appropriate signature, FlusherFunc(f) is a
+// [Flusher] that calls f.
+type FlusherFunc func() error
+
+// Flush calls f().
+func (f FlusherFunc) Flush() error {
+	return f()
+}
+
+// pending counts the requests of a writer in flight, e.g. of HTTPWriter.
+type pending struct {
+	mu       sync.Mutex
+	cond     sync.Cond
+	n        int
+	draining int
+}
+
+// add counts a new request, it reports false while wait drains the requests in flight,
+// so the request must be sent synchronously.
+func (p *pending) add() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.draining != 0 {
+		return false
+	}
+	p.n++
+	return true
+}
+
+// tryAdd is like add, but it reports full without counting the request if limit requests
+// are in flight.
+func (p *pending) tryAdd(limit int) (ok, full bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.draining != 0 {
+		return false, false
+	}
+	if p.n >= limit {
+		return false, true
+	}
+	p.n++
+	return true, false
+}
+
+// done uncounts a request added by add.
+func (p *pending) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.n--; p.n == 0 && p.cond.L != nil {
+		p.cond.Broadcast()
+	}
+}
+
+// wait waits for the requests in flight, new requests are rejected by add meanwhile.
+func (p *pending) wait() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cond.L == nil {
+		p.cond.L = &p.mu
+	}
+	p.draining++
+	for p.n != 0 {
+		p.cond.Wait()
+	}
+	p.draining--
+}
+
+var flushers struct {
+	mu   sync.Mutex
+	list []Flusher
+}
+
+// ExitFunc is called with the exit code by entries of fatal level after the registered
+// flushers are drained. It uses os.Exit by default, tests may replace it.
+var ExitFunc = os.Exit
+
+// FatalFlushTimeout is the deadline of draining the registered flushers before exit.
+var FatalFlushTimeout = 5 * time.Second
+
+// RegisterFlusher registers f to be flushed by Shutdown and before entries of fatal level exit.
+func RegisterFlusher(f Flusher) {
+	flushers.mu.Lock()
+	flushers.list = append(flushers.list, f)
+	flushers.mu.Unlock()
+}
+
+// Shutdown flushes the registered flushers in order, then closes those which implement
+// io.Closer and unregisters all. It returns ctx.Err() if ctx is done before they finish.
+func Shutdown(ctx context.Context) error {
+	err := drain(ctx, nil, true)
+	flushers.mu.Lock()
+	flushers.list = nil
+	flushers.mu.Unlock()
+	return err
+}
+
+// drain flushes the registered flushers and w, and closes the registered flushers if close.
+func drain(ctx context.Context, w Writer, close bool) error {
+	flushers.mu.Lock()
+	list := append([]Flusher(nil), flushers.list...)
+	flushers.mu.Unlock()
+	if f, ok := w.(Flusher); ok {
+		list = append(list, f)
+	}
+	if len(list) == 0 {
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		var errs []error
+		for _, f := range list {
+			if err := f.Flush(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if close {
+			for _, f := range list {
+				if closer, ok := f.(io.Closer); ok {
+					if err := closer.Close(); err != nil {
+						errs = append(errs, err)
+					}
+				}
+			}
+		}
+		done <- errors.Join(errs...)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// exit drains the registered flushers and w within FatalFlushTimeout, then calls ExitFunc.
+func exit(w Writer) {
+	ctx, cancel := context.WithTimeout(context.Background(), FatalFlushTimeout)
+	_ = drain(ctx, w, false)
+	cancel()
+	ExitFunc(255)
+}
@@ -0,0 +1,162 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testFlusher struct {
+	name  string
+	calls *[]string
+	err   error
+}
+
+func (f *testFlusher) Flush() error {
+	*f.calls = append(*f.calls, "flush "+f.name)
+	return f.err
+}
+
+func (f *testFlusher) Close() error {
+	*f.calls = append(*f.calls, "close "+f.name)
+	return nil
+}
+
+func TestShutdown(t *testing.T) {
+	var calls []string
+	errFlush := errors.New("flush failed")
+	RegisterFlusher(&testFlusher{name: "a", calls: &calls})
+	RegisterFlusher(FlusherFunc(func() error { calls = append(calls, "flush func"); return nil }))
+	RegisterFlusher(&testFlusher{name: "b", calls: &calls, err: errFlush})
+
+	if err := Shutdown(context.Background()); !errors.Is(err, errFlush) {
+		t.Errorf("Shutdown() = %v, want %v", err, errFlush)
+	}
+	want := []string{"flush a", "flush func", "flush b", "close a", "close b"}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("calls = %v, want %v", calls, want)
+		}
+	}
+
+	calls = nil
+	if err := Shutdown(context.Background()); err != nil || len(calls) != 0 {
+		t.Errorf("flushers not unregistered: %v %v", err, calls)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	RegisterFlusher(FlusherFunc(func() error { <-block; return nil }))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown() = %v, want deadline exceeded", err)
+	}
+}
+
+func TestFatalExit(t *testing.T) {
+	var code int32 = -1
+	var flushed bool
+	exitFunc := ExitFunc
+	ExitFunc = func(c int) { atomic.StoreInt32(&code, int32(c)) }
+	defer func() { ExitFunc = exitFunc }()
+
+	writer := struct {
+		Writer
+		Flusher
+	}{IOWriter{io.Discard}, FlusherFunc(func() error { flushed = true; return nil })}
+	exit(writer)
+	if code != 255 || !flushed {
+		t.Errorf("exit code = %d, flushed = %v", code, flushed)
+	}
+}
+
+func TestPending(t *testing.T) {
+	var p pending
+	if !p.add() || !p.add() {
+		t.Fatal("add rejected without wait")
+	}
+
+	waited := make(chan struct{})
+	go func() {
+		p.wait()
+		close(waited)
+	}()
+	for {
+		p.mu.Lock()
+		draining := p.draining
+		p.mu.Unlock()
+		if draining != 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if p.add() {
+		t.Error("add accepted while draining")
+	}
+	p.done()
+	select {
+	case <-waited:
+		t.Fatal("wait returned with a request in flight")
+	case <-time.After(10 * time.Millisecond):
+	}
+	p.done()
+	<-waited
+	if !p.add() {
+		t.Error("add rejected after wait")
+	}
+}
+
+func TestHTTPWriterFlush(t *testing.T) {
+	var received atomic.Int32
+	release := make(chan struct{})
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+	}))
+	defer fast.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	slowWriter := HTTPWriter{URL: slow.URL}
+	_, _ = slowWriter.WriteEntry(&Entry{buf: []byte(`{"n":0}`)})
+
+	fastWriter := HTTPWriter{URL: fast.URL}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = fastWriter.WriteEntry(&Entry{buf: []byte(`{"n":1}`)})
+		}()
+	}
+	wg.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		_ = fastWriter.Flush()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Flush waited for the requests of another URL")
+	}
+	if n := received.Load(); n != 8 {
+		t.Errorf("received %d requests after Flush, want 8", n)
+	}
+}
@@ -107,7 +107,7 @@ func (h slogJSONHandler) WithGroup(name string) slog.Handler {
 
 func (h *slogJSONHandler) Handle(_ context.Context, r slog.Record) error {
 	e := epool.Get().(*Entry)
-	e.buf = e.buf[:0]
+	e.reset(nil)
 
 	e.buf = append(e.buf, '{')
 
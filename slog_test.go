@@ -0,0 +1,31 @@
+package log
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogJSONHandlerReset(t *testing.T) {
+	var b bytes.Buffer
+	logger := testLogger(t, &b)
+	logger.PII = &PIIScanner{Email: PIIMask}
+	logger.Redactor = &Redactor{Keys: []string{"user"}}
+
+	var out bytes.Buffer
+	handler := slog.New(SlogNewJSONHandler(&out, nil))
+	for i := 0; i < 8; i++ {
+		logger.Info().Str("user", "alice").BeginObject("open").Msgf("mail %s", "alice@example.com")
+		handler.Info("mail alice@example.com", "user", "alice")
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if !strings.Contains(line, `"msg":"mail alice@example.com","user":"alice"}`) {
+			t.Fatalf("slog handler used the state of a logger entry: %s", line)
+		}
+	}
+	if !strings.Contains(b.String(), `"user":"***","open":{},"message":"mail ***"}`) {
+		t.Errorf("logger entry not masked: %s", b.String())
+	}
+}
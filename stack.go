@@ -0,0 +1,235 @@
+package log
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// StackOptions specifies the structured stacks added by Entry.Stack and Entry.StackFrames,
+// see Logger.StackOptions.
+type StackOptions struct {
+	// Skip specifies the number of frames to skip above the caller.
+	Skip int
+
+	// Limit specifies the maximum number of frames, it is unlimited if zero.
+	Limit int
+
+	// HideStdlib determines if hides the frames of the standard library, including runtime.
+	HideStdlib bool
+
+	// Hide specifies the prefixes of function names to hide, e.g. "github.com/oarkflow/log.".
+	Hide []string
+
+	// All determines if adds the stacks of all goroutines as the "goroutines" key.
+	All bool
+}
+
+// DefaultStackOptions hides the frames of the standard library and this package.
+var DefaultStackOptions = StackOptions{
+	Limit:      64,
+	HideStdlib: true,
+	Hide:       []string{"github.com/oarkflow/log."},
+}
+
+// hidden reports whether the frames of function name are hidden by o.
+func (o *StackOptions) hidden(name string) bool {
+	if o == nil {
+		return false
+	}
+	if o.HideStdlib && isStdlib(name) {
+		return true
+	}
+	for _, prefix := range o.Hide {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isStdlib reports whether function name belongs to the standard library, whose
+// import paths have no dot in the first element.
+func isStdlib(name string) bool {
+	pkg := name
+	if i := strings.LastIndexByte(pkg, '/'); i >= 0 {
+		if j := strings.IndexByte(pkg[i:], '.'); j >= 0 {
+			pkg = pkg[:i+j]
+		}
+	} else if j := strings.IndexByte(pkg, '.'); j >= 0 {
+		pkg = pkg[:j]
+	}
+	if pkg == "main" {
+		return false
+	}
+	if i := strings.IndexByte(pkg, '/'); i >= 0 {
+		pkg = pkg[:i]
+	}
+	return strings.IndexByte(pkg, '.') < 0
+}
+
+// StackFrames adds the "stack" key with the frames of the caller as an array of
+// {"func","file","line"} objects, e.g.
+//
+//	log.Error().StackFrames(log.DefaultStackOptions).Msg("hello world")
+//
+//	// Output: {"time":"2019-07-10T05:35:54.277Z","level":"error","stack":[{"func":"main.main","file":"/app/main.go","line":42}],"message":"hello world"}
+func (e *Entry) StackFrames(opts StackOptions) *Entry {
+	if e == nil {
+		return nil
+	}
+
+	e.stackFrames(&opts)
+	return e
+}
+
+// stackFrames adds the frames of the caller of its caller.
+func (e *Entry) stackFrames(opts *StackOptions) {
+	pcs := make([]uintptr, 128)
+	pcs = pcs[:runtime.Callers(3+opts.Skip, pcs)]
+	n, enc := e.beginJSON()
+	e.key(e.schema().stackKey())
+	e.frames(pcs, opts)
+	if opts.All {
+		e.buf = append(e.buf, ",\"goroutines\":"...)
+		e.goroutines(stacks(true), opts)
+	}
+	e.endJSON(n, enc)
+}
+
+// frames adds pcs as an array of {"func","file","line"} objects.
+func (e *Entry) frames(pcs []uintptr, opts *StackOptions) {
+	e.buf = append(e.buf, '[')
+	frames := runtime.CallersFrames(pcs)
+	for n := 0; opts == nil || opts.Limit == 0 || n < opts.Limit; {
+		frame, more := frames.Next()
+		if frame.PC != 0 && !opts.hidden(frame.Function) {
+			e.frame(n, frame.Function, frame.File, frame.Line)
+			n++
+		}
+		if !more {
+			break
+		}
+	}
+	e.buf = append(e.buf, ']')
+}
+
+// frame adds the n-th frame of an array.
+func (e *Entry) frame(n int, function, file string, line int) {
+	if n != 0 {
+		e.buf = append(e.buf, ',')
+	}
+	e.buf = append(e.buf, "{\"func\":\""...)
+	e.string(function)
+	e.buf = append(e.buf, "\",\"file\":\""...)
+	e.string(file)
+	e.buf = append(e.buf, "\",\"line\":"...)
+	e.buf = strconv.AppendInt(e.buf, int64(line), 10)
+	e.buf = append(e.buf, '}')
+}
+
+// goroutines adds the text of runtime.Stack for all goroutines as an array of
+// {"id","state","stack"} objects.
+func (e *Entry) goroutines(trace []byte, opts *StackOptions) {
+	e.buf = append(e.buf, '[')
+	for g, block := range bytes.Split(bytes.TrimSpace(trace), []byte("\n\n")) {
+		lines := strings.Split(b2s(block), "\n")
+		// goroutine 1 [running]:
+		header := strings.TrimSuffix(strings.TrimPrefix(lines[0], "goroutine "), ":")
+		id, state, _ := strings.Cut(header, " ")
+		if g != 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = append(e.buf, "{\"id\":"...)
+		if _, err := strconv.ParseUint(id, 10, 64); err == nil {
+			e.buf = append(e.buf, id...)
+		} else {
+			e.buf = append(e.buf, '0')
+		}
+		e.buf = append(e.buf, ",\"state\":\""...)
+		e.string(strings.Trim(state, "[]"))
+		e.buf = append(e.buf, "\",\"stack\":["...)
+		n := 0
+		for i := 1; i+1 < len(lines) && (opts.Limit == 0 || n < opts.Limit); i += 2 {
+			// main.main()
+			// 	/app/main.go:42 +0x1d
+			function := lines[i]
+			if strings.HasPrefix(function, "...") {
+				// ...additional frames elided...
+				i--
+				continue
+			}
+			if strings.HasPrefix(function, "created by ") {
+				function = strings.TrimPrefix(function, "created by ")
+				if j := strings.Index(function, " in goroutine "); j >= 0 {
+					function = function[:j]
+				}
+			} else if j := strings.LastIndexByte(function, '('); j > 0 {
+				function = function[:j]
+			}
+			if opts.hidden(function) {
+				continue
+			}
+			location := strings.TrimSpace(lines[i+1])
+			if j := strings.LastIndex(location, " +0x"); j >= 0 {
+				location = location[:j]
+			}
+			file, line := location, 0
+			if j := strings.LastIndexByte(location, ':'); j >= 0 {
+				file = location[:j]
+				line, _ = strconv.Atoi(location[j+1:])
+			}
+			e.frame(n, function, file, line)
+			n++
+		}
+		e.buf = append(e.buf, "]}"...)
+	}
+	e.buf = append(e.buf, ']')
+}
+
+// jsonObjects parses the objects of a JSON array, string values are unescaped and other
+// values are kept as their JSON text.
+func jsonObjects(json []byte) (objects []map[string]string) {
+	i := bytes.IndexByte(json, '[')
+	if i < 0 {
+		return
+	}
+	var key, val []byte
+	var typ byte
+	var ok bool
+	for i++; i < len(json); i++ {
+		switch json[i] {
+		case ']':
+			return
+		case '{':
+		default:
+			continue
+		}
+		object := make(map[string]string)
+		for i++; i < len(json) && json[i] != '}'; i++ {
+			if json[i] != '"' {
+				continue
+			}
+			if i, key, _, ok = jsonParseString(json, i+1); !ok {
+				return
+			}
+			for i < len(json) && (json[i] <= ' ' || json[i] == ':') {
+				i++
+			}
+			if i, typ, val, ok = jsonParseAny(json, i, true); !ok {
+				return
+			}
+			switch typ {
+			case 's':
+				val = val[1 : len(val)-1]
+			case 'S':
+				val = jsonUnescape(val[1:len(val)-1], nil)
+			}
+			object[string(key[1:len(key)-1])] = string(val)
+			i--
+		}
+		objects = append(objects, object)
+	}
+	return
+}
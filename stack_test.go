@@ -0,0 +1,136 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIsStdlib(t *testing.T) {
+	cases := []struct {
+		name string
+		want bool
+	}{
+		{"runtime.goexit", true},
+		{"net/http.(*conn).serve", true},
+		{"testing.tRunner", true},
+		{"main.main", false},
+		{"github.com/oarkflow/log.(*Entry).Msg", false},
+		{"example.com/app.handler.func1", false},
+		{"internal/poll.(*FD).Read", true},
+	}
+	for _, c := range cases {
+		if got := isStdlib(c.name); got != c.want {
+			t.Errorf("isStdlib(%q) = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestStackOptionsHidden(t *testing.T) {
+	cases := []struct {
+		opts *StackOptions
+		name string
+		want bool
+	}{
+		{nil, "runtime.goexit", false},
+		{&DefaultStackOptions, "runtime.goexit", true},
+		{&DefaultStackOptions, "github.com/oarkflow/log.(*Entry).Msg", true},
+		{&DefaultStackOptions, "github.com/oarkflow/logx.Do", false},
+		{&DefaultStackOptions, "main.main", false},
+		{&StackOptions{Hide: []string{"main."}}, "main.main", true},
+	}
+	for _, c := range cases {
+		if got := c.opts.hidden(c.name); got != c.want {
+			t.Errorf("hidden(%q) = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+type stackFrame struct {
+	Func string
+	File string
+	Line int
+}
+
+func TestStackFrames(t *testing.T) {
+	cases := []struct {
+		name  string
+		opts  StackOptions
+		first string
+		max   int
+	}{
+		{"all", StackOptions{}, "github.com/oarkflow/log.TestStackFrames.func1", 0},
+		{"limit", StackOptions{Limit: 1}, "github.com/oarkflow/log.TestStackFrames.func1", 1},
+		{"hide", StackOptions{HideStdlib: true, Hide: []string{"github.com/oarkflow/log.TestStackFrames"}}, "", 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			logger.Error().StackFrames(c.opts).Msg("")
+
+			var entry struct{ Stack []stackFrame }
+			if err := json.Unmarshal(b.Bytes(), &entry); err != nil {
+				t.Fatalf("invalid JSON %s: %v", b.Bytes(), err)
+			}
+			if c.first == "" {
+				if len(entry.Stack) != 0 {
+					t.Errorf("frames not hidden: %+v", entry.Stack)
+				}
+				return
+			}
+			if len(entry.Stack) == 0 || entry.Stack[0].Func != c.first || !strings.HasSuffix(entry.Stack[0].File, "stack_test.go") || entry.Stack[0].Line == 0 {
+				t.Errorf("first frame = %+v, want %s", entry.Stack, c.first)
+			}
+			if c.max != 0 && len(entry.Stack) > c.max {
+				t.Errorf("got %d frames, want at most %d", len(entry.Stack), c.max)
+			}
+		})
+	}
+}
+
+func TestStackWithOptions(t *testing.T) {
+	var b bytes.Buffer
+	logger := testLogger(t, &b)
+	logger.StackOptions = &StackOptions{Limit: 2}
+	logger.Error().Stack().Msg("")
+
+	var entry struct{ Stack []stackFrame }
+	if err := json.Unmarshal(b.Bytes(), &entry); err != nil || len(entry.Stack) != 2 || entry.Stack[0].Func != "github.com/oarkflow/log.TestStackWithOptions" {
+		t.Errorf("got %s", b.Bytes())
+	}
+}
+
+func TestGoroutines(t *testing.T) {
+	trace := `goroutine 1 [running]:
+main.main()
+	/app/main.go:42 +0x1d
+
+goroutine 7 [chan receive, 2 minutes]:
+runtime.gopark(0x0?)
+	/usr/local/go/src/runtime/proc.go:424 +0xce
+example.com/app.worker(0xc000010000)
+	/app/worker.go:10 +0x25
+...additional frames elided...
+created by example.com/app.start in goroutine 1
+	/app/start.go:5 +0x3f
+`
+	var e Entry
+	e.goroutines([]byte(trace), &StackOptions{HideStdlib: true})
+
+	var got []struct {
+		ID    int
+		State string
+		Stack []stackFrame
+	}
+	if err := json.Unmarshal(e.buf, &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", e.buf, err)
+	}
+	want := `[{1 running [{main.main /app/main.go 42}]} {7 chan receive, 2 minutes [{example.com/app.worker /app/worker.go 10} {example.com/app.start /app/start.go 5}]}]`
+	if s := fmt.Sprint(got); s != want {
+		t.Errorf("got %s\nwant %s", s, want)
+	}
+}
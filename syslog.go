@@ -94,25 +94,7 @@ func (w *SyslogWriter) WriteEntry(e *Entry) (n int, err error) {
 	}
 
 	// convert level to syslog priority
-	var priority byte
-	switch e.Level {
-	case TraceLevel:
-		priority = '7' // LOG_DEBUG
-	case DebugLevel:
-		priority = '7' // LOG_DEBUG
-	case InfoLevel:
-		priority = '6' // LOG_INFO
-	case WarnLevel:
-		priority = '4' // LOG_WARNING
-	case ErrorLevel:
-		priority = '3' // LOG_ERR
-	case FatalLevel:
-		priority = '2' // LOG_CRIT
-	case PanicLevel:
-		priority = '1' // LOG_ALERT
-	default:
-		priority = '6' // LOG_INFO
-	}
+	priority := byte('0' + e.Level.syslog())
 
 	e1 := epool.Get().(*Entry)
 	defer func(entry *Entry) {
@@ -139,7 +121,7 @@ func (w *SyslogWriter) WriteEntry(e *Entry) (n int, err error) {
 	e1.buf = strconv.AppendInt(e1.buf, int64(pid), 10)
 	e1.buf = append(e1.buf, ']', ':', ' ')
 	e1.buf = append(e1.buf, w.Marker...)
-	e1.buf = append(e1.buf, e.buf...)
+	e1.buf = e.appendJSON(e1.buf)
 
 	w.mu.Lock()
 	defer w.mu.Unlock()
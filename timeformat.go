@@ -0,0 +1,117 @@
+package log
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// TimeFormatRFC3339Micro defines a time format of RFC3339 with fixed microseconds,
+// which is formatted by the fast path of the header.
+const TimeFormatRFC3339Micro = "2006-01-02T15:04:05.000000Z07:00"
+
+// TimeFormatRFC3339Nano defines a time format of RFC3339 with fixed nanoseconds,
+// which is formatted by the fast path of the header.
+const TimeFormatRFC3339Nano = "2006-01-02T15:04:05.000000000Z07:00"
+
+// TimeFormatUnixMicro defines a time format that makes time fields to be
+// serialized as Unix timestamp integers in microseconds.
+const TimeFormatUnixMicro = "\x04"
+
+// TimeFormatUnixNano defines a time format that makes time fields to be
+// serialized as Unix timestamp integers in nanoseconds.
+const TimeFormatUnixNano = "\x05"
+
+// timePrefix is a formatted `"2006-01-02T15:04:05` of a second.
+type timePrefix struct {
+	sec int64
+	b   [20]byte
+}
+
+// timePrefixes caches the last formatted second of UTC and of the local offset, so
+// entries in the same second only format the fractional part.
+var timePrefixes [2]atomic.Pointer[timePrefix]
+
+// appendTimePrefix appends the quoted date and clock of sec in offset.
+func appendTimePrefix(dst []byte, sec, offset int64) []byte {
+	cache := &timePrefixes[0]
+	if offset != 0 {
+		cache = &timePrefixes[1]
+	}
+	if p := cache.Load(); p != nil && p.sec == sec {
+		return append(dst, p.b[:]...)
+	}
+
+	p := &timePrefix{sec: sec}
+	tmp := &p.b
+	abs := sec + 9223372028715321600 + offset // unixToInternal + internalToAbsolute + timeOffset
+	year, month, day, _ := absDate(uint64(abs), true)
+	hour, minute, second := absClock(uint64(abs))
+	// year
+	a := year / 100 * 2
+	b := year % 100 * 2
+	tmp[0] = '"'
+	tmp[1] = smallsString[a]
+	tmp[2] = smallsString[a+1]
+	tmp[3] = smallsString[b]
+	tmp[4] = smallsString[b+1]
+	// month
+	month *= 2
+	tmp[5] = '-'
+	tmp[6] = smallsString[month]
+	tmp[7] = smallsString[month+1]
+	// day
+	day *= 2
+	tmp[8] = '-'
+	tmp[9] = smallsString[day]
+	tmp[10] = smallsString[day+1]
+	// hour
+	hour *= 2
+	tmp[11] = 'T'
+	tmp[12] = smallsString[hour]
+	tmp[13] = smallsString[hour+1]
+	// minute
+	minute *= 2
+	tmp[14] = ':'
+	tmp[15] = smallsString[minute]
+	tmp[16] = smallsString[minute+1]
+	// second
+	second *= 2
+	tmp[17] = ':'
+	tmp[18] = smallsString[second]
+	tmp[19] = smallsString[second+1]
+	cache.Store(p)
+
+	return append(dst, p.b[:]...)
+}
+
+// appendTimeRFC3339 appends the quoted RFC3339 time of sec and nsec in offset with
+// digits of fractional seconds, i.e. 3, 6 or 9.
+func appendTimeRFC3339(dst []byte, sec int64, nsec int32, offset int64, digits int) []byte {
+	dst = appendTimePrefix(dst, sec, offset)
+	// fractional seconds
+	var tmp [10]byte
+	tmp[0] = '.'
+	frac := int(nsec)
+	for i := digits; i < 9; i++ {
+		frac /= 10
+	}
+	for i := digits; i > 0; i-- {
+		tmp[i] = byte('0' + frac%10)
+		frac /= 10
+	}
+	dst = append(dst, tmp[:digits+1]...)
+	// time zone
+	if offset == 0 {
+		return append(dst, 'Z', '"')
+	}
+	dst = append(dst, timeZone...)
+	return append(dst, '"')
+}
+
+// appendTimeUnix appends sec and nsec as a Unix timestamp integer in microseconds or nanoseconds.
+func appendTimeUnix(dst []byte, sec int64, nsec int32, format string) []byte {
+	if format == TimeFormatUnixMicro {
+		return strconv.AppendInt(dst, sec*1000000+int64(nsec)/1000, 10)
+	}
+	return strconv.AppendInt(dst, sec*1000000000+int64(nsec), 10)
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTimeFormat(t *testing.T) {
+	at := time.Date(2019, 7, 10, 5, 35, 54, 123456789, time.UTC)
+	cases := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{"default", "", `{"time":"2019-07-10T05:35:54.123Z","level":"info","at":"2019-07-10T05:35:54.123Z","message":"hi"}` + "\n"},
+		{"rfc3339-micro", TimeFormatRFC3339Micro, `{"time":"2019-07-10T05:35:54.123456Z","level":"info","at":"2019-07-10T05:35:54.123456Z","message":"hi"}` + "\n"},
+		{"rfc3339-nano", TimeFormatRFC3339Nano, `{"time":"2019-07-10T05:35:54.123456789Z","level":"info","at":"2019-07-10T05:35:54.123456789Z","message":"hi"}` + "\n"},
+		{"unix", TimeFormatUnix, `{"time":1562736954,"level":"info","at":1562736954,"message":"hi"}` + "\n"},
+		{"unix-ms", TimeFormatUnixMs, `{"time":1562736954123,"level":"info","at":1562736954123,"message":"hi"}` + "\n"},
+		{"unix-micro", TimeFormatUnixMicro, `{"time":1562736954123456,"level":"info","at":1562736954123456,"message":"hi"}` + "\n"},
+		{"unix-nano", TimeFormatUnixNano, `{"time":1562736954123456789,"level":"info","at":1562736954123456789,"message":"hi"}` + "\n"},
+		{"layout", time.Kitchen, `{"time":"5:35AM","level":"info","at":"5:35AM","message":"hi"}` + "\n"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			SetTimeNow(func() time.Time { return at })
+			defer SetTimeNow(nil)
+
+			var b bytes.Buffer
+			logger := Logger{TimeFormat: c.format, TimeLocation: time.UTC, Writer: IOWriter{&b}}
+			e := logger.Info()
+			if c.format == "" {
+				e.Time("at", at)
+			} else {
+				e.TimeFormat("at", c.format, at)
+			}
+			e.Msg("hi")
+
+			if got := b.String(); got != c.want {
+				t.Errorf("got %s, want %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAppendTimeRFC3339(t *testing.T) {
+	cases := []struct {
+		name   string
+		t      time.Time
+		digits int
+		want   string
+	}{
+		{"millis", time.Date(2019, 7, 10, 5, 35, 54, 123456789, time.UTC), 3, `"2019-07-10T05:35:54.123Z"`},
+		{"micros", time.Date(2019, 7, 10, 5, 35, 54, 123456789, time.UTC), 6, `"2019-07-10T05:35:54.123456Z"`},
+		{"nanos", time.Date(2019, 7, 10, 5, 35, 54, 123456789, time.UTC), 9, `"2019-07-10T05:35:54.123456789Z"`},
+		{"zeros", time.Date(2019, 7, 10, 5, 35, 54, 1000, time.UTC), 9, `"2019-07-10T05:35:54.000001000Z"`},
+		{"same-second", time.Date(2019, 7, 10, 5, 35, 54, 0, time.UTC), 3, `"2019-07-10T05:35:54.000Z"`},
+		{"next-second", time.Date(2019, 7, 10, 5, 35, 55, 999999999, time.UTC), 3, `"2019-07-10T05:35:55.999Z"`},
+		{"next-year", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), 6, `"2020-01-01T00:00:00.000000Z"`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := appendTimeRFC3339(nil, c.t.Unix(), int32(c.t.Nanosecond()), 0, c.digits)
+			if string(got) != c.want {
+				t.Errorf("appendTimeRFC3339() = %s, want %s", got, c.want)
+			}
+		})
+	}
+}
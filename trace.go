@@ -0,0 +1,118 @@
+package log
+
+import (
+	"math/rand/v2"
+	"sync"
+
+	"github.com/oarkflow/xid"
+
+	"github.com/oarkflow/log/fqdn"
+)
+
+// TraceIDGenerator generates trace ids for entries which carry none in their context.
+type TraceIDGenerator interface {
+	NewTraceID() string
+}
+
+// The TraceIDGeneratorFunc type is an adapter to allow the use of
+// ordinary functions as trace id generators. If f is a function
+// with the appropriate signature, TraceIDGeneratorFunc(f) is a
+// [TraceIDGenerator] that calls f.
+type TraceIDGeneratorFunc func() string
+
+// NewTraceID calls f().
+func (f TraceIDGeneratorFunc) NewTraceID() string {
+	return f()
+}
+
+var (
+	// XIDGenerator generates xid trace ids.
+	XIDGenerator TraceIDGenerator = TraceIDGeneratorFunc(func() string {
+		return xid.New().String()
+	})
+
+	// UUIDv7Generator generates time ordered UUIDv7 trace ids, e.g. "01932c07-a8c4-7d4e-9f6b-3c1a5e0b2d8f".
+	UUIDv7Generator TraceIDGenerator = TraceIDGeneratorFunc(newUUIDv7)
+
+	// W3CGenerator generates W3C Trace Context trace ids, 16 random bytes as 32 lowercase hex digits.
+	W3CGenerator TraceIDGenerator = TraceIDGeneratorFunc(newW3CTraceID)
+)
+
+// DefaultTraceIDGenerator is used by loggers without a TraceIDGenerator.
+var DefaultTraceIDGenerator = XIDGenerator
+
+func newUUIDv7() string {
+	var b [16]byte
+	ms := uint64(timeNow().UnixMilli())
+	b[0], b[1], b[2] = byte(ms>>40), byte(ms>>32), byte(ms>>24)
+	b[3], b[4], b[5] = byte(ms>>16), byte(ms>>8), byte(ms)
+	r1, r2 := rand.Uint64(), rand.Uint64()
+	b[6], b[7] = 0x70|byte(r1>>8)&0x0f, byte(r1)
+	b[8] = 0x80 | byte(r2>>56)&0x3f
+	b[9], b[10], b[11] = byte(r2>>48), byte(r2>>40), byte(r2>>32)
+	b[12], b[13], b[14], b[15] = byte(r2>>24), byte(r2>>16), byte(r2>>8), byte(r2)
+
+	var dst [36]byte
+	j := 0
+	for i, c := range b {
+		if i == 4 || i == 6 || i == 8 || i == 10 {
+			dst[j] = '-'
+			j++
+		}
+		dst[j], dst[j+1] = hex[c>>4], hex[c&0x0f]
+		j += 2
+	}
+	return string(dst[:])
+}
+
+func newW3CTraceID() string {
+	var dst [32]byte
+	for {
+		r1, r2 := rand.Uint64(), rand.Uint64()
+		if r1 == 0 && r2 == 0 {
+			// all zeros is an invalid trace id
+			continue
+		}
+		for i := 0; i < 16; i++ {
+			dst[15-i], dst[31-i] = hex[r1&0x0f], hex[r2&0x0f]
+			r1, r2 = r1>>4, r2>>4
+		}
+		return string(dst[:])
+	}
+}
+
+// traceID adds the trace id field of logger l, using the trace id in the entry context
+// if present or a generated one otherwise.
+func (e *Entry) traceID(l *Logger) {
+	field := l.traceIDField()
+	if e.context != nil {
+		switch v := e.context.Value(field).(type) {
+		case string:
+			if v != "" {
+				e.traceIDStr(field, v)
+				return
+			}
+		case int64:
+			e.Int64(field, v)
+			return
+		}
+	}
+	generator := l.TraceIDGenerator
+	if generator == nil {
+		generator = DefaultTraceIDGenerator
+	}
+	e.traceIDStr(field, generator.NewTraceID())
+}
+
+var nodename struct {
+	once sync.Once
+	name string
+}
+
+// nodeName returns the fully qualified hostname used by LogNode.
+func nodeName() string {
+	nodename.once.Do(func() {
+		nodename.name, _ = fqdn.Hostname()
+	})
+	return nodename.name
+}
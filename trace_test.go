@@ -0,0 +1,84 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"testing"
+)
+
+func TestTracing(t *testing.T) {
+	ids := TraceIDGeneratorFunc(func() string { return "generated" })
+	cases := []struct {
+		name string
+		log  func(l *Logger)
+		want string
+	}{
+		{"disabled", func(l *Logger) { l.Info().Msg("hi") }, `{"time":"2019-07-10T05:35:54.277Z","level":"info","message":"hi"}`},
+		{"generated", func(l *Logger) {
+			l.EnableTracing, l.TraceIDGenerator = true, ids
+			l.Info().Msg("hi")
+		}, `{"time":"2019-07-10T05:35:54.277Z","level":"info","trace_id":"generated","message":"hi"}`},
+		{"field", func(l *Logger) {
+			l.EnableTracing, l.TraceIDGenerator, l.TraceIDField = true, ids, "tid"
+			l.Info().Msg("hi")
+		}, `{"time":"2019-07-10T05:35:54.277Z","level":"info","tid":"generated","message":"hi"}`},
+		{"context", func(l *Logger) {
+			l.EnableTracing, l.TraceIDGenerator = true, ids
+			l.Info().WithContext(context.WithValue(context.Background(), "trace_id", "from-ctx")).Msg("hi")
+		}, `{"time":"2019-07-10T05:35:54.277Z","level":"info","trace_id":"from-ctx","message":"hi"}`},
+		{"context-int", func(l *Logger) {
+			l.EnableTracing, l.TraceIDGenerator = true, ids
+			l.Info().WithContext(context.WithValue(context.Background(), "trace_id", int64(42))).Msg("hi")
+		}, `{"time":"2019-07-10T05:35:54.277Z","level":"info","trace_id":42,"message":"hi"}`},
+		{"copy", func(l *Logger) {
+			l.EnableTracing, l.TraceIDGenerator, l.TraceIDField = true, ids, "tid"
+			child := With(l).Str("a", "b").Copy()
+			child.Info().Msg("hi")
+		}, `{"time":"2019-07-10T05:35:54.277Z","level":"info","a":"b","tid":"generated","message":"hi"}`},
+		{"node", func(l *Logger) {
+			l.LogNode = true
+			l.Info().Msg("hi")
+		}, `{"time":"2019-07-10T05:35:54.277Z","level":"info","host_platform":"` + nodeName() + `","message":"hi"}`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			c.log(&logger)
+			if got := b.String(); got != c.want+"\n" {
+				t.Errorf("got %s\nwant %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestTraceIDGenerators(t *testing.T) {
+	cases := []struct {
+		name      string
+		generator TraceIDGenerator
+		pattern   string
+	}{
+		{"xid", XIDGenerator, `^[0-9a-z]+$`},
+		{"uuidv7", UUIDv7Generator, `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{"w3c", W3CGenerator, `^[0-9a-f]{32}$`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			re := regexp.MustCompile(c.pattern)
+			seen := make(map[string]bool)
+			for i := 0; i < 100; i++ {
+				id := c.generator.NewTraceID()
+				if !re.MatchString(id) {
+					t.Fatalf("trace id %q does not match %s", id, c.pattern)
+				}
+				if seen[id] {
+					t.Fatalf("trace id %q is repeated", id)
+				}
+				seen[id] = true
+			}
+		})
+	}
+}
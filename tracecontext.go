@@ -0,0 +1,214 @@
+package log
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ContextExtractor adds fields taken from the context of an entry, see Entry.WithContext.
+// It reports whether a trace id field was added, so the logger does not add another.
+type ContextExtractor interface {
+	Extract(ctx context.Context, e *Entry) bool
+}
+
+// The ContextExtractorFunc type is an adapter to allow the use of
+// ordinary functions as context extractors. If f is a function
+// with the appropriate signature, ContextExtractorFunc(f) is a
+// [ContextExtractor] that calls f.
+type ContextExtractorFunc func(ctx context.Context, e *Entry) bool
+
+// Extract calls f(ctx, e).
+func (f ContextExtractorFunc) Extract(ctx context.Context, e *Entry) bool {
+	return f(ctx, e)
+}
+
+// ContextExtractors is a ContextExtractor that runs extractors in order.
+type ContextExtractors []ContextExtractor
+
+// Extract implements ContextExtractor.
+func (x ContextExtractors) Extract(ctx context.Context, e *Entry) (traced bool) {
+	for _, extractor := range x {
+		if extractor.Extract(ctx, e) {
+			traced = true
+		}
+	}
+	return
+}
+
+// TraceContext represents a W3C Trace Context, see https://www.w3.org/TR/trace-context/
+type TraceContext struct {
+	// TraceID is the trace id as 32 lowercase hex digits.
+	TraceID string
+
+	// SpanID is the parent span id as 16 lowercase hex digits.
+	SpanID string
+
+	// Flags is the trace flags, 0x01 means sampled.
+	Flags byte
+
+	// TraceState is the raw value of the tracestate header.
+	TraceState string
+
+	// Baggage is the raw value of the baggage header.
+	Baggage string
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZeroHex(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+func unhex(c byte) byte {
+	if c <= '9' {
+		return c - '0'
+	}
+	return c - 'a' + 10
+}
+
+// ParseTraceparent parses a traceparent header value in the form of
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func ParseTraceparent(s string) (tc TraceContext, ok bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return
+	}
+	version, traceID, spanID, flags := s[0:2], s[3:35], s[36:52], s[53:55]
+	if !isLowerHex(version) || version == "ff" || (version == "00" && len(s) != 55) || (len(s) > 55 && s[55] != '-') {
+		return
+	}
+	if !isLowerHex(traceID) || isZeroHex(traceID) || !isLowerHex(spanID) || isZeroHex(spanID) || !isLowerHex(flags) {
+		return
+	}
+	tc.TraceID = traceID
+	tc.SpanID = spanID
+	tc.Flags = unhex(flags[0])<<4 | unhex(flags[1])
+	return tc, true
+}
+
+// Traceparent returns the traceparent header value of tc.
+func (tc TraceContext) Traceparent() string {
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + string([]byte{hex[tc.Flags>>4], hex[tc.Flags&0x0f]})
+}
+
+// TraceContextFromHeader parses the traceparent, tracestate and baggage headers of h.
+func TraceContextFromHeader(h http.Header) (tc TraceContext, ok bool) {
+	tc, ok = ParseTraceparent(h.Get("traceparent"))
+	if !ok {
+		return
+	}
+	tc.TraceState = strings.Join(h.Values("tracestate"), ",")
+	tc.Baggage = strings.Join(h.Values("baggage"), ",")
+	return
+}
+
+type traceContextKey struct{}
+
+// ContextWithTraceContext returns a copy of ctx carrying tc.
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// ContextWithHeader returns a copy of ctx carrying the trace context of h if valid, or ctx otherwise.
+func ContextWithHeader(ctx context.Context, h http.Header) context.Context {
+	if tc, ok := TraceContextFromHeader(h); ok {
+		return ContextWithTraceContext(ctx, tc)
+	}
+	return ctx
+}
+
+// TraceContextFromContext returns the trace context carried by ctx. It falls back to
+// the "traceparent", "tracestate" and "baggage" string values of ctx.
+func TraceContextFromContext(ctx context.Context) (tc TraceContext, ok bool) {
+	if tc, ok = ctx.Value(traceContextKey{}).(TraceContext); ok {
+		return
+	}
+	if s, _ := ctx.Value("traceparent").(string); s != "" {
+		if tc, ok = ParseTraceparent(s); ok {
+			tc.TraceState, _ = ctx.Value("tracestate").(string)
+			tc.Baggage, _ = ctx.Value("baggage").(string)
+		}
+	}
+	return
+}
+
+// W3CExtractor is a ContextExtractor which adds the trace id, "span_id" and "trace_flags"
+// fields of the W3C trace context carried by the entry context, or their keys of Logger.Schema.
+type W3CExtractor struct {
+	// TraceState determines if adds the tracestate of the "tracestate" key.
+	TraceState bool
+
+	// Baggage determines if adds the baggage members as an object of the "baggage" key.
+	Baggage bool
+}
+
+// Extract implements ContextExtractor.
+func (x W3CExtractor) Extract(ctx context.Context, e *Entry) bool {
+	tc, ok := TraceContextFromContext(ctx)
+	if !ok {
+		return false
+	}
+
+	field := "trace_id"
+	if e.logger != nil {
+		field = e.logger.traceIDField()
+	}
+	e.traceIDStr(field, tc.TraceID)
+	e.Str(e.schema().spanIDKey(), tc.SpanID)
+	if s := e.schema(); s != nil && s.TraceSampledKey != "" {
+		e.Bool(s.TraceSampledKey, tc.Flags&0x01 != 0)
+	} else {
+		e.buf = append(e.buf, ",\"trace_flags\":\""...)
+		e.buf = append(e.buf, hex[tc.Flags>>4], hex[tc.Flags&0x0f], '"')
+	}
+	if x.TraceState && tc.TraceState != "" {
+		e.Str("tracestate", tc.TraceState)
+	}
+	if x.Baggage && tc.Baggage != "" {
+		e.baggage(tc.Baggage)
+	}
+	return true
+}
+
+// baggage adds the members of a baggage header value as an object of the "baggage" key.
+func (e *Entry) baggage(s string) {
+	e.buf = append(e.buf, ",\"baggage\":"...)
+	n := len(e.buf)
+	for _, member := range strings.Split(s, ",") {
+		// properties of a member are dropped
+		if i := strings.IndexByte(member, ';'); i >= 0 {
+			member = member[:i]
+		}
+		key, value, ok := strings.Cut(member, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if v, err := url.PathUnescape(value); err == nil {
+			value = v
+		}
+		e.buf = append(e.buf, ',', '"')
+		e.text(key)
+		e.buf = append(e.buf, '"', ':', '"')
+		e.string(value)
+		e.buf = append(e.buf, '"')
+	}
+	if n < len(e.buf) {
+		e.buf[n] = '{'
+		e.buf = append(e.buf, '}')
+	} else {
+		e.buf = append(e.buf, '{', '}')
+	}
+}
+
+var _ ContextExtractor = W3CExtractor{}
+var _ ContextExtractor = ContextExtractors(nil)
@@ -0,0 +1,96 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	cases := []struct {
+		value string
+		ok    bool
+		flags byte
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, 0x01},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, 0x00},
+		{" 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03 ", true, 0x03},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true, 0x01},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, 0},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, 0},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, 0},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, 0},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, 0},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0g", false, 0},
+		{"00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, 0},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false, 0},
+		{"", false, 0},
+	}
+
+	for _, c := range cases {
+		tc, ok := ParseTraceparent(c.value)
+		if ok != c.ok {
+			t.Errorf("ParseTraceparent(%q) ok = %v, want %v", c.value, ok, c.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if tc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || tc.SpanID != "00f067aa0ba902b7" || tc.Flags != c.flags {
+			t.Errorf("ParseTraceparent(%q) = %+v", c.value, tc)
+		}
+		if tp, _ := ParseTraceparent(tc.Traceparent()); tp != tc {
+			t.Errorf("Traceparent() of %+v = %q does not round-trip", tc, tc.Traceparent())
+		}
+	}
+}
+
+func TestW3CExtractor(t *testing.T) {
+	h := http.Header{}
+	h.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.Add("tracestate", "rojo=00f067aa0ba902b7")
+	h.Add("tracestate", "congo=t61rcWkgMzE")
+	h.Set("baggage", "userId=alice%20b,serverNode=DF28;prop=1,invalid")
+
+	pii := http.Header{}
+	pii.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	pii.Set("baggage", "alice@example.com=bob@example.com")
+
+	cases := []struct {
+		name      string
+		ctx       context.Context
+		extractor W3CExtractor
+		schema    *Schema
+		want      string
+	}{
+		{"header", ContextWithHeader(context.Background(), h), W3CExtractor{}, nil,
+			`{"time":"2019-07-10T05:35:54.277Z","level":"info","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7","trace_flags":"01","message":"hi"}`},
+		{"state-baggage", ContextWithHeader(context.Background(), h), W3CExtractor{TraceState: true, Baggage: true}, nil,
+			`{"time":"2019-07-10T05:35:54.277Z","level":"info","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7","trace_flags":"01","tracestate":"rojo=00f067aa0ba902b7,congo=t61rcWkgMzE","baggage":{"userId":"alice b","serverNode":"DF28"},"message":"hi"}`},
+		{"values", context.WithValue(context.Background(), "traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"), W3CExtractor{}, nil,
+			`{"time":"2019-07-10T05:35:54.277Z","level":"info","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7","trace_flags":"00","message":"hi"}`},
+		{"schema", ContextWithHeader(context.Background(), h), W3CExtractor{}, &Schema{TraceIDKey: "trace", SpanIDKey: "span", TraceSampledKey: "sampled"},
+			`{"time":"2019-07-10T05:35:54.277Z","level":"info","trace":"4bf92f3577b34da6a3ce929d0e0e4736","span":"00f067aa0ba902b7","sampled":true,"message":"hi"}`},
+		{"pii", ContextWithHeader(context.Background(), pii), W3CExtractor{Baggage: true}, nil,
+			`{"time":"2019-07-10T05:35:54.277Z","level":"info","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7","trace_flags":"01","baggage":{"alice@example.com":"***"},"message":"hi"}`},
+		{"none", context.Background(), W3CExtractor{}, nil,
+			`{"time":"2019-07-10T05:35:54.277Z","level":"info","trace_id":"generated","message":"hi"}`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b bytes.Buffer
+			logger := testLogger(t, &b)
+			logger.EnableTracing = true
+			logger.TraceIDGenerator = TraceIDGeneratorFunc(func() string { return "generated" })
+			logger.ContextExtractor = c.extractor
+			logger.Schema = c.schema
+			logger.PII = &PIIScanner{Email: PIIMask}
+			logger.Info().WithContext(c.ctx).Msg("hi")
+			if got := b.String(); got != c.want+"\n" {
+				t.Errorf("got %s\nwant %s", got, c.want)
+			}
+		})
+	}
+}